@@ -191,7 +191,7 @@ func (bi Int) Equals(o Int) bool {
 	return Cmp(bi, o) == 0
 }
 
-func (bi *Int) MarshalJSON() ([]byte, error) {
+func (bi Int) MarshalJSON() ([]byte, error) {
 	if bi.Int == nil {
 		zero := Zero()
 		return json.Marshal(zero)
@@ -2,6 +2,7 @@ package big
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"math/big"
 	"testing"
@@ -76,6 +77,11 @@ func TestInt_MarshalUnmarshalJSON(t *testing.T) {
 	s, err := tnil.MarshalJSON()
 	require.NoError(t, err)
 	assert.Equal(t, "\"0\"", string(s))
+
+	// Values not addressable, such as fields of a struct passed by value, also encode as strings.
+	s, err = json.Marshal(struct{ A Int }{Lsh(NewInt(1), 70)})
+	require.NoError(t, err)
+	assert.Equal(t, `{"A":"1180591620717411303424"}`, string(s))
 }
 
 func TestOperations(t *testing.T) {
@@ -1,6 +1,7 @@
 package builtin
 
 import (
+	"fmt"
 	"sort"
 
 	"github.com/ipfs/go-cid"
@@ -23,6 +24,9 @@ var (
 	CallerTypesSignable         []cid.Cid
 )
 
+// The version of the actors implemented in this repository, which forms part of their code CIDs.
+const ActorsVersion = 1
+
 var builtinActors map[cid.Cid]*actorInfo
 
 type actorInfo struct {
@@ -31,28 +35,24 @@ type actorInfo struct {
 }
 
 func init() {
-	builder := cid.V1Builder{Codec: cid.Raw, MhType: mh.IDENTITY}
 	builtinActors = make(map[cid.Cid]*actorInfo)
 
 	for id, info := range map[*cid.Cid]*actorInfo{ //nolint:nomaprange
-		&SystemActorCodeID:           {name: "fil/1/system"},
-		&InitActorCodeID:             {name: "fil/1/init"},
-		&CronActorCodeID:             {name: "fil/1/cron"},
-		&StoragePowerActorCodeID:     {name: "fil/1/storagepower"},
-		&StorageMinerActorCodeID:     {name: "fil/1/storageminer"},
-		&StorageMarketActorCodeID:    {name: "fil/1/storagemarket"},
-		&PaymentChannelActorCodeID:   {name: "fil/1/paymentchannel"},
-		&RewardActorCodeID:           {name: "fil/1/reward"},
-		&VerifiedRegistryActorCodeID: {name: "fil/1/verifiedregistry"},
-		&AccountActorCodeID:          {name: "fil/1/account", signer: true},
-		&MultisigActorCodeID:         {name: "fil/1/multisig", signer: true},
+		&SystemActorCodeID:           {name: "system"},
+		&InitActorCodeID:             {name: "init"},
+		&CronActorCodeID:             {name: "cron"},
+		&StoragePowerActorCodeID:     {name: "storagepower"},
+		&StorageMinerActorCodeID:     {name: "storageminer"},
+		&StorageMarketActorCodeID:    {name: "storagemarket"},
+		&PaymentChannelActorCodeID:   {name: "paymentchannel"},
+		&RewardActorCodeID:           {name: "reward"},
+		&VerifiedRegistryActorCodeID: {name: "verifiedregistry"},
+		&AccountActorCodeID:          {name: "account", signer: true},
+		&MultisigActorCodeID:         {name: "multisig", signer: true},
 	} {
-		c, err := builder.Sum([]byte(info.name))
-		if err != nil {
-			panic(err)
-		}
+		c := MakeActorCodeID(ActorsVersion, info.name)
 		*id = c
-		builtinActors[c] = info
+		builtinActors[c] = &actorInfo{name: ActorVersionName(ActorsVersion, info.name), signer: info.signer}
 	}
 
 	// Set of actor code types that can represent external signing parties.
@@ -67,7 +67,24 @@ func init() {
 
 }
 
+// Returns the name of a version of a builtin actor, such as "fil/1/storageminer" for version 1 of "storageminer".
+func ActorVersionName(version int, name string) string {
+	return fmt.Sprintf("fil/%d/%s", version, name)
+}
+
+// Returns the code CID of a version of a builtin actor, which is the identity hash of its versioned name.
+// Versions of an actor other than ActorsVersion are not implemented in this package.
+func MakeActorCodeID(version int, name string) cid.Cid {
+	builder := cid.V1Builder{Codec: cid.Raw, MhType: mh.IDENTITY}
+	c, err := builder.Sum([]byte(ActorVersionName(version, name)))
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
 // IsBuiltinActor returns true if the code belongs to an actor defined in this repo.
+// Only actors of ActorsVersion are recognised: see exported.Registry for actors of any version.
 func IsBuiltinActor(code cid.Cid) bool {
 	_, isBuiltin := builtinActors[code]
 	return isBuiltin
@@ -1,9 +1,15 @@
 package exported
 
 import (
+	"reflect"
+	"sort"
+
+	cid "github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/filecoin-project/specs-actors/actors/builtin"
-	cid "github.com/ipfs/go-cid"
+	"github.com/filecoin-project/specs-actors/actors/runtime"
 
 	"github.com/filecoin-project/specs-actors/actors/builtin/account"
 	"github.com/filecoin-project/specs-actors/actors/builtin/cron"
@@ -20,9 +26,45 @@ import (
 
 var _ abi.Invokee = BuiltinActor{}
 
+// A version of a builtin actor: its implementation, method table and state type.
 type BuiltinActor struct {
-	actor abi.Invokee
-	code  cid.Cid
+	actor   abi.Invokee
+	code    cid.Cid
+	version int
+	name    string
+	methods []Method
+	state   reflect.Type
+}
+
+// An exported method of an actor.
+type Method struct {
+	Num    abi.MethodNum
+	Name   string
+	Params reflect.Type // A pointer type.
+	Return reflect.Type // A pointer type.
+}
+
+// Describes a version of a builtin actor, such as version 2 of "storageminer", with code CID "fil/2/storageminer".
+// The methods are a struct of method numbers, such as builtin.MethodsMiner, naming the actor's exported methods.
+// The state is a pointer to a value of the actor's state type.
+func NewBuiltinActor(version int, name string, actor abi.Invokee, methods interface{}, state runtime.CBORer) (BuiltinActor, error) {
+	code := builtin.MakeActorCodeID(version, name)
+	table, err := methodTable(actor, methods)
+	if err != nil {
+		return BuiltinActor{}, xerrors.Errorf("invalid methods of actor %s: %w", builtin.ActorVersionName(version, name), err)
+	}
+	stateType := reflect.TypeOf(state)
+	if stateType == nil || stateType.Kind() != reflect.Ptr {
+		return BuiltinActor{}, xerrors.Errorf("state of actor %s is %v, not a pointer", builtin.ActorVersionName(version, name), stateType)
+	}
+	return BuiltinActor{
+		actor:   actor,
+		code:    code,
+		version: version,
+		name:    name,
+		methods: table,
+		state:   stateType.Elem(),
+	}, nil
 }
 
 // Code is the CodeID (cid) of the actor.
@@ -35,51 +77,167 @@ func (b BuiltinActor) Exports() []interface{} {
 	return b.actor.Exports()
 }
 
+// The actor version, from which its code CID is derived.
+func (b BuiltinActor) Version() int {
+	return b.version
+}
+
+// The unversioned name of the actor, such as "storageminer".
+func (b BuiltinActor) Name() string {
+	return b.name
+}
+
+// The exported methods, in order of method number.
+func (b BuiltinActor) Methods() []Method {
+	return append([]Method{}, b.methods...)
+}
+
+// Looks up an exported method by number.
+func (b BuiltinActor) Method(num abi.MethodNum) (Method, bool) {
+	for _, m := range b.methods {
+		if m.Num == num {
+			return m, true
+		}
+	}
+	return Method{}, false
+}
+
+// The type of the actor's state (not a pointer).
+func (b BuiltinActor) StateType() reflect.Type {
+	return b.state
+}
+
+// Returns a pointer to a new, zero value of the actor's state type, into which state may be decoded.
+func (b BuiltinActor) NewState() runtime.CBORer {
+	return reflect.New(b.state).Interface().(runtime.CBORer)
+}
+
+// Returns the actors implemented in this repository, of version builtin.ActorsVersion.
 func BuiltinActors() []BuiltinActor {
 	return []BuiltinActor{
-		{
-			actor: account.Actor{},
-			code:  builtin.AccountActorCodeID,
-		},
-		{
-			actor: cron.Actor{},
-			code:  builtin.CronActorCodeID,
-		},
-		{
-			actor: init_.Actor{},
-			code:  builtin.InitActorCodeID,
-		},
-		{
-			actor: market.Actor{},
-			code:  builtin.StorageMarketActorCodeID,
-		},
-		{
-			actor: miner.Actor{},
-			code:  builtin.StorageMinerActorCodeID,
-		},
-		{
-			actor: multisig.Actor{},
-			code:  builtin.MultisigActorCodeID,
-		},
-		{
-			actor: paych.Actor{},
-			code:  builtin.PaymentChannelActorCodeID,
-		},
-		{
-			actor: power.Actor{},
-			code:  builtin.StoragePowerActorCodeID,
-		},
-		{
-			actor: reward.Actor{},
-			code:  builtin.RewardActorCodeID,
-		},
-		{
-			actor: system.Actor{},
-			code:  builtin.SystemActorCodeID,
-		},
-		{
-			actor: verifreg.Actor{},
-			code:  builtin.VerifiedRegistryActorCodeID,
-		},
+		mustBuiltinActor("account", account.Actor{}, builtin.MethodsAccount, &account.State{}),
+		mustBuiltinActor("cron", cron.Actor{}, builtin.MethodsCron, &cron.State{}),
+		mustBuiltinActor("init", init_.Actor{}, builtin.MethodsInit, &init_.State{}),
+		mustBuiltinActor("storagemarket", market.Actor{}, builtin.MethodsMarket, &market.State{}),
+		mustBuiltinActor("storageminer", miner.Actor{}, builtin.MethodsMiner, &miner.State{}),
+		mustBuiltinActor("multisig", multisig.Actor{}, builtin.MethodsMultisig, &multisig.State{}),
+		mustBuiltinActor("paymentchannel", paych.Actor{}, builtin.MethodsPaych, &paych.State{}),
+		mustBuiltinActor("storagepower", power.Actor{}, builtin.MethodsPower, &power.State{}),
+		mustBuiltinActor("reward", reward.Actor{}, builtin.MethodsReward, &reward.State{}),
+		mustBuiltinActor("system", system.Actor{}, builtin.MethodsSystem, &system.State{}),
+		mustBuiltinActor("verifiedregistry", verifreg.Actor{}, builtin.MethodsVerifiedRegistry, &verifreg.State{}),
+	}
+}
+
+func mustBuiltinActor(name string, actor abi.Invokee, methods interface{}, state runtime.CBORer) BuiltinActor {
+	b, err := NewBuiltinActor(builtin.ActorsVersion, name, actor, methods, state)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Builds the method table of an actor from a struct of method numbers, checking that it names exactly the
+// methods the actor exports.
+func methodTable(actor abi.Invokee, methods interface{}) ([]Method, error) {
+	exports := actor.Exports()
+	named := make([]bool, len(exports))
+	var table []Method
+
+	mv := reflect.ValueOf(methods)
+	if mv.Kind() != reflect.Struct {
+		return nil, xerrors.Errorf("method numbers %v are not a struct", mv.Type())
+	}
+	for i := 0; i < mv.NumField(); i++ {
+		name := mv.Type().Field(i).Name
+		num, ok := mv.Field(i).Interface().(abi.MethodNum)
+		if !ok {
+			return nil, xerrors.Errorf("method %s number is a %v, not a method number", name, mv.Field(i).Type())
+		}
+		if int(num) >= len(exports) || exports[num] == nil {
+			return nil, xerrors.Errorf("method %s number %d is not exported", name, num)
+		}
+		if named[num] {
+			return nil, xerrors.Errorf("method %s number %d is already named", name, num)
+		}
+		named[num] = true
+		fn := reflect.TypeOf(exports[num])
+		if err := runtime.VerifyMethodType(fn); err != nil {
+			return nil, xerrors.Errorf("method %s: %w", name, err)
+		}
+		table = append(table, Method{Num: num, Name: name, Params: fn.In(1), Return: fn.Out(0)})
+	}
+	for num, export := range exports {
+		if export != nil && !named[num] {
+			return nil, xerrors.Errorf("exported method %d is not named", num)
+		}
+	}
+	sort.Slice(table, func(i, j int) bool { return table[i].Num < table[j].Num })
+	return table, nil
+}
+
+// A set of builtin actors of any number of versions, keyed by code CID.
+// Nodes use a registry to find the implementation and state type of an actor with any builtin code, so that actors
+// of old and new versions may coexist in a state tree during and after a network upgrade.
+type Registry struct {
+	actors map[cid.Cid]BuiltinActor
+}
+
+// Creates a registry of builtin actors, which must have distinct code CIDs.
+func NewRegistry(actors ...BuiltinActor) (*Registry, error) {
+	r := &Registry{actors: make(map[cid.Cid]BuiltinActor, len(actors))}
+	for _, a := range actors {
+		if _, ok := r.actors[a.code]; ok {
+			return nil, xerrors.Errorf("actor %s registered more than once", builtin.ActorVersionName(a.version, a.name))
+		}
+		r.actors[a.code] = a
+	}
+	return r, nil
+}
+
+// Returns a registry of the builtin actors of every version implemented in this repository.
+func BuiltinRegistry() *Registry {
+	r, err := NewRegistry(BuiltinActors()...)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Looks up the builtin actor with a code CID.
+func (r *Registry) Lookup(code cid.Cid) (BuiltinActor, bool) {
+	a, ok := r.actors[code]
+	return a, ok
+}
+
+// Whether a code CID is that of a builtin actor of any version in the registry.
+func (r *Registry) IsBuiltinActor(code cid.Cid) bool {
+	_, ok := r.actors[code]
+	return ok
+}
+
+// Returns the registered actors, ordered by version and then name.
+func (r *Registry) Actors() []BuiltinActor {
+	actors := make([]BuiltinActor, 0, len(r.actors))
+	for _, a := range r.actors { //nolint:nomaprange
+		actors = append(actors, a)
+	}
+	sort.Slice(actors, func(i, j int) bool {
+		if actors[i].version != actors[j].version {
+			return actors[i].version < actors[j].version
+		}
+		return actors[i].name < actors[j].name
+	})
+	return actors
+}
+
+// Returns the registered versions of an actor, in order of version.
+func (r *Registry) Versions(name string) []BuiltinActor {
+	var versions []BuiltinActor
+	for _, a := range r.Actors() {
+		if a.name == name {
+			versions = append(versions, a)
+		}
 	}
+	return versions
 }
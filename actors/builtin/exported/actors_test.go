@@ -0,0 +1,124 @@
+package exported_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/exported"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/actors/builtin/power"
+	"github.com/filecoin-project/specs-actors/actors/util/adt"
+	tutil "github.com/filecoin-project/specs-actors/support/testing"
+)
+
+func TestBuiltinActors(t *testing.T) {
+	actors := exported.BuiltinActors()
+	codes := map[string]bool{}
+	for _, a := range actors {
+		assert.Equal(t, builtin.ActorsVersion, a.Version())
+		assert.True(t, builtin.IsBuiltinActor(a.Code()), a.Name())
+		assert.Equal(t, builtin.ActorVersionName(builtin.ActorsVersion, a.Name()), builtin.ActorNameByCode(a.Code()))
+		codes[a.Code().KeyString()] = true
+
+		// The method table covers every exported method.
+		exports := 0
+		for _, e := range a.Exports() {
+			if e != nil {
+				exports++
+			}
+		}
+		assert.Len(t, a.Methods(), exports, a.Name())
+		m, ok := a.Method(builtin.MethodConstructor)
+		require.True(t, ok, a.Name())
+		assert.Equal(t, "Constructor", m.Name)
+	}
+	assert.Len(t, codes, 11)
+
+	miner1 := findActor(t, actors, "storageminer")
+	assert.Equal(t, builtin.StorageMinerActorCodeID, miner1.Code())
+	assert.Equal(t, reflect.TypeOf(miner.State{}), miner1.StateType())
+	m, ok := miner1.Method(builtin.MethodsMiner.SubmitWindowedPoSt)
+	require.True(t, ok)
+	assert.Equal(t, exported.Method{
+		Num:    builtin.MethodsMiner.SubmitWindowedPoSt,
+		Name:   "SubmitWindowedPoSt",
+		Params: reflect.TypeOf(&miner.SubmitWindowedPoStParams{}),
+		Return: reflect.TypeOf(&adt.EmptyValue{}),
+	}, m)
+	_, ok = miner1.Method(abi.MethodNum(100))
+	assert.False(t, ok)
+
+	// A new state decodes the actor's state.
+	st := power.ConstructState(tutil.MakeCID("map", nil), tutil.MakeCID("mmap", nil))
+	buf := bytes.Buffer{}
+	require.NoError(t, st.MarshalCBOR(&buf))
+	decoded := findActor(t, actors, "storagepower").NewState()
+	require.NoError(t, decoded.UnmarshalCBOR(&buf))
+	assert.Equal(t, st, decoded)
+}
+
+func TestNewBuiltinActor(t *testing.T) {
+	_, err := exported.NewBuiltinActor(2, "storageminer", miner.Actor{}, builtin.MethodsPower, &miner.State{})
+	assert.Error(t, err, "method names do not match exports")
+	_, err = exported.NewBuiltinActor(2, "storageminer", miner.Actor{}, struct{ Constructor abi.MethodNum }{1}, &miner.State{})
+	assert.Error(t, err, "method table incomplete")
+	_, err = exported.NewBuiltinActor(2, "storageminer", miner.Actor{}, builtin.MethodsMiner, (*miner.State)(nil))
+	assert.NoError(t, err)
+}
+
+func TestRegistry(t *testing.T) {
+	t.Run("builtin", func(t *testing.T) {
+		r := exported.BuiltinRegistry()
+		assert.Len(t, r.Actors(), 11)
+		a, ok := r.Lookup(builtin.StorageMarketActorCodeID)
+		require.True(t, ok)
+		assert.Equal(t, "storagemarket", a.Name())
+		assert.True(t, r.IsBuiltinActor(builtin.AccountActorCodeID))
+		assert.False(t, r.IsBuiltinActor(tutil.MakeCID("unknown", nil)))
+		assert.False(t, r.IsBuiltinActor(builtin.MakeActorCodeID(2, "storageminer")))
+	})
+
+	t.Run("multiple versions", func(t *testing.T) {
+		miner2, err := exported.NewBuiltinActor(2, "storageminer", miner.Actor{}, builtin.MethodsMiner, &miner.State{})
+		require.NoError(t, err)
+		assert.Equal(t, builtin.MakeActorCodeID(2, "storageminer"), miner2.Code())
+		assert.NotEqual(t, builtin.StorageMinerActorCodeID, miner2.Code())
+		assert.Equal(t, "fil/2/storageminer", string(miner2.Code().Hash()[2:]))
+
+		r, err := exported.NewRegistry(append(exported.BuiltinActors(), miner2)...)
+		require.NoError(t, err)
+		assert.True(t, r.IsBuiltinActor(builtin.StorageMinerActorCodeID))
+		assert.True(t, r.IsBuiltinActor(miner2.Code()))
+		found, ok := r.Lookup(miner2.Code())
+		require.True(t, ok)
+		assert.Equal(t, 2, found.Version())
+
+		versions := r.Versions("storageminer")
+		require.Len(t, versions, 2)
+		assert.Equal(t, 1, versions[0].Version())
+		assert.Equal(t, 2, versions[1].Version())
+		actors := r.Actors()
+		assert.Equal(t, miner2.Code(), actors[len(actors)-1].Code())
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		_, err := exported.NewRegistry(append(exported.BuiltinActors(), exported.BuiltinActors()[0])...)
+		assert.Error(t, err)
+	})
+}
+
+func findActor(t *testing.T, actors []exported.BuiltinActor, name string) exported.BuiltinActor {
+	for _, a := range actors {
+		if a.Name() == name {
+			return a
+		}
+	}
+	require.FailNow(t, "no actor "+name)
+	return exported.BuiltinActor{}
+}
@@ -0,0 +1,272 @@
+package market
+
+import (
+	"sort"
+
+	addr "github.com/filecoin-project/go-address"
+	cid "github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/util/adt"
+)
+
+// A summary of the market state, collected while checking its invariants, for checks of consistency with other
+// actors.
+type StateSummary struct {
+	Deals map[abi.DealID]*DealSummary
+}
+
+// The parties, terms and progress of a deal.
+type DealSummary struct {
+	Provider         addr.Address
+	Client           addr.Address
+	PieceSize        abi.PaddedPieceSize
+	VerifiedDeal     bool
+	StartEpoch       abi.ChainEpoch
+	EndEpoch         abi.ChainEpoch
+	SectorStartEpoch abi.ChainEpoch // epochUndefined until activated
+	LastUpdatedEpoch abi.ChainEpoch
+	SlashEpoch       abi.ChainEpoch
+}
+
+// Checks internal invariants of the market state, given the actor's balance, returning a summary of the deals and
+// the violations found. The summary may be incomplete if violations were found.
+// The locked balances and totals are recomputed from the obligations of each deal: both parties' collateral and the
+// storage fee not yet paid to the provider.
+func CheckStateInvariants(store adt.Store, st *State, balance abi.TokenAmount) (*StateSummary, *builtin.MessageAccumulator) {
+	acc := &builtin.MessageAccumulator{}
+	summary := &StateSummary{Deals: map[abi.DealID]*DealSummary{}}
+
+	proposals, ok := loadProposals(acc, store, st)
+	if !ok {
+		return summary, acc
+	}
+	dealStates, ok := loadDealStates(acc, store, st, proposals)
+	if !ok {
+		return summary, acc
+	}
+
+	// Recompute the locked amounts from the deals.
+	clientCollateral, providerCollateral, storageFees := big.Zero(), big.Zero(), big.Zero()
+	lockedByAddr := map[addr.Address]abi.TokenAmount{}
+	lock := func(a addr.Address, amount abi.TokenAmount) {
+		if prev, ok := lockedByAddr[a]; ok {
+			amount = big.Add(prev, amount)
+		}
+		lockedByAddr[a] = amount
+	}
+	pending := map[cid.Cid]abi.DealID{}
+	for _, id := range sortedDealIDs(proposals) {
+		proposal := proposals[id]
+		remainingFee := proposal.TotalStorageFee()
+		state, activated := dealStates[id]
+		deal := &DealSummary{
+			Provider:         proposal.Provider,
+			Client:           proposal.Client,
+			PieceSize:        proposal.PieceSize,
+			VerifiedDeal:     proposal.VerifiedDeal,
+			StartEpoch:       proposal.StartEpoch,
+			EndEpoch:         proposal.EndEpoch,
+			SectorStartEpoch: epochUndefined,
+			LastUpdatedEpoch: epochUndefined,
+			SlashEpoch:       epochUndefined,
+		}
+		if activated {
+			deal.SectorStartEpoch = state.SectorStartEpoch
+			deal.LastUpdatedEpoch = state.LastUpdatedEpoch
+			deal.SlashEpoch = state.SlashEpoch
+		}
+		summary.Deals[id] = deal
+		if activated && state.LastUpdatedEpoch != epochUndefined {
+			paidTo := state.LastUpdatedEpoch
+			if paidTo < proposal.StartEpoch {
+				paidTo = proposal.StartEpoch
+			}
+			if paidTo > proposal.EndEpoch {
+				paidTo = proposal.EndEpoch
+			}
+			remainingFee = big.Mul(big.NewInt(int64(proposal.EndEpoch-paidTo)), proposal.StoragePricePerEpoch)
+		} else {
+			// A proposal remains pending until the first cron update after its activation.
+			pcid, err := proposal.Cid()
+			if acc.RequireNoError(err, "failed to compute CID of deal %d", id) {
+				pending[pcid] = id
+			}
+		}
+		clientCollateral = big.Add(clientCollateral, proposal.ClientCollateral)
+		providerCollateral = big.Add(providerCollateral, proposal.ProviderCollateral)
+		storageFees = big.Add(storageFees, remainingFee)
+		lock(proposal.Client, big.Add(proposal.ClientCollateral, remainingFee))
+		lock(proposal.Provider, proposal.ProviderCollateral)
+	}
+	acc.Require(st.TotalClientLockedCollateral.Equals(clientCollateral),
+		"total client locked collateral %v differs from deals' %v", st.TotalClientLockedCollateral, clientCollateral)
+	acc.Require(st.TotalProviderLockedCollateral.Equals(providerCollateral),
+		"total provider locked collateral %v differs from deals' %v", st.TotalProviderLockedCollateral, providerCollateral)
+	acc.Require(st.TotalClientStorageFee.Equals(storageFees),
+		"total client storage fee %v differs from deals' %v", st.TotalClientStorageFee, storageFees)
+
+	checkBalanceTables(acc, store, st, balance, lockedByAddr)
+	checkPendingProposals(acc, store, st, pending)
+	checkDealOps(acc, store, st, proposals)
+	return summary, acc
+}
+
+// Loads all deal proposals, checking each ID is below the next ID to be allocated.
+func loadProposals(acc *builtin.MessageAccumulator, store adt.Store, st *State) (map[abi.DealID]*DealProposal, bool) {
+	proposals := map[abi.DealID]*DealProposal{}
+	array, err := AsDealProposalArray(store, st.Proposals)
+	if !acc.RequireNoError(err, "failed to load deal proposals") {
+		return nil, false
+	}
+	var proposal DealProposal
+	if err = array.ForEach(&proposal, func(id int64) error {
+		acc.Require(abi.DealID(id) < st.NextID, "deal %d is not below next deal ID %d", id, st.NextID)
+		p := proposal
+		proposals[abi.DealID(id)] = &p
+		return nil
+	}); !acc.RequireNoError(err, "failed to iterate deal proposals") {
+		return nil, false
+	}
+	return proposals, true
+}
+
+// Loads all deal states, checking each belongs to a proposal.
+func loadDealStates(acc *builtin.MessageAccumulator, store adt.Store, st *State, proposals map[abi.DealID]*DealProposal) (map[abi.DealID]*DealState, bool) {
+	states := map[abi.DealID]*DealState{}
+	array, err := AsDealStateArray(store, st.States)
+	if !acc.RequireNoError(err, "failed to load deal states") {
+		return nil, false
+	}
+	var state DealState
+	if err = array.ForEach(&state, func(id int64) error {
+		_, found := proposals[abi.DealID(id)]
+		acc.Require(found, "deal state %d has no proposal", id)
+		acc.Require(state.SectorStartEpoch != epochUndefined, "deal %d has a state but no sector start epoch", id)
+		s := state
+		states[abi.DealID(id)] = &s
+		return nil
+	}); !acc.RequireNoError(err, "failed to iterate deal states") {
+		return nil, false
+	}
+	return states, true
+}
+
+// Checks that each address's escrow covers its locked balance, which equals that computed from deals, and that the
+// actor's balance covers all escrow.
+func checkBalanceTables(acc *builtin.MessageAccumulator, store adt.Store, st *State, balance abi.TokenAmount,
+	lockedByAddr map[addr.Address]abi.TokenAmount) {
+	escrowTable, err := adt.AsBalanceTable(store, st.EscrowTable)
+	if !acc.RequireNoError(err, "failed to load escrow table") {
+		return
+	}
+	lockedTable, err := adt.AsBalanceTable(store, st.LockedTable)
+	if !acc.RequireNoError(err, "failed to load locked table") {
+		return
+	}
+
+	totalEscrow, err := escrowTable.Total()
+	if acc.RequireNoError(err, "failed to total escrow table") {
+		acc.Require(balance.GreaterThanEqual(totalEscrow), "balance %v below total escrow %v", balance, totalEscrow)
+	}
+
+	var locked abi.TokenAmount
+	if err = (*adt.Map)(lockedTable).ForEach(&locked, func(key string) error {
+		a, err := addr.NewFromBytes([]byte(key))
+		if err != nil {
+			return err
+		}
+		escrow, err := escrowTable.Get(a)
+		if err != nil {
+			return err
+		}
+		acc.Require(escrow.GreaterThanEqual(locked), "escrow %v of %v below locked %v", escrow, a, locked)
+
+		expected, ok := lockedByAddr[a]
+		if !ok {
+			expected = big.Zero()
+		}
+		acc.Require(locked.Equals(expected), "locked balance %v of %v differs from deals' %v", locked, a, expected)
+		delete(lockedByAddr, a)
+		return nil
+	}); !acc.RequireNoError(err, "failed to iterate locked table") {
+		return
+	}
+	for a, expected := range lockedByAddr { //nolint:nomaprange
+		acc.Require(expected.IsZero(), "no locked balance for %v, deals lock %v", a, expected)
+	}
+}
+
+// Checks that the pending proposals are exactly those of deals not yet updated after activation.
+func checkPendingProposals(acc *builtin.MessageAccumulator, store adt.Store, st *State, expected map[cid.Cid]abi.DealID) {
+	pendingProposals, err := adt.AsMap(store, st.PendingProposals)
+	if !acc.RequireNoError(err, "failed to load pending proposals") {
+		return
+	}
+	var proposal DealProposal
+	if err = pendingProposals.ForEach(&proposal, func(key string) error {
+		pcid, err := cid.Cast([]byte(key))
+		if err != nil {
+			return err
+		}
+		_, ok := expected[pcid]
+		acc.Require(ok, "pending proposal %v is not an unactivated deal", pcid)
+		delete(expected, pcid)
+		return nil
+	}); !acc.RequireNoError(err, "failed to iterate pending proposals") {
+		return
+	}
+	for pcid, id := range expected { //nolint:nomaprange
+		acc.Addf("deal %d proposal %v is not pending", id, pcid)
+	}
+}
+
+// Checks that every deal is scheduled exactly once in DealOpsByEpoch, at an epoch no earlier than the last cron, and
+// that every scheduled deal exists.
+func checkDealOps(acc *builtin.MessageAccumulator, store adt.Store, st *State, proposals map[abi.DealID]*DealProposal) {
+	dealOps, err := adt.AsMap(store, st.DealOpsByEpoch)
+	if !acc.RequireNoError(err, "failed to load deal ops") {
+		return
+	}
+	scheduled := map[abi.DealID]int{}
+	var setRoot cbg.CborCid
+	if err = dealOps.ForEach(&setRoot, func(key string) error {
+		epoch, err := adt.ParseUIntKey(key)
+		if err != nil {
+			return err
+		}
+		set, err := adt.AsSet(store, cid.Cid(setRoot))
+		if err != nil {
+			return err
+		}
+		// A deal starting at the current epoch may be published after that epoch's cron has run.
+		acc.Require(abi.ChainEpoch(epoch) >= st.LastCron, "deal ops at epoch %d before last cron %d", epoch, st.LastCron)
+		return set.ForEach(func(k string) error {
+			id, err := parseDealKey(k)
+			if err != nil {
+				return err
+			}
+			_, found := proposals[id]
+			acc.Require(found, "deal op at epoch %d for deal %d with no proposal", epoch, id)
+			scheduled[id]++
+			return nil
+		})
+	}); !acc.RequireNoError(err, "failed to iterate deal ops") {
+		return
+	}
+	for _, id := range sortedDealIDs(proposals) {
+		acc.Require(scheduled[id] == 1, "deal %d scheduled in deal ops %d times", id, scheduled[id])
+	}
+}
+
+func sortedDealIDs(proposals map[abi.DealID]*DealProposal) []abi.DealID {
+	ids := make([]abi.DealID, 0, len(proposals))
+	for id := range proposals { //nolint:nomaprange
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -0,0 +1,119 @@
+package market_test
+
+import (
+	"fmt"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
+	"github.com/filecoin-project/specs-actors/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/support/mock"
+	tutil "github.com/filecoin-project/specs-actors/support/testing"
+)
+
+func TestCheckStateInvariants(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	mAddrs := &minerAddrs{owner, worker, provider}
+
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+	sectorExpiry := endEpoch + 100
+
+	// Sets up one deal paid up to its start epoch and a second deal which is pending.
+	setup := func(t *testing.T) (*mock.Runtime, *market.State) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		rt.SetEpoch(startEpoch)
+		actor.cronTick(rt)
+		actor.generateAndPublishDeal(rt, client, mAddrs, startEpoch+10, endEpoch)
+
+		var st market.State
+		rt.GetState(&st)
+		return rt, &st
+	}
+
+	check := func(rt *mock.Runtime, st *market.State) *builtin.MessageAccumulator {
+		_, acc := market.CheckStateInvariants(rt.AdtStore(), st, rt.Balance())
+		return acc
+	}
+
+	t.Run("consistent state has no violations", func(t *testing.T) {
+		rt, st := setup(t)
+		summary, acc := market.CheckStateInvariants(rt.AdtStore(), st, rt.Balance())
+		tutil.AssertNoMsgs(t, acc)
+		require.Len(t, summary.Deals, 2)
+		assert.Equal(t, provider, summary.Deals[0].Provider)
+		assert.Equal(t, startEpoch, summary.Deals[0].LastUpdatedEpoch)
+		assert.Equal(t, abi.ChainEpoch(-1), summary.Deals[1].SectorStartEpoch)
+	})
+
+	t.Run("insufficient balance", func(t *testing.T) {
+		rt, st := setup(t)
+		_, acc := market.CheckStateInvariants(rt.AdtStore(), st, big.Zero())
+		tutil.AssertMsgContains(t, acc, "balance 0 below total escrow")
+	})
+
+	t.Run("deal not below next ID", func(t *testing.T) {
+		rt, st := setup(t)
+		st.NextID = 1
+		tutil.AssertMsgContains(t, check(rt, st), "deal 1 is not below next deal ID 1")
+	})
+
+	t.Run("totals differ from deals", func(t *testing.T) {
+		rt, st := setup(t)
+		st.TotalClientLockedCollateral = big.Add(st.TotalClientLockedCollateral, big.NewInt(1))
+		st.TotalClientStorageFee = big.Zero()
+		tutil.AssertMsgContains(t, check(rt, st), "total client locked collateral")
+		tutil.AssertMsgContains(t, check(rt, st), "total client storage fee 0 differs from deals'")
+	})
+
+	t.Run("escrow below locked", func(t *testing.T) {
+		rt, st := setup(t)
+		escrow, err := adt.AsBalanceTable(rt.AdtStore(), st.EscrowTable)
+		require.NoError(t, err)
+		balance, err := escrow.Get(provider)
+		require.NoError(t, err)
+		require.NoError(t, escrow.MustSubtract(provider, balance))
+		st.EscrowTable, err = escrow.Root()
+		require.NoError(t, err)
+		tutil.AssertMsgContains(t, check(rt, st), fmt.Sprintf("escrow 0 of %v below locked", provider))
+	})
+
+	t.Run("locked differs from deals", func(t *testing.T) {
+		rt, st := setup(t)
+		locked, err := adt.AsBalanceTable(rt.AdtStore(), st.LockedTable)
+		require.NoError(t, err)
+		require.NoError(t, locked.Add(client, big.NewInt(1)))
+		st.LockedTable, err = locked.Root()
+		require.NoError(t, err)
+		tutil.AssertMsgContains(t, check(rt, st), fmt.Sprintf("of %v differs from deals'", client))
+	})
+
+	t.Run("pending proposal missing", func(t *testing.T) {
+		rt, st := setup(t)
+		st.PendingProposals = emptyMap(t, rt)
+		tutil.AssertMsgContains(t, check(rt, st), "deal 1 proposal")
+	})
+
+	t.Run("deal not scheduled", func(t *testing.T) {
+		rt, st := setup(t)
+		st.DealOpsByEpoch = emptyMap(t, rt)
+		tutil.AssertMsgContains(t, check(rt, st), "deal 0 scheduled in deal ops 0 times")
+		tutil.AssertMsgContains(t, check(rt, st), "deal 1 scheduled in deal ops 0 times")
+	})
+}
+
+func emptyMap(t *testing.T, rt *mock.Runtime) cid.Cid {
+	root, err := adt.MakeEmptyMap(rt.AdtStore()).Root()
+	require.NoError(t, err)
+	return root
+}
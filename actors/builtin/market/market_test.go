@@ -290,6 +290,7 @@ func TestMarketActor(t *testing.T) {
 
 			rt.GetState(&st)
 			assert.Equal(t, abi.NewTokenAmount(19), actor.getEscrowBalance(rt, client))
+			actor.checkState(rt)
 		})
 
 		t.Run("client withdrawing more than escrow balance limits to available funds", func(t *testing.T) {
@@ -608,6 +609,7 @@ func TestPublishStorageDeals(t *testing.T) {
 		require.EqualValues(t, big.Add(providerLocked, provider2Locked), st.TotalProviderLockedCollateral)
 		totalStorageFee = big.Add(totalStorageFee, big.Add(deal6.TotalStorageFee(), deal7.TotalStorageFee()))
 		require.EqualValues(t, totalStorageFee, st.TotalClientStorageFee)
+		actor.checkState(rt)
 	})
 }
 
@@ -964,6 +966,7 @@ func TestActivateDeals(t *testing.T) {
 		// provider1 activates deal3
 		actor.activateDeals(rt, sectorExpiry, provider, currentEpoch, dealId3)
 		actor.assertDealsNotActivated(rt, currentEpoch, dealId4)
+		actor.checkState(rt)
 	})
 }
 
@@ -1155,6 +1158,7 @@ func TestOnMinerSectorsTerminate(t *testing.T) {
 		// provider2 terminates deal4
 		actor.terminateDeals(rt, provider2, dealId4)
 		actor.assertDealsTerminated(rt, currentEpoch, dealId4)
+		actor.checkState(rt)
 	})
 
 	t.Run("ignore deal proposal that does not exist", func(t *testing.T) {
@@ -1436,6 +1440,7 @@ func TestCronTick(t *testing.T) {
 		rt.SetEpoch(d1.StartEpoch)
 		actor.cronTick(rt)
 		actor.publishDeals(rt, mAddrs, d2)
+		actor.checkState(rt)
 	})
 }
 
@@ -1636,6 +1641,7 @@ func TestCronTickTimedoutDeals(t *testing.T) {
 		actor.assertDealDeleted(rt, dealIds[0], &deal1)
 		actor.assertDealDeleted(rt, dealIds[1], &deal2)
 		actor.assertDealDeleted(rt, dealIds[2], &deal3)
+		actor.checkState(rt)
 	})
 }
 
@@ -1725,6 +1731,7 @@ func TestCronTickDealExpiry(t *testing.T) {
 
 		// deal should be deleted as it should have expired
 		actor.assertDealDeleted(rt, dealId, d)
+		actor.checkState(rt)
 	})
 
 	t.Run("deal expiry -> payment for a deal if deal is already expired before a cron tick", func(t *testing.T) {
@@ -2063,6 +2070,7 @@ func TestCronTickDealSlashing(t *testing.T) {
 
 		// deal should be deleted as it should have expired
 		actor.assertDealDeleted(rt, dealId, d)
+		actor.checkState(rt)
 	})
 
 	// expired deals should NOT be slashed
@@ -2656,6 +2664,13 @@ func (h *marketActorTestHarness) assertAccountZero(rt *mock.Runtime, addr addres
 	require.Equal(h.t, big.Zero(), b)
 }
 
+func (h *marketActorTestHarness) checkState(rt *mock.Runtime) {
+	var st market.State
+	rt.GetState(&st)
+	_, acc := market.CheckStateInvariants(adt.AsStore(rt), &st, rt.Balance())
+	assert.Empty(h.t, acc.Messages(), "market state invariants violated")
+}
+
 func (h *marketActorTestHarness) getEscrowBalance(rt *mock.Runtime, addr address.Address) abi.TokenAmount {
 	var st market.State
 	rt.GetState(&st)
@@ -0,0 +1,72 @@
+package builtin
+
+import (
+	"fmt"
+)
+
+// Accumulates a sequence of messages, such as the invariant violations found in a state.
+// The zero value is empty and ready to use. Accumulators derived with WithPrefix share the messages of their parent.
+type MessageAccumulator struct {
+	prefix string
+	msgs   *[]string
+}
+
+// Returns a new accumulator that adds messages to this one, prefixed with a formatted string.
+func (ma *MessageAccumulator) WithPrefix(format string, args ...interface{}) *MessageAccumulator {
+	if ma.msgs == nil {
+		ma.msgs = &[]string{}
+	}
+	return &MessageAccumulator{
+		prefix: ma.prefix + fmt.Sprintf(format, args...),
+		msgs:   ma.msgs,
+	}
+}
+
+func (ma *MessageAccumulator) IsEmpty() bool {
+	return ma.msgs == nil || len(*ma.msgs) == 0
+}
+
+// The accumulated messages, in the order they were added.
+func (ma *MessageAccumulator) Messages() []string {
+	if ma.msgs == nil {
+		return nil
+	}
+	return append([]string{}, *ma.msgs...)
+}
+
+// Adds a message.
+func (ma *MessageAccumulator) Add(msg string) {
+	if ma.msgs == nil {
+		ma.msgs = &[]string{}
+	}
+	*ma.msgs = append(*ma.msgs, ma.prefix+msg)
+}
+
+// Adds a formatted message.
+func (ma *MessageAccumulator) Addf(format string, args ...interface{}) {
+	ma.Add(fmt.Sprintf(format, args...))
+}
+
+// Adds the messages of another accumulator.
+func (ma *MessageAccumulator) AddAll(other *MessageAccumulator) {
+	for _, msg := range other.Messages() {
+		ma.Add(msg)
+	}
+}
+
+// Adds a formatted message if the predicate is false.
+func (ma *MessageAccumulator) Require(predicate bool, format string, args ...interface{}) {
+	if !predicate {
+		ma.Addf(format, args...)
+	}
+}
+
+// Adds a formatted message, suffixed by the error, if the error is not nil.
+// Returns whether the error was nil.
+func (ma *MessageAccumulator) RequireNoError(err error, format string, args ...interface{}) bool {
+	if err != nil {
+		ma.Addf(format+": %v", append(args, err)...)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,35 @@
+package builtin_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+)
+
+func TestMessageAccumulator(t *testing.T) {
+	acc := &builtin.MessageAccumulator{}
+	assert.True(t, acc.IsEmpty())
+	assert.Empty(t, acc.Messages())
+
+	acc.Require(true, "not added")
+	acc.Require(false, "added %d", 1)
+	assert.True(t, acc.RequireNoError(nil, "not added"))
+
+	prefixed := acc.WithPrefix("deadline %d: ", 2).WithPrefix("partition %d: ", 3)
+	assert.False(t, prefixed.RequireNoError(xerrors.New("oops"), "failed to load %s", "sectors"))
+
+	other := &builtin.MessageAccumulator{}
+	other.Add("from other")
+	acc.AddAll(other)
+
+	assert.False(t, acc.IsEmpty())
+	assert.Equal(t, []string{
+		"added 1",
+		"deadline 2: partition 3: failed to load sectors: oops",
+		"from other",
+	}, acc.Messages())
+	assert.Equal(t, acc.Messages(), prefixed.Messages())
+}
@@ -9,6 +9,10 @@ const (
 	MethodConstructor = abi.MethodNum(1)
 )
 
+var MethodsSystem = struct {
+	Constructor abi.MethodNum
+}{MethodConstructor}
+
 var MethodsAccount = struct {
 	Constructor   abi.MethodNum
 	PubkeyAddress abi.MethodNum
@@ -0,0 +1,335 @@
+package miner
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/filecoin-project/go-bitfield"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/util/adt"
+)
+
+// A summary of a miner's state, collected while checking its invariants, for checks of consistency with other actors.
+type StateSummary struct {
+	LivePower   PowerPair
+	FaultyPower PowerPair
+	// The deals in live sectors, by deal ID.
+	Deals map[abi.DealID]SectorDealSummary
+}
+
+// The sector in which a deal is stored.
+type SectorDealSummary struct {
+	Sector           abi.SectorNumber
+	SectorStart      abi.ChainEpoch
+	SectorExpiration abi.ChainEpoch
+}
+
+// The power that counts towards the miner's claim: that of live sectors which are not faulty.
+func (s *StateSummary) ActivePower() PowerPair {
+	return s.LivePower.Sub(s.FaultyPower)
+}
+
+// Checks internal invariants of a miner's state, given the actor's balance, returning a summary of the state and the
+// violations found. The summary may be incomplete if violations were found.
+// Unlike the assertions made by the actor, violations do not panic, so the check may be run against any state.
+// The check loads all of the miner's state, so is expensive for miners with many sectors.
+func CheckStateInvariants(store adt.Store, st *State, balance abi.TokenAmount) (*StateSummary, *builtin.MessageAccumulator) {
+	acc := &builtin.MessageAccumulator{}
+	summary := &StateSummary{
+		LivePower:   NewPowerPairZero(),
+		FaultyPower: NewPowerPairZero(),
+		Deals:       map[abi.DealID]SectorDealSummary{},
+	}
+
+	acc.Require(st.PreCommitDeposits.GreaterThanEqual(big.Zero()), "negative pre-commit deposits %v", st.PreCommitDeposits)
+	acc.Require(st.LockedFunds.GreaterThanEqual(big.Zero()), "negative locked funds %v", st.LockedFunds)
+	acc.Require(st.InitialPledgeRequirement.GreaterThanEqual(big.Zero()), "negative initial pledge requirement %v", st.InitialPledgeRequirement)
+	acc.Require(balance.GreaterThanEqual(big.Add(st.PreCommitDeposits, st.LockedFunds)),
+		"balance %v below pre-commit deposits %v plus locked funds %v", balance, st.PreCommitDeposits, st.LockedFunds)
+
+	info, err := st.GetInfo(store)
+	if !acc.RequireNoError(err, "failed to load info") {
+		return summary, acc
+	}
+	sectorSize := info.SectorSize
+
+	var allocated bitfield.BitField
+	if err := store.Get(store.Context(), st.AllocatedSectors, &allocated); !acc.RequireNoError(err, "failed to load allocated sectors") {
+		return summary, acc
+	}
+
+	sectors, ok := checkSectors(acc, store, st, allocated)
+	if !ok {
+		return summary, acc
+	}
+	checkPreCommits(acc, store, st, allocated, sectors)
+	checkDeadlines(acc, store, st, sectorSize, sectors, summary)
+	checkVesting(acc, store, st)
+	return summary, acc
+}
+
+// Loads all sector infos, checking each has been allocated.
+func checkSectors(acc *builtin.MessageAccumulator, store adt.Store, st *State, allocated bitfield.BitField) (map[abi.SectorNumber]*SectorOnChainInfo, bool) {
+	sectors := map[abi.SectorNumber]*SectorOnChainInfo{}
+	if err := st.ForEachSector(store, func(sector *SectorOnChainInfo) {
+		info := *sector
+		sectors[info.SectorNumber] = &info
+	}); !acc.RequireNoError(err, "failed to load sectors") {
+		return nil, false
+	}
+
+	var numbers []uint64
+	for sno := range sectors { //nolint:nomaprange
+		numbers = append(numbers, uint64(sno))
+	}
+	checkAllocated(acc, allocated, bitfield.NewFromSet(numbers), "sectors")
+	return sectors, true
+}
+
+// Checks that pre-committed sectors are allocated, not yet proven, scheduled for expiry exactly once, and that their
+// deposits sum to the state's total.
+func checkPreCommits(acc *builtin.MessageAccumulator, store adt.Store, st *State, allocated bitfield.BitField,
+	sectors map[abi.SectorNumber]*SectorOnChainInfo) {
+	precommitted, err := adt.AsMap(store, st.PreCommittedSectors)
+	if !acc.RequireNoError(err, "failed to load pre-committed sectors") {
+		return
+	}
+	deposits := big.Zero()
+	var numbers []uint64
+	var precommit SectorPreCommitOnChainInfo
+	if err = precommitted.ForEach(&precommit, func(key string) error {
+		sno := precommit.Info.SectorNumber
+		keyNo, err := adt.ParseUIntKey(key)
+		if err != nil {
+			return err
+		}
+		acc.Require(keyNo == uint64(sno), "pre-committed sector %d stored under key %d", sno, keyNo)
+		_, proven := sectors[sno]
+		acc.Require(!proven, "pre-committed sector %d is also proven", sno)
+		deposits = big.Add(deposits, precommit.PreCommitDeposit)
+		numbers = append(numbers, uint64(sno))
+		return nil
+	}); !acc.RequireNoError(err, "failed to iterate pre-committed sectors") {
+		return
+	}
+	acc.Require(deposits.Equals(st.PreCommitDeposits), "pre-commit deposits %v differ from sum of pre-commits %v",
+		st.PreCommitDeposits, deposits)
+	checkAllocated(acc, allocated, bitfield.NewFromSet(numbers), "pre-committed sectors")
+
+	// The expiry queue retains the numbers of sectors that have since been proven, so only presence is checked.
+	expiryQ, err := LoadBitfieldQueue(store, st.PreCommittedSectorsExpiry, st.QuantSpecEveryDeadline())
+	if !acc.RequireNoError(err, "failed to load pre-commit expiry queue") {
+		return
+	}
+	scheduled := map[uint64]int{}
+	if err = expiryQ.ForEach(func(epoch abi.ChainEpoch, bf bitfield.BitField) error {
+		return bf.ForEach(func(sno uint64) error {
+			scheduled[sno]++
+			return nil
+		})
+	}); !acc.RequireNoError(err, "failed to iterate pre-commit expiry queue") {
+		return
+	}
+	for _, sno := range numbers {
+		acc.Require(scheduled[sno] == 1, "pre-committed sector %d scheduled for expiry %d times", sno, scheduled[sno])
+	}
+}
+
+// Checks each partition's sector sets, power and expiration queue against the sector infos, and each deadline's
+// totals against its partitions, adding the partitions' power and deals to the summary.
+func checkDeadlines(acc *builtin.MessageAccumulator, store adt.Store, st *State, sectorSize abi.SectorSize,
+	sectors map[abi.SectorNumber]*SectorOnChainInfo, summary *StateSummary) {
+	deadlines, err := st.LoadDeadlines(store)
+	if !acc.RequireNoError(err, "failed to load deadlines") {
+		return
+	}
+	// The partition in which each sector has been seen, to detect sectors assigned more than once.
+	seen := map[uint64]string{}
+	err = deadlines.ForEach(store, func(dlIdx uint64, dl *Deadline) error {
+		dlAcc := acc.WithPrefix("deadline %d: ", dlIdx)
+		partitions, err := dl.PartitionsArray(store)
+		if !dlAcc.RequireNoError(err, "failed to load partitions") {
+			return nil
+		}
+		liveCount, totalCount := uint64(0), uint64(0)
+		faultyPower := NewPowerPairZero()
+		var partition Partition
+		err = partitions.ForEach(&partition, func(partIdx int64) error {
+			partAcc := dlAcc.WithPrefix("partition %d: ", partIdx)
+			live, total := checkPartition(partAcc, store, &partition, st.QuantSpecForDeadline(dlIdx), sectorSize, sectors, summary)
+			liveCount += live
+			totalCount += total
+			faultyPower = faultyPower.Add(partition.FaultyPower)
+			summary.LivePower = summary.LivePower.Add(partition.LivePower)
+			summary.FaultyPower = summary.FaultyPower.Add(partition.FaultyPower)
+
+			location := fmt.Sprintf("deadline %d partition %d", dlIdx, partIdx)
+			return partition.Sectors.ForEach(func(sno uint64) error {
+				if prev, ok := seen[sno]; ok {
+					partAcc.Addf("sector %d also assigned to %s", sno, prev)
+				}
+				seen[sno] = location
+				return nil
+			})
+		})
+		if !dlAcc.RequireNoError(err, "failed to iterate partitions") {
+			return nil
+		}
+		dlAcc.Require(dl.LiveSectors == liveCount, "live sectors %d differ from partitions' %d", dl.LiveSectors, liveCount)
+		dlAcc.Require(dl.TotalSectors == totalCount, "total sectors %d differ from partitions' %d", dl.TotalSectors, totalCount)
+		dlAcc.Require(dl.FaultyPower.Equals(faultyPower), "faulty power %v differs from partitions' %v", dl.FaultyPower, faultyPower)
+		return nil
+	})
+	acc.RequireNoError(err, "failed to iterate deadlines")
+}
+
+// Checks a partition's sector sets, power and expiration queue, returning its numbers of live and total sectors.
+// The deals of live sectors are added to the summary.
+func checkPartition(acc *builtin.MessageAccumulator, store adt.Store, partition *Partition, quant QuantSpec,
+	sectorSize abi.SectorSize, sectors map[abi.SectorNumber]*SectorOnChainInfo, summary *StateSummary) (uint64, uint64) {
+	requireSubset(acc, partition.Sectors, partition.Faults, "faults", "sectors")
+	requireSubset(acc, partition.Sectors, partition.Recoveries, "recoveries", "sectors")
+	requireSubset(acc, partition.Sectors, partition.Terminated, "terminated", "sectors")
+	requireSubset(acc, partition.Faults, partition.Recoveries, "recoveries", "faults")
+	overlap, err := abi.BitFieldContainsAny(partition.Faults, partition.Terminated)
+	if acc.RequireNoError(err, "failed to intersect faults with terminated") {
+		acc.Require(!overlap, "faults include terminated sectors")
+	}
+
+	live, err := partition.LiveSectors()
+	if !acc.RequireNoError(err, "failed to compute live sectors") {
+		return 0, 0
+	}
+	liveCount, err := live.Count()
+	if !acc.RequireNoError(err, "failed to count live sectors") {
+		return 0, 0
+	}
+	totalCount, err := partition.Sectors.Count()
+	if !acc.RequireNoError(err, "failed to count sectors") {
+		return 0, 0
+	}
+
+	// Terminated sectors' infos may have been removed, but every live sector must have one.
+	selectInfos := func(sectorNos bitfield.BitField) []*SectorOnChainInfo {
+		var infos []*SectorOnChainInfo
+		err := sectorNos.ForEach(func(sno uint64) error {
+			if info, ok := sectors[abi.SectorNumber(sno)]; ok {
+				infos = append(infos, info)
+			}
+			return nil
+		})
+		acc.RequireNoError(err, "failed to iterate sector numbers")
+		return infos
+	}
+	if err = live.ForEach(func(sno uint64) error {
+		info, ok := sectors[abi.SectorNumber(sno)]
+		if !ok {
+			acc.Addf("live sector %d has no sector info", sno)
+			return nil
+		}
+		for _, dealID := range info.DealIDs {
+			if prev, ok := summary.Deals[dealID]; ok {
+				acc.Addf("deal %d in sector %d also in sector %d", dealID, sno, prev.Sector)
+			}
+			summary.Deals[dealID] = SectorDealSummary{
+				Sector:           info.SectorNumber,
+				SectorStart:      info.Activation,
+				SectorExpiration: info.Expiration,
+			}
+		}
+		return nil
+	}); !acc.RequireNoError(err, "failed to iterate live sectors") {
+		return liveCount, totalCount
+	}
+
+	livePower := PowerForSectors(sectorSize, selectInfos(live))
+	acc.Require(partition.LivePower.Equals(livePower), "live power %v differs from sectors' %v", partition.LivePower, livePower)
+	faultyPower := PowerForSectors(sectorSize, selectInfos(partition.Faults))
+	acc.Require(partition.FaultyPower.Equals(faultyPower), "faulty power %v differs from sectors' %v", partition.FaultyPower, faultyPower)
+	recoveringPower := PowerForSectors(sectorSize, selectInfos(partition.Recoveries))
+	acc.Require(partition.RecoveringPower.Equals(recoveringPower), "recovering power %v differs from sectors' %v",
+		partition.RecoveringPower, recoveringPower)
+
+	// Every live sector is scheduled to expire exactly once, either on time or early if faulty.
+	expQ, err := LoadExpirationQueue(store, partition.ExpirationsEpochs, quant)
+	if !acc.RequireNoError(err, "failed to load expiration queue") {
+		return liveCount, totalCount
+	}
+	scheduled := map[uint64]int{}
+	var exp ExpirationSet
+	if err = expQ.ForEach(&exp, func(epoch int64) error {
+		requireSubset(acc, partition.Faults, exp.EarlySectors, fmt.Sprintf("early expirations at %d", epoch), "faults")
+		for _, set := range []bitfield.BitField{exp.OnTimeSectors, exp.EarlySectors} {
+			if err := set.ForEach(func(sno uint64) error {
+				scheduled[sno]++
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); !acc.RequireNoError(err, "failed to iterate expiration queue") {
+		return liveCount, totalCount
+	}
+	if err = live.ForEach(func(sno uint64) error {
+		acc.Require(scheduled[sno] == 1, "live sector %d scheduled to expire %d times", sno, scheduled[sno])
+		delete(scheduled, sno)
+		return nil
+	}); !acc.RequireNoError(err, "failed to iterate live sectors") {
+		return liveCount, totalCount
+	}
+	var unexpected []uint64
+	for sno := range scheduled { //nolint:nomaprange
+		unexpected = append(unexpected, sno)
+	}
+	if len(unexpected) > 0 {
+		acc.Addf("expiration queue includes sectors %v that are not live", sortedUint64s(unexpected))
+	}
+	return liveCount, totalCount
+}
+
+// Checks that the vesting table sums to the locked funds.
+func checkVesting(acc *builtin.MessageAccumulator, store adt.Store, st *State) {
+	vestingFunds, err := adt.AsArray(store, st.VestingFunds)
+	if !acc.RequireNoError(err, "failed to load vesting funds") {
+		return
+	}
+	total := big.Zero()
+	var amount abi.TokenAmount
+	if err = vestingFunds.ForEach(&amount, func(epoch int64) error {
+		acc.Require(amount.GreaterThan(big.Zero()), "vesting amount %v at epoch %d is not positive", amount, epoch)
+		total = big.Add(total, amount)
+		return nil
+	}); !acc.RequireNoError(err, "failed to iterate vesting funds") {
+		return
+	}
+	acc.Require(total.Equals(st.LockedFunds), "locked funds %v differ from vesting total %v", st.LockedFunds, total)
+}
+
+func checkAllocated(acc *builtin.MessageAccumulator, allocated, sectorNos bitfield.BitField, name string) {
+	requireSubset(acc, allocated, sectorNos, name, "allocated sectors")
+}
+
+// Adds a message if the subset is not contained in the superset.
+func requireSubset(acc *builtin.MessageAccumulator, superset, subset bitfield.BitField, subsetName, supersetName string) {
+	excess, err := bitfield.SubtractBitField(subset, superset)
+	if !acc.RequireNoError(err, "failed to compare %s with %s", subsetName, supersetName) {
+		return
+	}
+	empty, err := excess.IsEmpty()
+	if !acc.RequireNoError(err, "failed to compare %s with %s", subsetName, supersetName) || empty {
+		return
+	}
+	sectorNos, err := excess.All(AddressedSectorsMax)
+	if acc.RequireNoError(err, "failed to expand %s not in %s", subsetName, supersetName) {
+		acc.Addf("%s %v not in %s", subsetName, sectorNos, supersetName)
+	}
+}
+
+func sortedUint64s(values []uint64) []uint64 {
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	return values
+}
@@ -0,0 +1,167 @@
+package miner_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/filecoin-project/go-bitfield"
+	cid "github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/actors/util/adt"
+	tutils "github.com/filecoin-project/specs-actors/support/testing"
+)
+
+func TestCheckStateInvariants(t *testing.T) {
+	balance := abi.NewTokenAmount(1_000_000)
+
+	setup := func(t *testing.T) *stateHarness {
+		h := constructStateHarness(t, abi.ChainEpoch(0))
+		info, err := h.s.GetInfo(h.store)
+		require.NoError(t, err)
+
+		var sectors []*miner.SectorOnChainInfo
+		for i := 1; i <= 6; i++ {
+			sector := newSectorOnChainInfo(abi.SectorNumber(i), tutils.MakeCID(fmt.Sprintf("%d", i), &miner.SealedCIDPrefix), big.NewInt(1), 0)
+			sector.Expiration = abi.ChainEpoch(1000 * i)
+			sector.DealIDs = []abi.DealID{abi.DealID(100 + i)}
+			require.NoError(t, h.s.AllocateSectorNumber(h.store, sector.SectorNumber))
+			sectors = append(sectors, sector)
+		}
+		require.NoError(t, h.s.PutSectors(h.store, sectors...))
+		_, err = h.s.AssignSectorsToDeadlines(h.store, 0, sectors, info.WindowPoStPartitionSectors, info.SectorSize)
+		require.NoError(t, err)
+
+		precommit := newSectorPreCommitOnChainInfo(10, tutils.MakeCID("10", &miner.SealedCIDPrefix), abi.NewTokenAmount(5), 0)
+		require.NoError(t, h.s.AllocateSectorNumber(h.store, 10))
+		h.putPreCommit(precommit)
+		h.s.AddPreCommitDeposit(precommit.PreCommitDeposit)
+		require.NoError(t, h.s.AddPreCommitExpiry(h.store, 500, 10))
+
+		h.addLockedFunds(0, abi.NewTokenAmount(1000), &miner.RewardVestingSpec)
+		return h
+	}
+
+	check := func(h *stateHarness) *builtin.MessageAccumulator {
+		_, acc := miner.CheckStateInvariants(h.store, h.s, balance)
+		return acc
+	}
+
+	t.Run("consistent state has no violations", func(t *testing.T) {
+		h := setup(t)
+		summary, acc := miner.CheckStateInvariants(h.store, h.s, balance)
+		tutils.AssertNoMsgs(t, acc)
+		assert.False(t, summary.LivePower.IsZero())
+		assert.True(t, summary.FaultyPower.IsZero())
+		assert.Equal(t, summary.LivePower, summary.ActivePower())
+		assert.Len(t, summary.Deals, 6)
+		assert.Equal(t, miner.SectorDealSummary{Sector: 2, SectorStart: 0, SectorExpiration: 2000}, summary.Deals[102])
+	})
+
+	t.Run("insufficient balance", func(t *testing.T) {
+		h := setup(t)
+		_, acc := miner.CheckStateInvariants(h.store, h.s, big.Zero())
+		tutils.AssertMsgContains(t, acc, "balance 0 below pre-commit deposits 5 plus locked funds 1000")
+	})
+
+	t.Run("faults not in partition", func(t *testing.T) {
+		h := setup(t)
+		h.updatePartition(1, func(p *miner.Partition) {
+			p.Faults = bitfield.NewFromSet([]uint64{99})
+		})
+		tutils.AssertMsgContains(t, check(h), "partition 0: faults [99] not in sectors")
+	})
+
+	t.Run("recoveries not faulty", func(t *testing.T) {
+		h := setup(t)
+		h.updatePartition(1, func(p *miner.Partition) {
+			p.Recoveries = bitfield.NewFromSet([]uint64{1})
+		})
+		tutils.AssertMsgContains(t, check(h), "recoveries [1] not in faults")
+	})
+
+	t.Run("partition power differs from sectors", func(t *testing.T) {
+		h := setup(t)
+		h.updatePartition(1, func(p *miner.Partition) {
+			p.LivePower = p.LivePower.Add(miner.NewPowerPair(big.NewInt(1), big.NewInt(1)))
+		})
+		tutils.AssertMsgContains(t, check(h), "live power")
+	})
+
+	t.Run("live sector missing from expiration queue", func(t *testing.T) {
+		h := setup(t)
+		h.updatePartition(1, func(p *miner.Partition) {
+			p.ExpirationsEpochs = h.emptyArray()
+		})
+		tutils.AssertMsgContains(t, check(h), "live sector 1 scheduled to expire 0 times")
+	})
+
+	t.Run("deal in more than one sector", func(t *testing.T) {
+		h := setup(t)
+		sector := newSectorOnChainInfo(2, tutils.MakeCID("2", &miner.SealedCIDPrefix), big.NewInt(1), 0)
+		sector.Expiration = 2000
+		sector.DealIDs = []abi.DealID{101, 102}
+		require.NoError(t, h.s.PutSectors(h.store, sector))
+		tutils.AssertMsgContains(t, check(h), "deal 101 in sector 2 also in sector 1")
+	})
+
+	t.Run("sector not allocated", func(t *testing.T) {
+		h := setup(t)
+		emptyBitfield, err := h.store.Put(h.store.Context(), bitfield.New())
+		require.NoError(t, err)
+		h.s.AllocatedSectors = emptyBitfield
+		tutils.AssertMsgContains(t, check(h), "sectors [1 2 3 4 5 6] not in allocated sectors")
+		tutils.AssertMsgContains(t, check(h), "pre-committed sectors [10] not in allocated sectors")
+	})
+
+	t.Run("pre-commit not scheduled for expiry", func(t *testing.T) {
+		h := setup(t)
+		h.s.PreCommittedSectorsExpiry = h.emptyArray()
+		tutils.AssertMsgContains(t, check(h), "pre-committed sector 10 scheduled for expiry 0 times")
+	})
+
+	t.Run("pre-commit deposits differ", func(t *testing.T) {
+		h := setup(t)
+		h.s.AddPreCommitDeposit(abi.NewTokenAmount(1))
+		tutils.AssertMsgContains(t, check(h), "pre-commit deposits 6 differ from sum of pre-commits 5")
+	})
+
+	t.Run("locked funds differ from vesting table", func(t *testing.T) {
+		h := setup(t)
+		h.s.LockedFunds = abi.NewTokenAmount(999)
+		tutils.AssertMsgContains(t, check(h), "locked funds 999 differ from vesting total 1000")
+	})
+}
+
+func (h *stateHarness) emptyArray() cid.Cid {
+	root, err := adt.MakeEmptyArray(h.store).Root()
+	require.NoError(h.t, err)
+	return root
+}
+
+// Replaces the partition containing a sector with the result of a mutation.
+func (h *stateHarness) updatePartition(sno abi.SectorNumber, mutate func(p *miner.Partition)) {
+	dlIdx, partIdx, err := h.s.FindSector(h.store, sno)
+	require.NoError(h.t, err)
+	deadlines, err := h.s.LoadDeadlines(h.store)
+	require.NoError(h.t, err)
+	dl, err := deadlines.LoadDeadline(h.store, dlIdx)
+	require.NoError(h.t, err)
+	partition, err := dl.LoadPartition(h.store, partIdx)
+	require.NoError(h.t, err)
+
+	mutate(partition)
+
+	partitions, err := dl.PartitionsArray(h.store)
+	require.NoError(h.t, err)
+	require.NoError(h.t, partitions.Set(partIdx, partition))
+	dl.Partitions, err = partitions.Root()
+	require.NoError(h.t, err)
+	require.NoError(h.t, deadlines.UpdateDeadline(h.store, dlIdx, dl))
+	require.NoError(h.t, h.s.SaveDeadlines(h.store, deadlines))
+}
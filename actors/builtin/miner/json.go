@@ -0,0 +1,113 @@
+package miner
+
+import (
+	"encoding/json"
+
+	"github.com/filecoin-project/go-bitfield"
+)
+
+// Bitfields encode to JSON as run lengths, starting with a (possibly zero-length) run of unset bits.
+// A bitfield decoded from JSON by go-bitfield holds its runs but not their RLE+ encoding, and so would subsequently
+// encode to CBOR as empty. The types below containing bitfields re-encode them after decoding.
+
+func (st *State) UnmarshalJSON(b []byte) error {
+	type state State // Without methods, to avoid recursion.
+	if err := json.Unmarshal(b, (*state)(st)); err != nil {
+		return err
+	}
+	return reencodeBitFields(&st.EarlyTerminations)
+}
+
+func (dl *Deadline) UnmarshalJSON(b []byte) error {
+	type deadline Deadline
+	if err := json.Unmarshal(b, (*deadline)(dl)); err != nil {
+		return err
+	}
+	return reencodeBitFields(&dl.PostSubmissions, &dl.EarlyTerminations)
+}
+
+func (p *Partition) UnmarshalJSON(b []byte) error {
+	type partition Partition
+	if err := json.Unmarshal(b, (*partition)(p)); err != nil {
+		return err
+	}
+	return reencodeBitFields(&p.Sectors, &p.Faults, &p.Recoveries, &p.Terminated)
+}
+
+func (es *ExpirationSet) UnmarshalJSON(b []byte) error {
+	type expirationSet ExpirationSet
+	if err := json.Unmarshal(b, (*expirationSet)(es)); err != nil {
+		return err
+	}
+	return reencodeBitFields(&es.OnTimeSectors, &es.EarlySectors)
+}
+
+func (p *PoStPartition) UnmarshalJSON(b []byte) error {
+	type postPartition PoStPartition
+	if err := json.Unmarshal(b, (*postPartition)(p)); err != nil {
+		return err
+	}
+	return reencodeBitFields(&p.Skipped)
+}
+
+func (e *ExpirationExtension) UnmarshalJSON(b []byte) error {
+	type expirationExtension ExpirationExtension
+	if err := json.Unmarshal(b, (*expirationExtension)(e)); err != nil {
+		return err
+	}
+	return reencodeBitFields(&e.Sectors)
+}
+
+func (d *TerminationDeclaration) UnmarshalJSON(b []byte) error {
+	type terminationDeclaration TerminationDeclaration
+	if err := json.Unmarshal(b, (*terminationDeclaration)(d)); err != nil {
+		return err
+	}
+	return reencodeBitFields(&d.Sectors)
+}
+
+func (d *FaultDeclaration) UnmarshalJSON(b []byte) error {
+	type faultDeclaration FaultDeclaration
+	if err := json.Unmarshal(b, (*faultDeclaration)(d)); err != nil {
+		return err
+	}
+	return reencodeBitFields(&d.Sectors)
+}
+
+func (d *RecoveryDeclaration) UnmarshalJSON(b []byte) error {
+	type recoveryDeclaration RecoveryDeclaration
+	if err := json.Unmarshal(b, (*recoveryDeclaration)(d)); err != nil {
+		return err
+	}
+	return reencodeBitFields(&d.Sectors)
+}
+
+func (p *CompactPartitionsParams) UnmarshalJSON(b []byte) error {
+	type compactPartitionsParams CompactPartitionsParams
+	if err := json.Unmarshal(b, (*compactPartitionsParams)(p)); err != nil {
+		return err
+	}
+	return reencodeBitFields(&p.Partitions)
+}
+
+func (p *CompactSectorNumbersParams) UnmarshalJSON(b []byte) error {
+	type compactSectorNumbersParams CompactSectorNumbersParams
+	if err := json.Unmarshal(b, (*compactSectorNumbersParams)(p)); err != nil {
+		return err
+	}
+	return reencodeBitFields(&p.MaskSectorNumbers)
+}
+
+// Replaces each bitfield with one encoded from its runs.
+func reencodeBitFields(bfs ...*bitfield.BitField) error {
+	for _, bf := range bfs {
+		runs, err := bf.RunIterator()
+		if err != nil {
+			return err
+		}
+		if *bf, err = bitfield.NewFromIter(runs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
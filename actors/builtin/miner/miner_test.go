@@ -260,6 +260,7 @@ func TestCommitments(t *testing.T) {
 		assert.Equal(t, expectedInitialPledge, entry.OnTimePledge)
 		assert.Equal(t, sectorPower, entry.ActivePower)
 		assert.Equal(t, miner.NewPowerPairZero(), entry.FaultyPower)
+		actor.checkState(rt)
 	})
 
 	t.Run("invalid pre-commit rejected", func(t *testing.T) {
@@ -468,6 +469,7 @@ func TestCommitments(t *testing.T) {
 		// Old sector gone from pledge requirement and deposit
 		assert.Equal(t, st.InitialPledgeRequirement, newSector.InitialPledge)
 		assert.Equal(t, st.LockedFunds, big.Mul(big.NewInt(4), faultPenalty)) // from manual fund addition above - 1 fault penalty
+		actor.checkState(rt)
 	})
 
 	t.Run("invalid committed capacity upgrade rejected", func(t *testing.T) {
@@ -835,6 +837,7 @@ func TestWindowPost(t *testing.T) {
 
 		expectedBalance := big.Sub(initialLocked, recoveryFee)
 		assert.Equal(t, expectedBalance, actor.getLockedFunds(rt))
+		actor.checkState(rt)
 	})
 
 	t.Run("skipped faults are penalized and adjust power", func(t *testing.T) {
@@ -904,6 +907,7 @@ func TestWindowPost(t *testing.T) {
 
 		// expect ongoing fault from both sectors
 		advanceDeadline(rt, actor, &cronConfig{ongoingFaultsPenalty: actor.declaredFaultPenalty(infos)})
+		actor.checkState(rt)
 	})
 
 	t.Run("skipped all sectors in a deadline may be skipped", func(t *testing.T) {
@@ -1211,6 +1215,7 @@ func TestDeadlineCron(t *testing.T) {
 		deadline = actor.getDeadline(rt, dlIdx)
 		assert.True(t, pwr.Equals(deadline.FaultyPower))
 		checkDeadlineInvariants(t, rt.AdtStore(), deadline, st.QuantSpecForDeadline(dlIdx), actor.sectorSize, uint64(4), allSectors)
+		actor.checkState(rt)
 	})
 
 	t.Run("test cron run late", func(t *testing.T) {
@@ -1301,6 +1306,7 @@ func TestDeclareFaults(t *testing.T) {
 		advanceDeadline(rt, actor, &cronConfig{
 			ongoingFaultsPenalty: ongoingPenalty,
 		})
+		actor.checkState(rt)
 	})
 }
 
@@ -1544,6 +1550,7 @@ func TestExtendSectorExpiration(t *testing.T) {
 			}))
 			assert.EqualValues(t, sectorCount/2, extendedTotal)
 		}
+		actor.checkState(rt)
 	})
 
 	t.Run("supports extensions off deadline boundary", func(t *testing.T) {
@@ -1653,6 +1660,7 @@ func TestTerminateSectors(t *testing.T) {
 			// expect pledge requirement to have been decremented
 			assert.Equal(t, big.Zero(), st.InitialPledgeRequirement)
 		}
+		actor.checkState(rt)
 	})
 }
 
@@ -1905,6 +1913,7 @@ func TestAddLockedFund(t *testing.T) {
 		})
 		require.NoError(t, err)
 		assert.Equal(t, amt, st.LockedFunds)
+		actor.checkState(rt)
 
 	})
 
@@ -2156,6 +2165,12 @@ func (h *actorHarness) collectPartitionExpirations(rt *mock.Runtime, partition *
 	return expirations
 }
 
+func (h *actorHarness) checkState(rt *mock.Runtime) {
+	st := getState(rt)
+	_, acc := miner.CheckStateInvariants(rt.AdtStore(), st, rt.Balance())
+	assert.Empty(h.t, acc.Messages(), "miner state invariants violated")
+}
+
 func (h *actorHarness) getLockedFunds(rt *mock.Runtime) abi.TokenAmount {
 	st := getState(rt)
 	return st.LockedFunds
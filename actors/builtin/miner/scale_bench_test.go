@@ -0,0 +1,401 @@
+package miner_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/filecoin-project/go-bitfield"
+	cid "github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/support/ipld"
+	tutil "github.com/filecoin-project/specs-actors/support/testing"
+)
+
+// Benchmarks of miner state operations on miners of realistic size.
+// Each reports the store I/O per operation alongside its time. Sizes beyond the smallest are skipped with -short,
+// since building their state is slow. The state of each size is built once and shared by all benchmarks.
+//
+// go test ./actors/builtin/miner -run XXX -bench Scale -benchtime 10x
+
+var scaleSectorCounts = []int{10_000, 100_000, 1_000_000}
+
+const scaleSealProof = abi.RegisteredSealProof_StackedDrg32GiBV1
+
+func BenchmarkScaleAssignSectorsToDeadlines(b *testing.B) {
+	const newSectors = 1000
+	forEachScale(b, func(b *testing.B, f *scaleFixture) {
+		next := abi.SectorNumber(f.sectorCount)
+		io := ioCounter{store: f.store}
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			st := f.loadState(b)
+			sectors := make([]*miner.SectorOnChainInfo, newSectors)
+			for j := range sectors {
+				sectors[j] = scaleSector(next)
+				next++
+			}
+			io.start()
+			b.StartTimer()
+
+			_, err := st.AssignSectorsToDeadlines(f.store, 0, sectors, f.partitionSize, f.sectorSize)
+			require.NoError(b, err)
+
+			b.StopTimer()
+			io.stop()
+		}
+		io.report(b)
+	})
+}
+
+func BenchmarkScaleDeclareFaults(b *testing.B) {
+	forEachScale(b, func(b *testing.B, f *scaleFixture) {
+		io := ioCounter{store: f.store}
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			st := f.loadState(b)
+			dl := f.loadDeadline(b, st, 0)
+			sectors, err := miner.LoadSectors(f.store, st.Sectors)
+			require.NoError(b, err)
+			toFault := f.partitionSectors(b, dl)
+			io.start()
+			b.StartTimer()
+
+			_, err = dl.DeclareFaults(f.store, sectors, f.sectorSize, st.QuantSpecForDeadline(0), miner.FaultMaxAge, toFault)
+			require.NoError(b, err)
+
+			b.StopTimer()
+			io.stop()
+		}
+		io.report(b)
+	})
+}
+
+func BenchmarkScaleRecordProvenSectors(b *testing.B) {
+	forEachScale(b, func(b *testing.B, f *scaleFixture) {
+		faulty := f.faultyState(b)
+		io := ioCounter{store: f.store}
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			var st miner.State
+			require.NoError(b, f.store.Get(context.Background(), faulty, &st))
+			dl := f.loadDeadline(b, &st, 0)
+			sectors, err := miner.LoadSectors(f.store, st.Sectors)
+			require.NoError(b, err)
+
+			// Every partition is proven, recovering its recovering sectors, but skips every seventh sector,
+			// which becomes faulty (or fails to recover).
+			var postPartitions []miner.PoStPartition
+			for partIdx, skipped := range f.selectSectors(b, dl, 7) { //nolint:nomaprange
+				postPartitions = append(postPartitions, miner.PoStPartition{Index: partIdx, Skipped: skipped})
+			}
+			io.start()
+			b.StartTimer()
+
+			_, err = dl.RecordProvenSectors(f.store, sectors, f.sectorSize, st.QuantSpecForDeadline(0), miner.FaultMaxAge, postPartitions)
+			require.NoError(b, err)
+
+			b.StopTimer()
+			io.stop()
+		}
+		io.report(b)
+	})
+}
+
+func BenchmarkScalePopEarlyTerminations(b *testing.B) {
+	forEachScale(b, func(b *testing.B, f *scaleFixture) {
+		terminated := f.terminatedState(b)
+		io := ioCounter{store: f.store}
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			var st miner.State
+			require.NoError(b, f.store.Get(context.Background(), terminated, &st))
+			io.start()
+			b.StartTimer()
+
+			_, _, err := st.PopEarlyTerminations(f.store, miner.AddressedPartitionsMax, miner.AddressedSectorsMax)
+			require.NoError(b, err)
+
+			b.StopTimer()
+			io.stop()
+		}
+		io.report(b)
+	})
+}
+
+func BenchmarkScaleProcessDeadlineEnd(b *testing.B) {
+	forEachScale(b, func(b *testing.B, f *scaleFixture) {
+		io := ioCounter{store: f.store}
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			st := f.loadState(b)
+			dl := f.loadDeadline(b, st, 0)
+			io.start()
+			b.StartTimer()
+
+			// No partitions were proven, so all the deadline's sectors become faulty.
+			_, _, err := dl.ProcessDeadlineEnd(f.store, st.QuantSpecForDeadline(0), miner.FaultMaxAge)
+			require.NoError(b, err)
+
+			b.StopTimer()
+			io.stop()
+		}
+		io.report(b)
+	})
+}
+
+func BenchmarkScaleExpirationQueuePopUntil(b *testing.B) {
+	forEachScale(b, func(b *testing.B, f *scaleFixture) {
+		io := ioCounter{store: f.store}
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			st := f.loadState(b)
+			dl := f.loadDeadline(b, st, 0)
+			partition, err := dl.LoadPartition(f.store, 0)
+			require.NoError(b, err)
+			queue, err := miner.LoadExpirationQueue(f.store, partition.ExpirationsEpochs, st.QuantSpecForDeadline(0))
+			require.NoError(b, err)
+			io.start()
+			b.StartTimer()
+
+			// Pops about half of the sectors' expirations.
+			_, err = queue.PopUntil(scaleExpiration(scaleExpirationSpread / 2))
+			require.NoError(b, err)
+
+			b.StopTimer()
+			io.stop()
+		}
+		io.report(b)
+	})
+}
+
+//
+// Fixtures
+//
+
+// A miner with sectors assigned to all deadlines, held in an instrumented store.
+type scaleFixture struct {
+	sectorCount   int
+	store         *ipld.InstrumentedStore
+	state         cid.Cid
+	terminated    cid.Cid // The state after terminating a tenth of the sectors of deadline 0, built on demand.
+	faulty        cid.Cid // The state with faulty and recovering sectors in deadline 0, built on demand.
+	partitionSize uint64
+	sectorSize    abi.SectorSize
+}
+
+var scaleFixtures = map[int]*scaleFixture{}
+
+func forEachScale(b *testing.B, bench func(b *testing.B, f *scaleFixture)) {
+	for i, count := range scaleSectorCounts {
+		b.Run(fmt.Sprintf("sectors=%d", count), func(b *testing.B) {
+			if i > 0 && testing.Short() {
+				b.Skip("skipping large miner in short mode")
+			}
+			f, ok := scaleFixtures[count]
+			if !ok {
+				f = newScaleFixture(b, count)
+				scaleFixtures[count] = f
+			}
+			b.ResetTimer()
+			bench(b, f)
+		})
+	}
+}
+
+func newScaleFixture(b *testing.B, sectorCount int) *scaleFixture {
+	ctx := context.Background()
+	store := ipld.NewInstrumentedStore(ipld.NewADTStore(ctx))
+
+	emptyMap, err := adt.MakeEmptyMap(store).Root()
+	require.NoError(b, err)
+	emptyArray, err := adt.MakeEmptyArray(store).Root()
+	require.NoError(b, err)
+	emptyBitfield, err := store.Put(ctx, bitfield.New())
+	require.NoError(b, err)
+	emptyDeadline, err := store.Put(ctx, miner.ConstructDeadline(emptyArray))
+	require.NoError(b, err)
+	emptyDeadlines, err := store.Put(ctx, miner.ConstructDeadlines(emptyDeadline))
+	require.NoError(b, err)
+
+	sectorSize, err := scaleSealProof.SectorSize()
+	require.NoError(b, err)
+	partitionSize, err := scaleSealProof.WindowPoStPartitionSectors()
+	require.NoError(b, err)
+	info, err := miner.ConstructMinerInfo(tutil.NewIDAddr(b, 100), tutil.NewIDAddr(b, 101), abi.PeerID("peer"), nil, scaleSealProof)
+	require.NoError(b, err)
+	infoCid, err := store.Put(ctx, info)
+	require.NoError(b, err)
+
+	st, err := miner.ConstructState(infoCid, 0, emptyBitfield, emptyArray, emptyMap, emptyDeadlines)
+	require.NoError(b, err)
+
+	sectors := make([]*miner.SectorOnChainInfo, sectorCount)
+	for i := range sectors {
+		sectors[i] = scaleSector(abi.SectorNumber(i))
+	}
+	require.NoError(b, st.PutSectors(store, sectors...))
+
+	// The deadlines near the current one can't be assigned to, so assign half the sectors at each of two epochs
+	// half a proving period apart to fill all deadlines.
+	half := sectorCount / 2
+	_, err = st.AssignSectorsToDeadlines(store, 0, sectors[:half], partitionSize, sectorSize)
+	require.NoError(b, err)
+	_, err = st.AssignSectorsToDeadlines(store, miner.WPoStProvingPeriod/2, sectors[half:], partitionSize, sectorSize)
+	require.NoError(b, err)
+
+	root, err := store.Put(ctx, st)
+	require.NoError(b, err)
+	return &scaleFixture{
+		sectorCount:   sectorCount,
+		store:         store,
+		state:         root,
+		partitionSize: partitionSize,
+		sectorSize:    sectorSize,
+	}
+}
+
+func (f *scaleFixture) loadState(b *testing.B) *miner.State {
+	var st miner.State
+	require.NoError(b, f.store.Get(context.Background(), f.state, &st))
+	return &st
+}
+
+func (f *scaleFixture) loadDeadline(b *testing.B, st *miner.State, dlIdx uint64) *miner.Deadline {
+	deadlines, err := st.LoadDeadlines(f.store)
+	require.NoError(b, err)
+	dl, err := deadlines.LoadDeadline(f.store, dlIdx)
+	require.NoError(b, err)
+	return dl
+}
+
+// The sectors of each partition in a deadline.
+func (f *scaleFixture) partitionSectors(b *testing.B, dl *miner.Deadline) miner.PartitionSectorMap {
+	partitions, err := dl.PartitionsArray(f.store)
+	require.NoError(b, err)
+	result := miner.PartitionSectorMap{}
+	var partition miner.Partition
+	require.NoError(b, partitions.ForEach(&partition, func(i int64) error {
+		return result.Add(uint64(i), partition.Sectors)
+	}))
+	return result
+}
+
+// The sectors of each partition in a deadline whose numbers are multiples of n.
+func (f *scaleFixture) selectSectors(b *testing.B, dl *miner.Deadline, n uint64) miner.PartitionSectorMap {
+	result := miner.PartitionSectorMap{}
+	for partIdx, sectorNos := range f.partitionSectors(b, dl) { //nolint:nomaprange
+		var selected []uint64
+		require.NoError(b, sectorNos.ForEach(func(sectorNo uint64) error {
+			if sectorNo%n == 0 {
+				selected = append(selected, sectorNo)
+			}
+			return nil
+		}))
+		require.NoError(b, result.AddValues(partIdx, selected...))
+	}
+	return result
+}
+
+// Returns the root of the state after terminating every tenth sector of deadline 0.
+func (f *scaleFixture) terminatedState(b *testing.B) cid.Cid {
+	if f.terminated.Defined() {
+		return f.terminated
+	}
+	st := f.loadState(b)
+	deadlines, err := st.LoadDeadlines(f.store)
+	require.NoError(b, err)
+	dl, err := deadlines.LoadDeadline(f.store, 0)
+	require.NoError(b, err)
+	sectors, err := miner.LoadSectors(f.store, st.Sectors)
+	require.NoError(b, err)
+
+	toTerminate := f.selectSectors(b, dl, 10)
+	_, err = dl.TerminateSectors(f.store, sectors, 1, toTerminate, f.sectorSize, st.QuantSpecForDeadline(0))
+	require.NoError(b, err)
+	require.NoError(b, deadlines.UpdateDeadline(f.store, 0, dl))
+	require.NoError(b, st.SaveDeadlines(f.store, deadlines))
+	st.EarlyTerminations.Set(0)
+
+	f.terminated, err = f.store.Put(context.Background(), st)
+	require.NoError(b, err)
+	return f.terminated
+}
+
+// Returns the root of the state after declaring every fifth sector of deadline 0 faulty, and then every tenth
+// sector recovering, so that every partition has both faulty and recovering sectors.
+func (f *scaleFixture) faultyState(b *testing.B) cid.Cid {
+	if f.faulty.Defined() {
+		return f.faulty
+	}
+	st := f.loadState(b)
+	deadlines, err := st.LoadDeadlines(f.store)
+	require.NoError(b, err)
+	dl, err := deadlines.LoadDeadline(f.store, 0)
+	require.NoError(b, err)
+	sectors, err := miner.LoadSectors(f.store, st.Sectors)
+	require.NoError(b, err)
+
+	quant := st.QuantSpecForDeadline(0)
+	_, err = dl.DeclareFaults(f.store, sectors, f.sectorSize, quant, miner.FaultMaxAge, f.selectSectors(b, dl, 5))
+	require.NoError(b, err)
+	require.NoError(b, dl.DeclareFaultsRecovered(f.store, sectors, f.sectorSize, f.selectSectors(b, dl, 10)))
+	require.NoError(b, deadlines.UpdateDeadline(f.store, 0, dl))
+	require.NoError(b, st.SaveDeadlines(f.store, deadlines))
+
+	f.faulty, err = f.store.Put(context.Background(), st)
+	require.NoError(b, err)
+	return f.faulty
+}
+
+// Sector expirations are spread over this many epochs.
+const scaleExpirationSpread = abi.ChainEpoch(540 * 2880)
+
+func scaleExpiration(offset abi.ChainEpoch) abi.ChainEpoch {
+	return 180*2880 + offset
+}
+
+func scaleSector(number abi.SectorNumber) *miner.SectorOnChainInfo {
+	return &miner.SectorOnChainInfo{
+		SectorNumber:       number,
+		SealProof:          scaleSealProof,
+		SealedCID:          tutil.MakeCID(fmt.Sprintf("commR-%d", number), &miner.SealedCIDPrefix),
+		Activation:         0,
+		Expiration:         scaleExpiration(abi.ChainEpoch(uint64(number)*7919) % scaleExpirationSpread),
+		DealWeight:         big.Zero(),
+		VerifiedDealWeight: big.Zero(),
+		InitialPledge:      abi.NewTokenAmount(1 << 40),
+	}
+}
+
+// Accumulates the store I/O made by the timed part of each iteration of a benchmark.
+type ioCounter struct {
+	store  *ipld.InstrumentedStore
+	before ipld.IOStats
+	sum    ipld.IOStats
+}
+
+func (c *ioCounter) start() {
+	c.before = c.store.Total()
+}
+
+func (c *ioCounter) stop() {
+	after := c.store.Total()
+	c.sum.Gets += after.Gets - c.before.Gets
+	c.sum.Puts += after.Puts - c.before.Puts
+	c.sum.BytesRead += after.BytesRead - c.before.BytesRead
+	c.sum.BytesWritten += after.BytesWritten - c.before.BytesWritten
+}
+
+func (c *ioCounter) report(b *testing.B) {
+	n := float64(b.N)
+	b.ReportMetric(float64(c.sum.Gets)/n, "gets/op")
+	b.ReportMetric(float64(c.sum.Puts)/n, "puts/op")
+	b.ReportMetric(float64(c.sum.BytesRead)/n, "bytes-read/op")
+	b.ReportMetric(float64(c.sum.BytesWritten)/n, "bytes-written/op")
+}
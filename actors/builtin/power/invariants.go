@@ -0,0 +1,132 @@
+package power
+
+import (
+	addr "github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/util/adt"
+)
+
+// A summary of the power state, collected while checking its invariants, for checks of consistency with other actors.
+type StateSummary struct {
+	Claims map[addr.Address]Claim
+}
+
+// Checks internal invariants of the power state, returning a summary of the claims and the violations found.
+// The summary may be incomplete if violations were found.
+// The totals are recomputed from the claims, and cron events and batched proofs must target miners with claims.
+func CheckStateInvariants(store adt.Store, st *State) (*StateSummary, *builtin.MessageAccumulator) {
+	acc := &builtin.MessageAccumulator{}
+
+	acc.Require(st.TotalPledgeCollateral.GreaterThanEqual(big.Zero()), "negative total pledge collateral %v", st.TotalPledgeCollateral)
+
+	claims, ok := loadClaims(acc, store, st)
+	if !ok {
+		return &StateSummary{Claims: map[addr.Address]Claim{}}, acc
+	}
+	checkClaimTotals(acc, st, claims)
+	checkCronEvents(acc, store, st, claims)
+	checkProofValidationBatch(acc, store, st, claims)
+	return &StateSummary{Claims: claims}, acc
+}
+
+// Loads all claims, checking each is non-negative.
+func loadClaims(acc *builtin.MessageAccumulator, store adt.Store, st *State) (map[addr.Address]Claim, bool) {
+	claims := map[addr.Address]Claim{}
+	claimsMap, err := adt.AsMap(store, st.Claims)
+	if !acc.RequireNoError(err, "failed to load claims") {
+		return nil, false
+	}
+	var claim Claim
+	if err = claimsMap.ForEach(&claim, func(key string) error {
+		a, err := addr.NewFromBytes([]byte(key))
+		if err != nil {
+			return err
+		}
+		acc.Require(claim.RawBytePower.GreaterThanEqual(big.Zero()), "claim for %v has negative raw byte power %v", a, claim.RawBytePower)
+		acc.Require(claim.QualityAdjPower.GreaterThanEqual(big.Zero()), "claim for %v has negative quality adjusted power %v", a, claim.QualityAdjPower)
+		claims[a] = claim
+		return nil
+	}); !acc.RequireNoError(err, "failed to iterate claims") {
+		return nil, false
+	}
+	return claims, true
+}
+
+// Checks the miner counts and power totals against those recomputed from the claims.
+// Only miners meeting the consensus minimum power contribute to the raw byte and quality adjusted power totals.
+func checkClaimTotals(acc *builtin.MessageAccumulator, st *State, claims map[addr.Address]Claim) {
+	committedRaw, committedQA := big.Zero(), big.Zero()
+	aboveMinRaw, aboveMinQA := big.Zero(), big.Zero()
+	aboveMinCount := int64(0)
+	for _, claim := range claims { //nolint:nomaprange
+		committedRaw = big.Add(committedRaw, claim.RawBytePower)
+		committedQA = big.Add(committedQA, claim.QualityAdjPower)
+		if claim.QualityAdjPower.GreaterThanEqual(ConsensusMinerMinPower) {
+			aboveMinCount++
+			aboveMinRaw = big.Add(aboveMinRaw, claim.RawBytePower)
+			aboveMinQA = big.Add(aboveMinQA, claim.QualityAdjPower)
+		}
+	}
+
+	acc.Require(st.MinerCount == int64(len(claims)), "miner count %d differs from %d claims", st.MinerCount, len(claims))
+	acc.Require(st.MinerAboveMinPowerCount == aboveMinCount,
+		"miner above min power count %d differs from %d claims above min power", st.MinerAboveMinPowerCount, aboveMinCount)
+	acc.Require(st.TotalBytesCommitted.Equals(committedRaw),
+		"total bytes committed %v differs from claims' %v", st.TotalBytesCommitted, committedRaw)
+	acc.Require(st.TotalQABytesCommitted.Equals(committedQA),
+		"total quality adjusted bytes committed %v differs from claims' %v", st.TotalQABytesCommitted, committedQA)
+	acc.Require(st.TotalRawBytePower.Equals(aboveMinRaw),
+		"total raw byte power %v differs from claims' above min power %v", st.TotalRawBytePower, aboveMinRaw)
+	acc.Require(st.TotalQualityAdjPower.Equals(aboveMinQA),
+		"total quality adjusted power %v differs from claims' above min power %v", st.TotalQualityAdjPower, aboveMinQA)
+}
+
+// Checks that cron events are scheduled no earlier than the first cron epoch, for miners with claims.
+func checkCronEvents(acc *builtin.MessageAccumulator, store adt.Store, st *State, claims map[addr.Address]Claim) {
+	queue, err := adt.AsMultimap(store, st.CronEventQueue)
+	if !acc.RequireNoError(err, "failed to load cron event queue") {
+		return
+	}
+	err = queue.ForAll(func(key string, events *adt.Array) error {
+		epoch, err := adt.ParseIntKey(key)
+		if err != nil {
+			return err
+		}
+		acc.Require(abi.ChainEpoch(epoch) >= st.FirstCronEpoch,
+			"cron events at epoch %d before first cron epoch %d", epoch, st.FirstCronEpoch)
+
+		var event CronEvent
+		return events.ForEach(&event, func(i int64) error {
+			_, found := claims[event.MinerAddr]
+			acc.Require(found, "cron event %d at epoch %d for unknown miner %v", i, epoch, event.MinerAddr)
+			return nil
+		})
+	})
+	acc.RequireNoError(err, "failed to iterate cron event queue")
+}
+
+// Checks that batched proofs are submitted by miners with claims, within the per-epoch limit.
+func checkProofValidationBatch(acc *builtin.MessageAccumulator, store adt.Store, st *State, claims map[addr.Address]Claim) {
+	if st.ProofValidationBatch == nil {
+		return
+	}
+	batch, err := adt.AsMultimap(store, *st.ProofValidationBatch)
+	if !acc.RequireNoError(err, "failed to load proof validation batch") {
+		return
+	}
+	err = batch.ForAll(func(key string, infos *adt.Array) error {
+		a, err := addr.NewFromBytes([]byte(key))
+		if err != nil {
+			return err
+		}
+		_, found := claims[a]
+		acc.Require(found, "proof validation batch for unknown miner %v", a)
+		acc.Require(infos.Length() <= MaxMinerProveCommitsPerEpoch,
+			"proof validation batch for %v has %d proofs, more than %d", a, infos.Length(), MaxMinerProveCommitsPerEpoch)
+		return nil
+	})
+	acc.RequireNoError(err, "failed to iterate proof validation batch")
+}
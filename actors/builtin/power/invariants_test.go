@@ -0,0 +1,100 @@
+package power_test
+
+import (
+	"fmt"
+	"testing"
+
+	assert "github.com/stretchr/testify/assert"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	big "github.com/filecoin-project/specs-actors/actors/abi/big"
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	market "github.com/filecoin-project/specs-actors/actors/builtin/market"
+	mineract "github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	power "github.com/filecoin-project/specs-actors/actors/builtin/power"
+	mock "github.com/filecoin-project/specs-actors/support/mock"
+	tutil "github.com/filecoin-project/specs-actors/support/testing"
+)
+
+func TestCheckStateInvariants(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	miner1 := tutil.NewIDAddr(t, 111)
+	miner2 := tutil.NewIDAddr(t, 112)
+	unknown := tutil.NewIDAddr(t, 113)
+	cronEpoch := abi.ChainEpoch(10)
+	sealInfo := &abi.SealVerifyInfo{
+		SealedCID:   tutil.MakeCID("commR", &mineract.SealedCIDPrefix),
+		UnsealedCID: tutil.MakeCID("commD", &market.PieceCIDPrefix),
+	}
+
+	// Sets up one miner above the consensus minimum power, with a cron event and a batched proof, and one miner below.
+	setup := func(t *testing.T) (*mock.Runtime, *spActorHarness) {
+		rt, actor := basicPowerSetup(t)
+		actor.createMinerBasic(rt, owner, owner, miner1)
+		actor.createMinerBasic(rt, owner, owner, miner2)
+		actor.updateClaimedPower(rt, miner1, power.ConsensusMinerMinPower, power.ConsensusMinerMinPower)
+		actor.updateClaimedPower(rt, miner2, big.NewInt(1), big.NewInt(2))
+		actor.updatePledgeTotal(rt, miner1, abi.NewTokenAmount(1000))
+		actor.enrollCronEvent(rt, miner1, cronEpoch, []byte{})
+		actor.submitPoRepForBulkVerify(rt, miner1, sealInfo)
+		return rt, actor
+	}
+
+	check := func(rt *mock.Runtime, st *power.State) *builtin.MessageAccumulator {
+		_, acc := power.CheckStateInvariants(rt.AdtStore(), st)
+		return acc
+	}
+
+	t.Run("consistent state has no violations", func(t *testing.T) {
+		rt, _ := setup(t)
+		summary, acc := power.CheckStateInvariants(rt.AdtStore(), getState(rt))
+		tutil.AssertNoMsgs(t, acc)
+		assert.Len(t, summary.Claims, 2)
+		assert.Equal(t, power.ConsensusMinerMinPower, summary.Claims[miner1].QualityAdjPower)
+	})
+
+	t.Run("miner counts differ from claims", func(t *testing.T) {
+		rt, _ := setup(t)
+		st := getState(rt)
+		st.MinerCount = 3
+		st.MinerAboveMinPowerCount = 0
+		tutil.AssertMsgContains(t, check(rt, st), "miner count 3 differs from 2 claims")
+		tutil.AssertMsgContains(t, check(rt, st), "miner above min power count 0 differs from 1 claims above min power")
+	})
+
+	t.Run("totals differ from claims", func(t *testing.T) {
+		rt, _ := setup(t)
+		st := getState(rt)
+		st.TotalQABytesCommitted = power.ConsensusMinerMinPower
+		st.TotalRawBytePower = big.Add(power.ConsensusMinerMinPower, big.NewInt(1))
+		tutil.AssertMsgContains(t, check(rt, st), fmt.Sprintf("total quality adjusted bytes committed %v differs from claims' %v",
+			power.ConsensusMinerMinPower, big.Add(power.ConsensusMinerMinPower, big.NewInt(2))))
+		tutil.AssertMsgContains(t, check(rt, st), "total raw byte power")
+	})
+
+	t.Run("negative pledge", func(t *testing.T) {
+		rt, _ := setup(t)
+		st := getState(rt)
+		st.TotalPledgeCollateral = abi.NewTokenAmount(-1)
+		tutil.AssertMsgContains(t, check(rt, st), "negative total pledge collateral -1")
+	})
+
+	t.Run("cron event before first cron epoch", func(t *testing.T) {
+		rt, _ := setup(t)
+		st := getState(rt)
+		st.FirstCronEpoch = cronEpoch + 1
+		tutil.AssertMsgContains(t, check(rt, st), "cron events at epoch 10 before first cron epoch 11")
+	})
+
+	t.Run("cron event for unknown miner", func(t *testing.T) {
+		rt, actor := setup(t)
+		actor.enrollCronEvent(rt, unknown, cronEpoch, []byte{})
+		tutil.AssertMsgContains(t, check(rt, getState(rt)), fmt.Sprintf("cron event 1 at epoch 10 for unknown miner %v", unknown))
+	})
+
+	t.Run("proof for unknown miner", func(t *testing.T) {
+		rt, actor := setup(t)
+		actor.submitPoRepForBulkVerify(rt, unknown, sealInfo)
+		tutil.AssertMsgContains(t, check(rt, getState(rt)), fmt.Sprintf("proof validation batch for unknown miner %v", unknown))
+	})
+}
@@ -289,6 +289,7 @@ func TestOnConsensusFault(t *testing.T) {
 		require.True(t, st.TotalQABytesCommitted.IsZero())
 		require.True(t, st.TotalBytesCommitted.IsZero())
 		require.EqualValues(t, big.Sub(delta, slash), st.TotalPledgeCollateral)
+		ac.checkState(rt)
 	})
 
 	t.Run("fails if total pledged amount goes below zero after fault", func(t *testing.T) {
@@ -398,6 +399,7 @@ func TestPowerAndPledgeAccounting(t *testing.T) {
 		claim2 = actor.getClaim(rt, miner2)
 		require.Equal(t, big.Zero(), claim2.RawBytePower)
 		require.Equal(t, big.Zero(), claim2.QualityAdjPower)
+		actor.checkState(rt)
 	})
 
 	t.Run("power accounting crossing threshold", func(t *testing.T) {
@@ -434,6 +436,7 @@ func TestPowerAndPledgeAccounting(t *testing.T) {
 
 		actor.updateClaimedPower(rt, miner3, div(delta.Neg(), 2), delta.Neg())
 		actor.expectTotalPowerEager(rt, div(expectedTotalBelow, 2), expectedTotalBelow)
+		actor.checkState(rt)
 	})
 
 	t.Run("all of one miner's power disappears when that miner dips below min power threshold", func(t *testing.T) {
@@ -551,6 +554,7 @@ func TestPowerAndPledgeAccounting(t *testing.T) {
 
 		// power of the fourth miner is removed
 		actor.expectTotalPowerEager(rt, mul(powerUnit, 3), mul(powerUnit, 3))
+		actor.checkState(rt)
 	})
 }
 
@@ -1217,6 +1221,12 @@ func (h *spActorHarness) submitPoRepForBulkVerify(rt *mock.Runtime, minerAddr ad
 	rt.Verify()
 }
 
+func (h *spActorHarness) checkState(rt *mock.Runtime) {
+	st := getState(rt)
+	_, acc := power.CheckStateInvariants(rt.AdtStore(), st)
+	assert.Empty(h.t, acc.Messages(), "power state invariants violated")
+}
+
 func (h *spActorHarness) expectTotalPowerEager(rt *mock.Runtime, expectedRaw, expectedQA abi.StoragePower) {
 	st := getState(rt)
 
@@ -0,0 +1,64 @@
+package verifreg
+
+import (
+	addr "github.com/filecoin-project/go-address"
+	cid "github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/util/adt"
+)
+
+// A summary of the registry state, collected while checking its invariants, for checks of consistency with other
+// actors.
+type StateSummary struct {
+	Verifiers map[addr.Address]DataCap
+	Clients   map[addr.Address]DataCap
+}
+
+// Checks internal invariants of the verified registry state, returning a summary of the verifiers and clients and
+// the violations found.
+// The registry records only the remaining data cap of each verifier and client, so the allowances originally granted
+// cannot be reconstructed.
+func CheckStateInvariants(store adt.Store, st *State) (*StateSummary, *builtin.MessageAccumulator) {
+	acc := &builtin.MessageAccumulator{}
+	summary := &StateSummary{
+		Verifiers: map[addr.Address]DataCap{},
+		Clients:   map[addr.Address]DataCap{},
+	}
+
+	// Verifiers may have delegated all but a fraction of their cap.
+	loadDataCaps(acc, store, st.Verifiers, "verifier", big.Zero(), summary.Verifiers)
+	// Clients are removed once their remaining cap is too small for a verified deal.
+	loadDataCaps(acc, store, st.VerifiedClients, "verified client", MinVerifiedDealSize, summary.Clients)
+
+	for a := range summary.Clients { //nolint:nomaprange
+		_, found := summary.Verifiers[a]
+		acc.Require(!found, "verified client %v is also a verifier", a)
+	}
+	_, found := summary.Verifiers[st.RootKey]
+	acc.Require(!found, "root key %v is a verifier", st.RootKey)
+	_, found = summary.Clients[st.RootKey]
+	acc.Require(!found, "root key %v is a verified client", st.RootKey)
+	return summary, acc
+}
+
+// Loads a map of data caps, checking each is no less than a minimum.
+func loadDataCaps(acc *builtin.MessageAccumulator, store adt.Store, root cid.Cid, name string, min DataCap,
+	out map[addr.Address]DataCap) {
+	caps, err := adt.AsMap(store, root)
+	if !acc.RequireNoError(err, "failed to load %ss", name) {
+		return
+	}
+	var dataCap DataCap
+	err = caps.ForEach(&dataCap, func(key string) error {
+		a, err := addr.NewFromBytes([]byte(key))
+		if err != nil {
+			return err
+		}
+		acc.Require(dataCap.GreaterThanEqual(min), "%s %v data cap %v below minimum %v", name, a, dataCap, min)
+		out[a] = dataCap
+		return nil
+	})
+	acc.RequireNoError(err, "failed to iterate %ss", name)
+}
@@ -0,0 +1,74 @@
+package verifreg_test
+
+import (
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/verifreg"
+	"github.com/filecoin-project/specs-actors/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/support/mock"
+	tutil "github.com/filecoin-project/specs-actors/support/testing"
+)
+
+func TestCheckStateInvariants(t *testing.T) {
+	root := tutil.NewIDAddr(t, 101)
+	clientAddr := tutil.NewIDAddr(t, 201)
+	verifierAddr := tutil.NewIDAddr(t, 301)
+	allowance := big.Add(verifreg.MinVerifiedDealSize, big.NewInt(42))
+
+	setup := func(t *testing.T) (*mock.Runtime, *verifreg.State) {
+		rt, ac := basicVerifRegSetup(t, root)
+		ac.addVerifier(rt, verifierAddr, big.Add(allowance, big.NewInt(1)))
+		ac.addVerifiedClient(rt, verifierAddr, clientAddr, allowance)
+		var st verifreg.State
+		rt.GetState(&st)
+		return rt, &st
+	}
+
+	// Replaces the data cap of an address in a map, returning the new root.
+	putCap := func(t *testing.T, rt *mock.Runtime, mapRoot cid.Cid, a address.Address, dataCap verifreg.DataCap) cid.Cid {
+		m, err := adt.AsMap(rt.AdtStore(), mapRoot)
+		require.NoError(t, err)
+		require.NoError(t, m.Put(verifreg.AddrKey(a), &dataCap))
+		newRoot, err := m.Root()
+		require.NoError(t, err)
+		return newRoot
+	}
+
+	check := func(rt *mock.Runtime, st *verifreg.State) *builtin.MessageAccumulator {
+		_, acc := verifreg.CheckStateInvariants(rt.AdtStore(), st)
+		return acc
+	}
+
+	t.Run("consistent state has no violations", func(t *testing.T) {
+		rt, st := setup(t)
+		summary, acc := verifreg.CheckStateInvariants(rt.AdtStore(), st)
+		tutil.AssertNoMsgs(t, acc)
+		assert.Equal(t, big.NewInt(1), summary.Verifiers[verifierAddr])
+		assert.Equal(t, allowance, summary.Clients[clientAddr])
+	})
+
+	t.Run("client cap below minimum", func(t *testing.T) {
+		rt, st := setup(t)
+		st.VerifiedClients = putCap(t, rt, st.VerifiedClients, clientAddr, big.NewInt(1))
+		tutil.AssertMsgContains(t, check(rt, st), "verified client t0201 data cap 1 below minimum")
+	})
+
+	t.Run("client is a verifier", func(t *testing.T) {
+		rt, st := setup(t)
+		st.Verifiers = putCap(t, rt, st.Verifiers, clientAddr, big.NewInt(1))
+		tutil.AssertMsgContains(t, check(rt, st), "verified client t0201 is also a verifier")
+	})
+
+	t.Run("root key is a verifier", func(t *testing.T) {
+		rt, st := setup(t)
+		st.Verifiers = putCap(t, rt, st.Verifiers, root, allowance)
+		tutil.AssertMsgContains(t, check(rt, st), "root key t0101 is a verifier")
+	})
+}
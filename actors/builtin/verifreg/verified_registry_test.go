@@ -232,6 +232,7 @@ func TestAddVerifiedClient(t *testing.T) {
 
 		require.EqualValues(t, big.Zero(), ac.getVerifierCap(rt, verifierAddr))
 		require.EqualValues(t, big.Zero(), ac.getVerifierCap(rt, verifierAddr2))
+		ac.checkState(rt)
 	})
 
 	t.Run("verifier successfully adds a verified client and then fails on adding another verified client because of low allowance", func(t *testing.T) {
@@ -404,6 +405,7 @@ func TestUseBytes(t *testing.T) {
 		// verify
 		require.EqualValues(t, bal1, ac.getClientCap(rt, clientAddr))
 		ac.assertClientRemoved(rt, clientAddr2)
+		ac.checkState(rt)
 	})
 
 	t.Run("successfully consume deal bytes for verified client and then fail on next attempt because it does NOT have enough allowance", func(t *testing.T) {
@@ -565,6 +567,7 @@ func TestRestoreBytes(t *testing.T) {
 		require.EqualValues(t, bal1, ac.getClientCap(rt, clientAddr))
 		require.EqualValues(t, bal2, ac.getClientCap(rt, clientAddr2))
 		require.EqualValues(t, bal3, ac.getClientCap(rt, clientAddr3))
+		ac.checkState(rt)
 	})
 
 	t.Run("successfully restore bytes after using bytes reduces a client's cap", func(t *testing.T) {
@@ -805,6 +808,13 @@ func (h *verifRegActorTestHarness) getClientCap(rt *mock.Runtime, a address.Addr
 	return dc
 }
 
+func (h *verifRegActorTestHarness) checkState(rt *mock.Runtime) {
+	var st verifreg.State
+	rt.GetState(&st)
+	_, acc := verifreg.CheckStateInvariants(rt.AdtStore(), &st)
+	require.Empty(h.t, acc.Messages(), "verified registry state invariants violated")
+}
+
 func (h *verifRegActorTestHarness) assertVerifierRemoved(rt *mock.Runtime, a address.Address) {
 	var st verifreg.State
 	rt.GetState(&st)
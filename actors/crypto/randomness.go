@@ -1,5 +1,14 @@
 package crypto
 
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/minio/blake2b-simd"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+)
+
 // Specifies a domain for randomness generation.
 type DomainSeparationTag int64
 
@@ -12,3 +21,55 @@ const (
 	DomainSeparationTag_InteractiveSealChallengeSeed
 	DomainSeparationTag_WindowedPoStDeadlineAssignment
 )
+
+// All the domain separation tags, in increasing order.
+var DomainSeparationTags = []DomainSeparationTag{
+	DomainSeparationTag_TicketProduction,
+	DomainSeparationTag_ElectionProofProduction,
+	DomainSeparationTag_WinningPoStChallengeSeed,
+	DomainSeparationTag_WindowedPoStChallengeSeed,
+	DomainSeparationTag_SealRandomness,
+	DomainSeparationTag_InteractiveSealChallengeSeed,
+	DomainSeparationTag_WindowedPoStDeadlineAssignment,
+}
+
+func (t DomainSeparationTag) Name() (string, error) {
+	switch t {
+	case DomainSeparationTag_TicketProduction:
+		return "TicketProduction", nil
+	case DomainSeparationTag_ElectionProofProduction:
+		return "ElectionProofProduction", nil
+	case DomainSeparationTag_WinningPoStChallengeSeed:
+		return "WinningPoStChallengeSeed", nil
+	case DomainSeparationTag_WindowedPoStChallengeSeed:
+		return "WindowedPoStChallengeSeed", nil
+	case DomainSeparationTag_SealRandomness:
+		return "SealRandomness", nil
+	case DomainSeparationTag_InteractiveSealChallengeSeed:
+		return "InteractiveSealChallengeSeed", nil
+	case DomainSeparationTag_WindowedPoStDeadlineAssignment:
+		return "WindowedPoStDeadlineAssignment", nil
+	default:
+		return "", fmt.Errorf("invalid domain separation tag: %d", t)
+	}
+}
+
+// Derives randomness from a base value (a beacon or ticket) for a domain, epoch and entropy.
+// The result is blake2b-256(tag || blake2b-256(base) || epoch || entropy), with the tag and epoch encoded
+// as big-endian 64-bit integers.
+func DrawRandomness(base []byte, tag DomainSeparationTag, epoch abi.ChainEpoch, entropy []byte) abi.Randomness {
+	h := blake2b.New256()
+	var buf [8]byte
+
+	binary.BigEndian.PutUint64(buf[:], uint64(tag))
+	_, _ = h.Write(buf[:])
+
+	baseDigest := blake2b.Sum256(base)
+	_, _ = h.Write(baseDigest[:])
+
+	binary.BigEndian.PutUint64(buf[:], uint64(epoch))
+	_, _ = h.Write(buf[:])
+
+	_, _ = h.Write(entropy)
+	return h.Sum(nil)
+}
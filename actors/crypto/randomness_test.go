@@ -0,0 +1,60 @@
+package crypto_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	crypto "github.com/filecoin-project/specs-actors/actors/crypto"
+	tutil "github.com/filecoin-project/specs-actors/support/testing"
+)
+
+func TestDrawRandomness(t *testing.T) {
+	base := []byte("beacon")
+	tag := crypto.DomainSeparationTag_WindowedPoStChallengeSeed
+	epoch := abi.ChainEpoch(100)
+	entropy := []byte("entropy")
+
+	r := crypto.DrawRandomness(base, tag, epoch, entropy)
+	assert.Len(t, r, 32)
+	assert.Equal(t, r, crypto.DrawRandomness(base, tag, epoch, entropy))
+
+	assert.NotEqual(t, r, crypto.DrawRandomness([]byte("ticket"), tag, epoch, entropy))
+	assert.NotEqual(t, r, crypto.DrawRandomness(base, crypto.DomainSeparationTag_SealRandomness, epoch, entropy))
+	assert.NotEqual(t, r, crypto.DrawRandomness(base, tag, epoch+1, entropy))
+	assert.NotEqual(t, r, crypto.DrawRandomness(base, tag, epoch, nil))
+}
+
+func TestDomainSeparationTagNames(t *testing.T) {
+	names := map[string]bool{}
+	for _, tag := range crypto.DomainSeparationTags {
+		name, err := tag.Name()
+		assert.NoError(t, err)
+		names[name] = true
+	}
+	assert.Len(t, names, len(crypto.DomainSeparationTags))
+
+	_, err := crypto.DomainSeparationTag(0).Name()
+	assert.Error(t, err)
+}
+
+func TestFakeRandomness(t *testing.T) {
+	tag := crypto.DomainSeparationTag_SealRandomness
+	entropy := []byte("miner")
+
+	r1 := tutil.NewFakeRandomness([]byte("seed"))
+	r2 := tutil.NewFakeRandomness([]byte("seed"))
+	other := tutil.NewFakeRandomness([]byte("other seed"))
+
+	// reproducible for a seed
+	assert.Equal(t, r1.GetRandomnessFromTickets(tag, 10, entropy), r2.GetRandomnessFromTickets(tag, 10, entropy))
+	assert.Equal(t, r1.GetRandomnessFromBeacon(tag, 10, entropy), r2.GetRandomnessFromBeacon(tag, 10, entropy))
+
+	// but distinct across seeds, epochs and chains
+	assert.NotEqual(t, r1.GetRandomnessFromTickets(tag, 10, entropy), other.GetRandomnessFromTickets(tag, 10, entropy))
+	assert.NotEqual(t, r1.GetRandomnessFromTickets(tag, 10, entropy), r1.GetRandomnessFromTickets(tag, 11, entropy))
+	assert.NotEqual(t, r1.GetRandomnessFromTickets(tag, 10, entropy), r1.GetRandomnessFromBeacon(tag, 10, entropy))
+
+	assert.Equal(t, crypto.DrawRandomness(r1.BeaconValue(10), tag, 10, entropy), r1.GetRandomnessFromBeacon(tag, 10, entropy))
+}
@@ -2,6 +2,7 @@ package crypto
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"math"
@@ -31,6 +32,40 @@ func (t SigType) Name() (string, error) {
 	}
 }
 
+// Encodes a known signature type as its name, and any other (such as the zero value) as its number.
+func (t SigType) MarshalJSON() ([]byte, error) {
+	switch t {
+	case SigTypeSecp256k1, SigTypeBLS:
+		name, err := t.Name()
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(name)
+	default:
+		return json.Marshal(byte(t))
+	}
+}
+
+// Decodes a signature type from its name or number.
+func (t *SigType) UnmarshalJSON(b []byte) error {
+	var num byte
+	if err := json.Unmarshal(b, &num); err == nil {
+		*t = SigType(num)
+		return nil
+	}
+	var name string
+	if err := json.Unmarshal(b, &name); err != nil {
+		return fmt.Errorf("signature type is neither a name nor a number: %s", string(b))
+	}
+	for _, candidate := range []SigType{SigTypeUnknown, SigTypeSecp256k1, SigTypeBLS} {
+		if n, _ := candidate.Name(); n == name {
+			*t = candidate
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid signature type name: %q", name)
+}
+
 const SignatureMaxLength = 200
 
 type Signature struct {
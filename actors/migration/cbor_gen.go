@@ -0,0 +1,86 @@
+// Code generated by github.com/whyrusleeping/cbor-gen. DO NOT EDIT.
+
+package migration
+
+import (
+	"fmt"
+	"io"
+
+	cbg "github.com/whyrusleeping/cbor-gen"
+	xerrors "golang.org/x/xerrors"
+)
+
+var _ = xerrors.Errorf
+
+var lengthBufJournal = []byte{130}
+
+func (t *Journal) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufJournal); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.InputRoot (cid.Cid) (struct)
+
+	if err := cbg.WriteCidBuf(scratch, w, t.InputRoot); err != nil {
+		return xerrors.Errorf("failed to write cid field t.InputRoot: %w", err)
+	}
+
+	// t.Migrated (cid.Cid) (struct)
+
+	if err := cbg.WriteCidBuf(scratch, w, t.Migrated); err != nil {
+		return xerrors.Errorf("failed to write cid field t.Migrated: %w", err)
+	}
+
+	return nil
+}
+
+func (t *Journal) UnmarshalCBOR(r io.Reader) error {
+	*t = Journal{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 2 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.InputRoot (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.InputRoot: %w", err)
+		}
+
+		t.InputRoot = c
+
+	}
+	// t.Migrated (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.Migrated: %w", err)
+		}
+
+		t.Migrated = c
+
+	}
+	return nil
+}
@@ -0,0 +1,326 @@
+// Package migration rewrites the state of every actor in a state tree, as required by a protocol upgrade that
+// changes the code or state schema of some actors.
+//
+// Each actor's state is migrated by a function registered for its code CID, which also names the code of the
+// migrated actor. Actors are migrated in parallel, with results accumulated into the new state tree.
+// That partial tree is recorded in a journal that may be checkpointed, so that an interrupted migration can resume
+// without repeating the actors already migrated.
+package migration
+
+import (
+	"context"
+	"runtime"
+	"strings"
+
+	addr "github.com/filecoin-project/go-address"
+	cid "github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	states "github.com/filecoin-project/specs-actors/actors/states"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+)
+
+// The actor whose state is to be migrated.
+type ActorMigrationInput struct {
+	Address addr.Address // ID address
+	Balance abi.TokenAmount
+	Head    cid.Cid
+}
+
+// Migrates the state of one actor, writing the new state to the store and returning its CID.
+// Migrations of different actors run concurrently, and so must not share mutable state.
+type ActorMigration func(ctx context.Context, store adt.Store, in ActorMigrationInput) (cid.Cid, error)
+
+// A migration that leaves an actor's state unchanged, for actors whose state schema has not changed.
+func Unchanged(_ context.Context, _ adt.Store, in ActorMigrationInput) (cid.Cid, error) {
+	return in.Head, nil
+}
+
+// A set of actor migrations, keyed by the code CID of the actors to which they apply.
+type Registry struct {
+	migrations map[cid.Cid]codeMigration
+}
+
+type codeMigration struct {
+	newCode cid.Cid
+	migrate ActorMigration
+}
+
+func NewRegistry() *Registry {
+	return &Registry{migrations: map[cid.Cid]codeMigration{}}
+}
+
+// Registers the migration for actors with an old code CID, which become actors with the new code CID.
+// The old and new codes may be equal if only the state schema changes.
+// It is an error to register more than one migration for an old code CID.
+func (r *Registry) Register(oldCode, newCode cid.Cid, migrate ActorMigration) error {
+	if !oldCode.Defined() || !newCode.Defined() {
+		return xerrors.Errorf("undefined code CID in migration from %v to %v", oldCode, newCode)
+	}
+	if migrate == nil {
+		return xerrors.Errorf("nil migration from %v to %v", oldCode, newCode)
+	}
+	if existing, ok := r.migrations[oldCode]; ok {
+		return xerrors.Errorf("duplicate migration from %v, already registered to %v", oldCode, existing.newCode)
+	}
+	r.migrations[oldCode] = codeMigration{newCode: newCode, migrate: migrate}
+	return nil
+}
+
+// Returns the code CID to which actors with an old code CID migrate.
+func (r *Registry) NewCode(oldCode cid.Cid) (cid.Cid, bool) {
+	m, ok := r.migrations[oldCode]
+	return m.newCode, ok
+}
+
+// The progress of a migration, which may be stored and passed to a later migration of the same state tree in order
+// to resume it.
+type Journal struct {
+	// The root of the state tree being migrated.
+	InputRoot cid.Cid
+	// The root of the new state tree, containing only the actors migrated so far.
+	Migrated cid.Cid
+}
+
+type Config struct {
+	// The number of actors to migrate concurrently. Defaults to the number of CPUs.
+	MaxWorkers int
+	// The epoch at which the input state was computed, for checking the invariants of the new state.
+	Epoch abi.ChainEpoch
+	// The CID of a journal from which to resume an interrupted migration of the same state tree, or cid.Undef.
+	Resume cid.Cid
+	// If not nil, called with the number of actors migrated and the total number of actors each time an actor
+	// migration completes, including those completed before the migration was resumed.
+	Progress func(migrated, total int)
+	// If not nil, called with the CID of the journal each time CheckpointInterval actor migrations complete, and
+	// when the migration fails. The journal and the partial tree are in the store.
+	Checkpoint func(journal cid.Cid) error
+	// The number of actor migrations completing between checkpoints. Defaults to 1000.
+	CheckpointInterval int
+	// Checks the invariants of the new state tree, returning the violations found.
+	// Defaults to states.CheckStateInvariants, which recognises only actors of builtin.ActorsVersion, and so must be
+	// provided if any actor migrates to a code CID of another version.
+	CheckInvariants func(tree *states.Tree, epoch abi.ChainEpoch) (*builtin.MessageAccumulator, error)
+}
+
+const defaultCheckpointInterval = 1000
+
+// Migrates every actor in a state tree, returning the root of the new state tree.
+// Every actor's code must have a registered migration. The new state is checked against the invariants of the
+// actors (see Config.CheckInvariants) and an error returned if any are violated.
+// The store must be safe for concurrent use.
+func MigrateStateTree(ctx context.Context, store adt.Store, root cid.Cid, registry *Registry, cfg Config) (cid.Cid, error) {
+	if cfg.MaxWorkers <= 0 {
+		cfg.MaxWorkers = runtime.NumCPU()
+	}
+	if cfg.CheckpointInterval <= 0 {
+		cfg.CheckpointInterval = defaultCheckpointInterval
+	}
+	if cfg.CheckInvariants == nil {
+		for _, m := range registry.migrations { //nolint:nomaprange
+			if !builtin.IsBuiltinActor(m.newCode) {
+				return cid.Undef, xerrors.Errorf("migration to code %v requires an invariant check for its version", m.newCode)
+			}
+		}
+		cfg.CheckInvariants = states.CheckStateInvariants
+	}
+
+	input, err := states.LoadTree(store, root)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to load state tree %v: %w", root, err)
+	}
+	output, err := loadJournal(store, root, cfg.Resume)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	// Find the actors remaining to be migrated, checking that each has a migration before any is migrated.
+	var pending []pendingActor
+	total := 0
+	if err = input.ForEach(func(a addr.Address, actor *states.Actor) error {
+		total++
+		m, ok := registry.migrations[actor.Code]
+		if !ok {
+			return xerrors.Errorf("no migration for actor %v with code %v", a, actor.Code)
+		}
+		_, done, err := output.GetActor(a)
+		if err != nil {
+			return err
+		}
+		if !done {
+			pending = append(pending, pendingActor{address: a, actor: actor, migration: m})
+		}
+		return nil
+	}); err != nil {
+		return cid.Undef, xerrors.Errorf("failed to traverse state tree %v: %w", root, err)
+	}
+
+	if err = migrateActors(ctx, store, root, output, pending, total, cfg); err != nil {
+		return cid.Undef, err
+	}
+	newRoot, err := output.Flush()
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to flush new state tree: %w", err)
+	}
+
+	acc, err := cfg.CheckInvariants(output, cfg.Epoch)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to check invariants of new state tree %v: %w", newRoot, err)
+	}
+	if !acc.IsEmpty() {
+		return cid.Undef, invariantsError(newRoot, acc)
+	}
+	return newRoot, nil
+}
+
+type pendingActor struct {
+	address   addr.Address
+	actor     *states.Actor
+	migration codeMigration
+}
+
+type migratedActor struct {
+	address addr.Address
+	actor   *states.Actor
+	err     error
+}
+
+// Migrates the pending actors with a pool of workers, accumulating the results into the output tree.
+// The output tree is only accessed by the calling goroutine.
+func migrateActors(ctx context.Context, store adt.Store, root cid.Cid, output *states.Tree, pending []pendingActor,
+	total int, cfg Config) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan pendingActor)
+	results := make(chan migratedActor)
+	go func() {
+		defer close(jobs)
+		for _, p := range pending {
+			select {
+			case jobs <- p:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	workers := cfg.MaxWorkers
+	if workers > len(pending) {
+		workers = len(pending)
+	}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for p := range jobs {
+				result := migrateActor(ctx, store, p)
+				select {
+				case results <- result:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	migrated := total - len(pending)
+	sinceCheckpoint := 0
+	var err error
+	for migrated < total {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		var result migratedActor
+		select {
+		case result = <-results:
+			err = result.err
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if err != nil {
+			break
+		}
+		if err = output.SetActor(result.address, result.actor); err != nil {
+			break
+		}
+		migrated++
+		if cfg.Progress != nil {
+			cfg.Progress(migrated, total)
+		}
+		sinceCheckpoint++
+		if cfg.Checkpoint != nil && sinceCheckpoint == cfg.CheckpointInterval && migrated < total {
+			if err = checkpoint(store, root, output, cfg.Checkpoint); err != nil {
+				break
+			}
+			sinceCheckpoint = 0
+		}
+	}
+	if err != nil {
+		// Record the actors migrated before the failure, so that they need not be migrated again.
+		cancel()
+		if cfg.Checkpoint != nil {
+			if cpErr := checkpoint(store, root, output, cfg.Checkpoint); cpErr != nil {
+				return xerrors.Errorf("failed to checkpoint (%v) after migration failure: %w", cpErr, err)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+func migrateActor(ctx context.Context, store adt.Store, p pendingActor) migratedActor {
+	head, err := p.migration.migrate(ctx, store, ActorMigrationInput{
+		Address: p.address,
+		Balance: p.actor.Balance,
+		Head:    p.actor.Head,
+	})
+	if err != nil {
+		return migratedActor{err: xerrors.Errorf("failed to migrate actor %v with code %v: %w", p.address, p.actor.Code, err)}
+	}
+	return migratedActor{
+		address: p.address,
+		actor: &states.Actor{
+			Code:       p.migration.newCode,
+			Head:       head,
+			CallSeqNum: p.actor.CallSeqNum,
+			Balance:    p.actor.Balance,
+		},
+	}
+}
+
+// Loads the partial output tree from a journal, or an empty tree if there is none.
+func loadJournal(store adt.Store, root cid.Cid, journal cid.Cid) (*states.Tree, error) {
+	if !journal.Defined() {
+		return states.NewTree(store), nil
+	}
+	var j Journal
+	if err := store.Get(store.Context(), journal, &j); err != nil {
+		return nil, xerrors.Errorf("failed to load migration journal %v: %w", journal, err)
+	}
+	if !j.InputRoot.Equals(root) {
+		return nil, xerrors.Errorf("migration journal %v is for state tree %v, not %v", journal, j.InputRoot, root)
+	}
+	tree, err := states.LoadTree(store, j.Migrated)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load partially migrated state tree %v: %w", j.Migrated, err)
+	}
+	return tree, nil
+}
+
+// Writes a journal of the partial output tree to the store and reports its CID.
+func checkpoint(store adt.Store, root cid.Cid, output *states.Tree, fn func(cid.Cid) error) error {
+	migrated, err := output.Flush()
+	if err != nil {
+		return xerrors.Errorf("failed to flush partially migrated state tree: %w", err)
+	}
+	journal, err := store.Put(store.Context(), &Journal{InputRoot: root, Migrated: migrated})
+	if err != nil {
+		return xerrors.Errorf("failed to write migration journal: %w", err)
+	}
+	return fn(journal)
+}
+
+func invariantsError(root cid.Cid, acc *builtin.MessageAccumulator) error {
+	msgs := acc.Messages()
+	return xerrors.Errorf("migrated state tree %v violates %d invariants: %s", root, len(msgs), strings.Join(msgs, "; "))
+}
@@ -0,0 +1,302 @@
+package migration_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	addr "github.com/filecoin-project/go-address"
+	cid "github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	big "github.com/filecoin-project/specs-actors/actors/abi/big"
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	account "github.com/filecoin-project/specs-actors/actors/builtin/account"
+	miner "github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	power "github.com/filecoin-project/specs-actors/actors/builtin/power"
+	migration "github.com/filecoin-project/specs-actors/actors/migration"
+	states "github.com/filecoin-project/specs-actors/actors/states"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+	tutil "github.com/filecoin-project/specs-actors/support/testing"
+	vm "github.com/filecoin-project/specs-actors/support/vm"
+)
+
+var builtinCodes = []cid.Cid{
+	builtin.SystemActorCodeID,
+	builtin.InitActorCodeID,
+	builtin.CronActorCodeID,
+	builtin.AccountActorCodeID,
+	builtin.StoragePowerActorCodeID,
+	builtin.StorageMinerActorCodeID,
+	builtin.StorageMarketActorCodeID,
+	builtin.PaymentChannelActorCodeID,
+	builtin.MultisigActorCodeID,
+	builtin.RewardActorCodeID,
+	builtin.VerifiedRegistryActorCodeID,
+}
+
+func TestRegistry(t *testing.T) {
+	newCode := tutil.MakeCID("fil/2/account", nil)
+	registry := migration.NewRegistry()
+	require.NoError(t, registry.Register(builtin.AccountActorCodeID, newCode, migration.Unchanged))
+
+	code, ok := registry.NewCode(builtin.AccountActorCodeID)
+	assert.True(t, ok)
+	assert.Equal(t, newCode, code)
+	_, ok = registry.NewCode(builtin.MultisigActorCodeID)
+	assert.False(t, ok)
+
+	assert.Error(t, registry.Register(builtin.AccountActorCodeID, builtin.AccountActorCodeID, migration.Unchanged), "duplicate")
+	assert.Error(t, registry.Register(builtin.MultisigActorCodeID, cid.Undef, migration.Unchanged), "undefined")
+	assert.Error(t, registry.Register(builtin.MultisigActorCodeID, builtin.MultisigActorCodeID, nil), "nil")
+}
+
+func TestMigrateStateTree(t *testing.T) {
+	ctx := context.Background()
+	v := vm.NewVMWithSingletons(ctx, t)
+	addrs := vm.CreateAccounts(ctx, t, v, 3, big.Mul(big.NewInt(10_000), vm.FIL), 93837778)
+	minerAddrs := createMiner(t, v, addrs[0])
+	store := v.Store()
+	root := v.StateRoot()
+	total := countActors(t, store, root)
+
+	t.Run("unchanged", func(t *testing.T) {
+		newRoot, err := migration.MigrateStateTree(ctx, store, root, unchangedRegistry(t), migration.Config{})
+		require.NoError(t, err)
+		assert.Equal(t, root, newRoot)
+	})
+
+	t.Run("new code and state", func(t *testing.T) {
+		newCode := builtin.MakeActorCodeID(2, "account")
+		registry := unchangedRegistryExcept(t, builtin.AccountActorCodeID)
+		require.NoError(t, registry.Register(builtin.AccountActorCodeID, newCode, func(ctx context.Context, store adt.Store, in migration.ActorMigrationInput) (cid.Cid, error) {
+			var st account.State
+			if err := store.Get(ctx, in.Head, &st); err != nil {
+				return cid.Undef, err
+			}
+			// Records the ID address in place of the key address.
+			st.Address = in.Address
+			return store.Put(ctx, &st)
+		}))
+
+		var progress [][2]int
+		newRoot, err := migration.MigrateStateTree(ctx, store, root, registry, migration.Config{
+			MaxWorkers: 2,
+			Progress:   func(migrated, total int) { progress = append(progress, [2]int{migrated, total}) },
+			// Accounts are not checked, so the checks of the current version apply.
+			CheckInvariants: states.CheckStateInvariants,
+		})
+		require.NoError(t, err)
+		require.Len(t, progress, total)
+		assert.Equal(t, [2]int{1, total}, progress[0])
+		assert.Equal(t, [2]int{total, total}, progress[total-1])
+
+		tree, err := states.LoadTree(store, newRoot)
+		require.NoError(t, err)
+		for _, a := range addrs {
+			idAddr, found := v.NormalizeAddress(a)
+			require.True(t, found)
+			act, found, err := tree.GetActor(idAddr)
+			require.NoError(t, err)
+			require.True(t, found)
+			assert.Equal(t, newCode, act.Code)
+			var st account.State
+			require.NoError(t, store.Get(ctx, act.Head, &st))
+			assert.Equal(t, idAddr, st.Address)
+
+			oldAct, _, err := v.GetActor(idAddr)
+			require.NoError(t, err)
+			assert.Equal(t, oldAct.Balance, act.Balance)
+			assert.Equal(t, oldAct.CallSeqNum, act.CallSeqNum)
+		}
+	})
+
+	t.Run("new version of power", func(t *testing.T) {
+		power2 := builtin.MakeActorCodeID(2, "storagepower")
+		registry := unchangedRegistryExcept(t, builtin.StoragePowerActorCodeID)
+		require.NoError(t, registry.Register(builtin.StoragePowerActorCodeID, power2, migration.Unchanged))
+
+		// The current version's checks would not find the power actor.
+		_, err := migration.MigrateStateTree(ctx, store, root, registry, migration.Config{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invariant check")
+
+		// Checks the power actor of the new version, whose state schema is unchanged.
+		checks := 0
+		checkPower2 := func(tree *states.Tree, _ abi.ChainEpoch) (*builtin.MessageAccumulator, error) {
+			checks++
+			acc := &builtin.MessageAccumulator{}
+			act, found, err := tree.GetActor(builtin.StoragePowerActorAddr)
+			if err != nil {
+				return nil, err
+			}
+			if !found || !act.Code.Equals(power2) {
+				acc.Addf("no power actor with code %v", power2)
+				return acc, nil
+			}
+			var st power.State
+			if err := tree.Store.Get(ctx, act.Head, &st); err != nil {
+				return nil, err
+			}
+			_, msgs := power.CheckStateInvariants(tree.Store, &st)
+			acc.AddAll(msgs)
+			return acc, nil
+		}
+		newRoot, err := migration.MigrateStateTree(ctx, store, root, registry, migration.Config{CheckInvariants: checkPower2})
+		require.NoError(t, err)
+		assert.Equal(t, 1, checks)
+		tree, err := states.LoadTree(store, newRoot)
+		require.NoError(t, err)
+		act, found, err := tree.GetActor(builtin.StoragePowerActorAddr)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, power2, act.Code)
+
+		// Violations found by the check fail the migration.
+		_, err = migration.MigrateStateTree(ctx, store, root, registry, migration.Config{
+			CheckInvariants: func(tree *states.Tree, epoch abi.ChainEpoch) (*builtin.MessageAccumulator, error) {
+				acc, err := checkPower2(tree, epoch)
+				acc.Add("power claims do not match miners")
+				return acc, err
+			},
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "power claims do not match miners")
+	})
+
+	t.Run("missing migration", func(t *testing.T) {
+		registry := unchangedRegistryExcept(t, builtin.StorageMinerActorCodeID)
+		_, err := migration.MigrateStateTree(ctx, store, root, registry, migration.Config{})
+		assert.Error(t, err)
+	})
+
+	t.Run("migration fails", func(t *testing.T) {
+		registry := unchangedRegistryExcept(t, builtin.StorageMinerActorCodeID)
+		require.NoError(t, registry.Register(builtin.StorageMinerActorCodeID, builtin.StorageMinerActorCodeID, func(_ context.Context, _ adt.Store, _ migration.ActorMigrationInput) (cid.Cid, error) {
+			return cid.Undef, xerrors.New("boom")
+		}))
+		_, err := migration.MigrateStateTree(ctx, store, root, registry, migration.Config{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "boom")
+	})
+
+	t.Run("invariant violated", func(t *testing.T) {
+		registry := unchangedRegistryExcept(t, builtin.StorageMinerActorCodeID)
+		require.NoError(t, registry.Register(builtin.StorageMinerActorCodeID, builtin.StorageMinerActorCodeID, func(ctx context.Context, store adt.Store, in migration.ActorMigrationInput) (cid.Cid, error) {
+			var st miner.State
+			if err := store.Get(ctx, in.Head, &st); err != nil {
+				return cid.Undef, err
+			}
+			st.LockedFunds = big.Add(in.Balance, big.NewInt(1))
+			return store.Put(ctx, &st)
+		}))
+		_, err := migration.MigrateStateTree(ctx, store, root, registry, migration.Config{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), minerAddrs.IDAddress.String())
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		// The first attempt fails at the miner, after checkpointing every actor migration.
+		registry := unchangedRegistryExcept(t, builtin.StorageMinerActorCodeID)
+		require.NoError(t, registry.Register(builtin.StorageMinerActorCodeID, builtin.StorageMinerActorCodeID, func(_ context.Context, _ adt.Store, _ migration.ActorMigrationInput) (cid.Cid, error) {
+			return cid.Undef, xerrors.New("interrupted")
+		}))
+		var journal cid.Cid
+		checkpoints := 0
+		_, err := migration.MigrateStateTree(ctx, store, root, registry, migration.Config{
+			MaxWorkers:         1,
+			CheckpointInterval: 1,
+			Checkpoint: func(j cid.Cid) error {
+				journal = j
+				checkpoints++
+				return nil
+			},
+		})
+		require.Error(t, err)
+		require.True(t, journal.Defined())
+		assert.True(t, checkpoints > 0)
+
+		var j migration.Journal
+		require.NoError(t, store.Get(ctx, journal, &j))
+		assert.Equal(t, root, j.InputRoot)
+		done := countActors(t, store, j.Migrated)
+		assert.True(t, done < total)
+
+		// The second attempt migrates only the actors not recorded in the journal.
+		var mu sync.Mutex
+		var migrated []addr.Address
+		counting := func(ctx context.Context, store adt.Store, in migration.ActorMigrationInput) (cid.Cid, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			migrated = append(migrated, in.Address)
+			return in.Head, nil
+		}
+		registry = migration.NewRegistry()
+		for _, code := range builtinCodes {
+			require.NoError(t, registry.Register(code, code, counting))
+		}
+		var progress [][2]int
+		newRoot, err := migration.MigrateStateTree(ctx, store, root, registry, migration.Config{
+			Resume:   journal,
+			Progress: func(migrated, total int) { progress = append(progress, [2]int{migrated, total}) },
+		})
+		require.NoError(t, err)
+		assert.Equal(t, root, newRoot)
+		assert.Len(t, migrated, total-done)
+		assert.Contains(t, migrated, minerAddrs.IDAddress)
+		require.NotEmpty(t, progress)
+		assert.Equal(t, [2]int{done + 1, total}, progress[0])
+
+		// A journal cannot resume the migration of a different tree.
+		_, err = migration.MigrateStateTree(ctx, store, j.Migrated, registry, migration.Config{Resume: journal})
+		assert.Error(t, err)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := migration.MigrateStateTree(cctx, store, root, unchangedRegistry(t), migration.Config{})
+		assert.True(t, xerrors.Is(err, context.Canceled), "%v", err)
+	})
+}
+
+func createMiner(t *testing.T, v *vm.VM, owner addr.Address) *power.CreateMinerReturn {
+	ret := vm.ApplyOk(t, v, owner, builtin.StoragePowerActorAddr, big.Zero(), builtin.MethodsPower.CreateMiner, &power.CreateMinerParams{
+		Owner:         owner,
+		Worker:        owner,
+		SealProofType: abi.RegisteredSealProof_StackedDrg32GiBV1,
+		Peer:          abi.PeerID("peer"),
+	})
+	var minerAddrs power.CreateMinerReturn
+	require.NoError(t, ret.Into(&minerAddrs))
+	return &minerAddrs
+}
+
+func unchangedRegistry(t *testing.T) *migration.Registry {
+	return unchangedRegistryExcept(t, cid.Undef)
+}
+
+// Registers unchanged migrations for every builtin actor except one.
+func unchangedRegistryExcept(t *testing.T, except cid.Cid) *migration.Registry {
+	registry := migration.NewRegistry()
+	for _, code := range builtinCodes {
+		if !code.Equals(except) {
+			require.NoError(t, registry.Register(code, code, migration.Unchanged))
+		}
+	}
+	return registry
+}
+
+func countActors(t *testing.T, store adt.Store, root cid.Cid) int {
+	tree, err := states.LoadTree(store, root)
+	require.NoError(t, err)
+	count := 0
+	require.NoError(t, tree.ForEach(func(_ addr.Address, _ *states.Actor) error {
+		count++
+		return nil
+	}))
+	return count
+}
@@ -0,0 +1,147 @@
+package checked
+
+import (
+	"bytes"
+
+	addr "github.com/filecoin-project/go-address"
+	cid "github.com/ipfs/go-cid"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	runtime "github.com/filecoin-project/specs-actors/actors/runtime"
+	exitcode "github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+)
+
+// The exit code with which a Runtime aborts when an actor violates the rules of the runtime.
+const ErrViolation = exitcode.SysErrorIllegalActor
+
+// A Runtime wraps another runtime for the invocation of a single actor method, enforcing the rules that the
+// runtime interface places on actor code. It aborts with ErrViolation when the actor:
+//   - validates the immediate caller more than once,
+//   - sends a message from inside a state transaction,
+//   - returns without having validated the immediate caller (checked by CheckReturn), or
+//   - mutates a state object outside a transaction, whether loaded with Readonly or persisted by a completed
+//     Transaction (checked at the next state access or send, and by CheckReturn).
+type Runtime struct {
+	runtime.Runtime
+
+	validations   int
+	inTransaction bool
+	// Serializations of the state objects loaded or persisted, which must not subsequently change.
+	snapshots map[runtime.CBORMarshaler][]byte
+}
+
+var _ runtime.Runtime = (*Runtime)(nil)
+
+func NewRuntime(rt runtime.Runtime) *Runtime {
+	return &Runtime{
+		Runtime:   rt,
+		snapshots: map[runtime.CBORMarshaler][]byte{},
+	}
+}
+
+// Checks the rules that apply when the actor method returns.
+// This must be called after the method returns normally, and not if it aborts.
+func (rt *Runtime) CheckReturn() {
+	if rt.validations == 0 {
+		rt.violation("method returned without validating the immediate caller")
+	}
+	rt.checkSnapshots()
+}
+
+func (rt *Runtime) ValidateImmediateCallerAcceptAny() {
+	rt.validate()
+	rt.Runtime.ValidateImmediateCallerAcceptAny()
+}
+
+func (rt *Runtime) ValidateImmediateCallerIs(addrs ...addr.Address) {
+	rt.validate()
+	rt.Runtime.ValidateImmediateCallerIs(addrs...)
+}
+
+func (rt *Runtime) ValidateImmediateCallerType(types ...cid.Cid) {
+	rt.validate()
+	rt.Runtime.ValidateImmediateCallerType(types...)
+}
+
+func (rt *Runtime) Send(toAddr addr.Address, methodNum abi.MethodNum, params runtime.CBORMarshaler, value abi.TokenAmount) (runtime.SendReturn, exitcode.ExitCode) {
+	if rt.inTransaction {
+		rt.violation("send to %s method %d inside a state transaction", toAddr, methodNum)
+	}
+	rt.checkSnapshots()
+	return rt.Runtime.Send(toAddr, methodNum, params, value)
+}
+
+func (rt *Runtime) State() runtime.StateHandle {
+	return &stateHandle{rt: rt, inner: rt.Runtime.State()}
+}
+
+func (rt *Runtime) validate() {
+	rt.validations++
+	if rt.validations > 1 {
+		rt.violation("immediate caller validated %d times", rt.validations)
+	}
+}
+
+func (rt *Runtime) violation(msg string, args ...interface{}) {
+	rt.Runtime.Abortf(ErrViolation, "runtime rule violated: "+msg, args...)
+}
+
+// Records the serialization of a state object, so that later mutation can be detected.
+func (rt *Runtime) snapshot(obj interface{}) {
+	m, ok := obj.(runtime.CBORMarshaler)
+	if !ok {
+		return
+	}
+	rt.snapshots[m] = serialize(rt, m)
+}
+
+func (rt *Runtime) forget(obj interface{}) {
+	if m, ok := obj.(runtime.CBORMarshaler); ok {
+		delete(rt.snapshots, m)
+	}
+}
+
+func (rt *Runtime) checkSnapshots() {
+	for obj, before := range rt.snapshots { //nolint:nomaprange
+		if !bytes.Equal(before, serialize(rt, obj)) {
+			rt.violation("state object of type %T mutated outside a transaction", obj)
+		}
+	}
+}
+
+func serialize(rt *Runtime, obj runtime.CBORMarshaler) []byte {
+	buf := bytes.Buffer{}
+	if err := obj.MarshalCBOR(&buf); err != nil {
+		rt.Runtime.Abortf(exitcode.ErrSerialization, "failed to serialize state object of type %T: %s", obj, err)
+	}
+	return buf.Bytes()
+}
+
+type stateHandle struct {
+	rt    *Runtime
+	inner runtime.StateHandle
+}
+
+func (h *stateHandle) Create(obj runtime.CBORMarshaler) {
+	h.rt.checkSnapshots()
+	h.inner.Create(obj)
+	h.rt.snapshot(obj)
+}
+
+func (h *stateHandle) Readonly(obj runtime.CBORUnmarshaler) {
+	h.rt.checkSnapshots()
+	h.inner.Readonly(obj)
+	h.rt.snapshot(obj)
+}
+
+func (h *stateHandle) Transaction(obj runtime.CBORer, f func()) {
+	h.rt.checkSnapshots()
+	// The object is reloaded and may be legitimately mutated within the transaction.
+	h.rt.forget(obj)
+	h.inner.Transaction(obj, func() {
+		h.rt.inTransaction = true
+		defer func() { h.rt.inTransaction = false }()
+		f()
+	})
+	h.rt.snapshot(obj)
+}
@@ -0,0 +1,191 @@
+package checked_test
+
+import (
+	"bytes"
+	"testing"
+
+	addr "github.com/filecoin-project/go-address"
+	cid "github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	big "github.com/filecoin-project/specs-actors/actors/abi/big"
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	runtime "github.com/filecoin-project/specs-actors/actors/runtime"
+	checked "github.com/filecoin-project/specs-actors/actors/runtime/checked"
+	exitcode "github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+)
+
+func TestCallerValidation(t *testing.T) {
+	t.Run("validated once", func(t *testing.T) {
+		rt := checked.NewRuntime(newFakeRuntime())
+		assert.Equal(t, exitcode.Ok, run(func() {
+			rt.ValidateImmediateCallerIs(builtin.SystemActorAddr)
+			rt.CheckReturn()
+		}))
+	})
+
+	t.Run("not validated", func(t *testing.T) {
+		rt := checked.NewRuntime(newFakeRuntime())
+		assert.Equal(t, checked.ErrViolation, run(rt.CheckReturn))
+	})
+
+	t.Run("validated twice", func(t *testing.T) {
+		rt := checked.NewRuntime(newFakeRuntime())
+		assert.Equal(t, checked.ErrViolation, run(func() {
+			rt.ValidateImmediateCallerAcceptAny()
+			rt.ValidateImmediateCallerType(builtin.AccountActorCodeID)
+		}))
+	})
+}
+
+func TestStateAccess(t *testing.T) {
+	t.Run("transaction", func(t *testing.T) {
+		rt := checked.NewRuntime(newFakeRuntime())
+		assert.Equal(t, exitcode.Ok, run(func() {
+			rt.ValidateImmediateCallerAcceptAny()
+			var st cbg.CborInt
+			rt.State().Transaction(&st, func() {
+				st = 5
+			})
+			var st2 cbg.CborInt
+			rt.State().Readonly(&st2)
+			assert.Equal(t, cbg.CborInt(5), st2)
+			rt.CheckReturn()
+		}))
+	})
+
+	t.Run("readonly state mutated", func(t *testing.T) {
+		rt := checked.NewRuntime(newFakeRuntime())
+		assert.Equal(t, checked.ErrViolation, run(func() {
+			rt.ValidateImmediateCallerAcceptAny()
+			var st cbg.CborInt
+			rt.State().Readonly(&st)
+			st = 5
+			rt.CheckReturn()
+		}))
+	})
+
+	t.Run("readonly state mutated before transaction", func(t *testing.T) {
+		rt := checked.NewRuntime(newFakeRuntime())
+		assert.Equal(t, checked.ErrViolation, run(func() {
+			var st cbg.CborInt
+			rt.State().Readonly(&st)
+			st = 5
+			rt.State().Transaction(&st, func() {})
+		}))
+	})
+
+	t.Run("readonly state reloaded by transaction", func(t *testing.T) {
+		rt := checked.NewRuntime(newFakeRuntime())
+		assert.Equal(t, exitcode.Ok, run(func() {
+			rt.ValidateImmediateCallerAcceptAny()
+			var st cbg.CborInt
+			rt.State().Readonly(&st)
+			rt.State().Transaction(&st, func() {
+				st = 5
+			})
+			rt.CheckReturn()
+		}))
+	})
+
+	t.Run("state mutated after transaction", func(t *testing.T) {
+		rt := checked.NewRuntime(newFakeRuntime())
+		assert.Equal(t, checked.ErrViolation, run(func() {
+			rt.ValidateImmediateCallerAcceptAny()
+			var st cbg.CborInt
+			rt.State().Transaction(&st, func() {
+				st = 5
+			})
+			st = 6
+			rt.CheckReturn()
+		}))
+	})
+}
+
+func TestSend(t *testing.T) {
+	t.Run("outside transaction", func(t *testing.T) {
+		fake := newFakeRuntime()
+		rt := checked.NewRuntime(fake)
+		assert.Equal(t, exitcode.Ok, run(func() {
+			rt.Send(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, big.Zero())
+		}))
+		assert.Equal(t, 1, fake.sends)
+	})
+
+	t.Run("inside transaction", func(t *testing.T) {
+		fake := newFakeRuntime()
+		rt := checked.NewRuntime(fake)
+		assert.Equal(t, checked.ErrViolation, run(func() {
+			var st cbg.CborInt
+			rt.State().Transaction(&st, func() {
+				rt.Send(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, big.Zero())
+			})
+		}))
+		assert.Equal(t, 0, fake.sends)
+	})
+}
+
+// Runs a function, recovering an abort into its exit code.
+func run(f func()) (code exitcode.ExitCode) {
+	defer func() {
+		if r := recover(); r != nil {
+			code = r.(runtime.Abort).Code
+		}
+	}()
+	f()
+	return exitcode.Ok
+}
+
+// A runtime implementing only the methods exercised by the checked runtime, holding state in memory.
+type fakeRuntime struct {
+	runtime.Runtime
+	state []byte
+	sends int
+}
+
+func newFakeRuntime() *fakeRuntime {
+	return &fakeRuntime{state: []byte{0}}
+}
+
+func (rt *fakeRuntime) ValidateImmediateCallerAcceptAny()           {}
+func (rt *fakeRuntime) ValidateImmediateCallerIs(_ ...addr.Address) {}
+func (rt *fakeRuntime) ValidateImmediateCallerType(_ ...cid.Cid)    {}
+
+func (rt *fakeRuntime) Abortf(code exitcode.ExitCode, msg string, args ...interface{}) {
+	runtime.Abortf(code, msg, args...)
+}
+
+func (rt *fakeRuntime) Send(_ addr.Address, _ abi.MethodNum, _ runtime.CBORMarshaler, _ abi.TokenAmount) (runtime.SendReturn, exitcode.ExitCode) {
+	rt.sends++
+	return nil, exitcode.Ok
+}
+
+func (rt *fakeRuntime) State() runtime.StateHandle {
+	return rt
+}
+
+func (rt *fakeRuntime) Create(obj runtime.CBORMarshaler) {
+	rt.put(obj)
+}
+
+func (rt *fakeRuntime) Readonly(obj runtime.CBORUnmarshaler) {
+	if err := obj.UnmarshalCBOR(bytes.NewReader(rt.state)); err != nil {
+		panic(err)
+	}
+}
+
+func (rt *fakeRuntime) Transaction(obj runtime.CBORer, f func()) {
+	rt.Readonly(obj)
+	f()
+	rt.put(obj)
+}
+
+func (rt *fakeRuntime) put(obj runtime.CBORMarshaler) {
+	buf := bytes.Buffer{}
+	if err := obj.MarshalCBOR(&buf); err != nil {
+		panic(err)
+	}
+	rt.state = buf.Bytes()
+}
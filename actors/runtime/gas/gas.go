@@ -0,0 +1,117 @@
+package gas
+
+import (
+	"fmt"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	crypto "github.com/filecoin-project/specs-actors/actors/crypto"
+)
+
+// The maximum gas that may be consumed by all messages in a block.
+const BlockGasLimit = 10_000_000_000
+
+// A GasCharge is the cost of a single operation, separated into computation and storage components.
+// The virtual components are not counted toward execution cost. They support observing the global change in
+// total gas charged if the price of an operation were to change.
+type GasCharge struct {
+	Name  string
+	Extra interface{}
+
+	ComputeGas int64
+	StorageGas int64
+
+	VirtualCompute int64
+	VirtualStorage int64
+}
+
+// The total gas charged.
+func (g GasCharge) Total() int64 {
+	return g.ComputeGas + g.StorageGas
+}
+
+// The total virtual gas charged.
+func (g GasCharge) VirtualTotal() int64 {
+	return g.VirtualCompute + g.VirtualStorage
+}
+
+// Returns a charge with the given virtual components.
+func (g GasCharge) WithVirtual(compute, storage int64) GasCharge {
+	out := g
+	out.VirtualCompute = compute
+	out.VirtualStorage = storage
+	return out
+}
+
+// Returns a charge with extra data for tracing.
+func (g GasCharge) WithExtra(extra interface{}) GasCharge {
+	out := g
+	out.Extra = extra
+	return out
+}
+
+func (g GasCharge) String() string {
+	return fmt.Sprintf("%s(compute: %d, storage: %d)", g.Name, g.ComputeGas, g.StorageGas)
+}
+
+func NewGasCharge(name string, computeGas int64, storageGas int64) GasCharge {
+	return GasCharge{
+		Name:       name,
+		ComputeGas: computeGas,
+		StorageGas: storageGas,
+	}
+}
+
+// Pricelist provides prices for operations in the VM.
+//
+// Note: this interface should be APPEND ONLY since last chain checkpoint
+type Pricelist interface {
+	// OnChainMessage returns the gas used for storing a message of a given size in the chain.
+	OnChainMessage(msgSize int) GasCharge
+	// OnChainReturnValue returns the gas used for storing the response of a message in the chain.
+	OnChainReturnValue(dataSize int) GasCharge
+
+	// OnMethodInvocation returns the gas used when invoking a method.
+	OnMethodInvocation(value abi.TokenAmount, methodNum abi.MethodNum) GasCharge
+
+	// OnIpldGet returns the gas used for loading an object of a given size from the store.
+	OnIpldGet(dataSize int) GasCharge
+	// OnIpldPut returns the gas used for storing an object of a given size.
+	OnIpldPut(dataSize int) GasCharge
+
+	// OnCreateActor returns the gas used for creating an actor.
+	OnCreateActor() GasCharge
+	// OnDeleteActor returns the gas used for deleting an actor.
+	OnDeleteActor() GasCharge
+
+	OnVerifySignature(sigType crypto.SigType, planTextSize int) (GasCharge, error)
+	OnHashing(dataSize int) GasCharge
+	OnComputeUnsealedSectorCid(proofType abi.RegisteredSealProof, pieces []abi.PieceInfo) GasCharge
+	OnVerifySeal(info abi.SealVerifyInfo) GasCharge
+	OnVerifyPost(info abi.WindowPoStVerifyInfo) GasCharge
+	OnVerifyConsensusFault() GasCharge
+}
+
+// A pricelist and the epoch from which it applies.
+type scheduledPricelist struct {
+	epoch     abi.ChainEpoch
+	pricelist Pricelist
+}
+
+// The schedule of pricelists, in increasing order of the epoch from which each applies.
+// A new pricelist is appended here, rather than modifying a prior one, when prices change in a network upgrade.
+var schedule = []scheduledPricelist{
+	{0, &pricelistV0},
+}
+
+// PricelistByEpoch finds the latest prices for the given epoch.
+func PricelistByEpoch(epoch abi.ChainEpoch) Pricelist {
+	// The applicable pricelist is the one with the highest epoch that is lower than or equal to `epoch`.
+	best := schedule[0].pricelist
+	for _, s := range schedule {
+		if s.epoch > epoch {
+			break
+		}
+		best = s.pricelist
+	}
+	return best
+}
@@ -0,0 +1,79 @@
+package gas_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	big "github.com/filecoin-project/specs-actors/actors/abi/big"
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	crypto "github.com/filecoin-project/specs-actors/actors/crypto"
+	gas "github.com/filecoin-project/specs-actors/actors/runtime/gas"
+)
+
+func TestPricelist(t *testing.T) {
+	pl := gas.PricelistByEpoch(0)
+	assert.Equal(t, pl, gas.PricelistByEpoch(1_000_000))
+
+	t.Run("method invocation", func(t *testing.T) {
+		send := pl.OnMethodInvocation(big.Zero(), builtin.MethodSend).Total()
+		transfer := pl.OnMethodInvocation(abi.NewTokenAmount(1), builtin.MethodSend).Total()
+		invoke := pl.OnMethodInvocation(big.Zero(), builtin.MethodsMiner.SubmitWindowedPoSt).Total()
+		assert.Greater(t, send, int64(0))
+		assert.Greater(t, transfer, send)
+		assert.Less(t, invoke, send)
+	})
+
+	t.Run("storage scales with size", func(t *testing.T) {
+		assert.Equal(t, pl.OnIpldGet(0), pl.OnIpldGet(1000))
+		assert.Greater(t, pl.OnIpldPut(1000).Total(), pl.OnIpldPut(10).Total())
+		assert.Greater(t, pl.OnChainMessage(1000).Total(), pl.OnChainMessage(10).Total())
+	})
+
+	t.Run("deletion refunds creation storage", func(t *testing.T) {
+		assert.Equal(t, -pl.OnCreateActor().StorageGas, pl.OnDeleteActor().StorageGas)
+	})
+
+	t.Run("signatures", func(t *testing.T) {
+		bls, err := pl.OnVerifySignature(crypto.SigTypeBLS, 100)
+		assert.NoError(t, err)
+		secp, err := pl.OnVerifySignature(crypto.SigTypeSecp256k1, 100)
+		assert.NoError(t, err)
+		assert.Greater(t, bls.Total(), secp.Total())
+
+		_, err = pl.OnVerifySignature(crypto.SigType(99), 100)
+		assert.Error(t, err)
+	})
+
+	t.Run("post scales with challenged sectors", func(t *testing.T) {
+		info := abi.WindowPoStVerifyInfo{
+			Proofs: []abi.PoStProof{{PoStProof: abi.RegisteredPoStProof_StackedDrgWindow32GiBV1}},
+		}
+		one := pl.OnVerifyPost(info).Total()
+		info.ChallengedSectors = make([]abi.SectorInfo, 10)
+		ten := pl.OnVerifyPost(info).Total()
+		assert.Greater(t, ten, one)
+	})
+}
+
+func TestTracker(t *testing.T) {
+	tracker := gas.NewTracker(100)
+	assert.True(t, tracker.TryCharge(gas.NewGasCharge("a", 60, 0)))
+	assert.False(t, tracker.Exhausted())
+	assert.Equal(t, int64(60), tracker.Used())
+
+	assert.True(t, tracker.TryCharge(gas.NewGasCharge("b", 20, 20)))
+	assert.Equal(t, int64(100), tracker.Used())
+	assert.False(t, tracker.Exhausted())
+
+	assert.False(t, tracker.TryCharge(gas.NewGasCharge("c", 1, 0)))
+	assert.True(t, tracker.Exhausted())
+	assert.Equal(t, int64(100), tracker.Used())
+	assert.Len(t, tracker.Charges(), 3)
+
+	// virtual gas is not counted
+	tracker = gas.NewTracker(10)
+	assert.True(t, tracker.TryCharge(gas.NewGasCharge("v", 0, 0).WithVirtual(100, 100)))
+	assert.Equal(t, int64(0), tracker.Used())
+}
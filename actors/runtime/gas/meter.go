@@ -0,0 +1,222 @@
+package gas
+
+import (
+	"bytes"
+
+	addr "github.com/filecoin-project/go-address"
+	cid "github.com/ipfs/go-cid"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	crypto "github.com/filecoin-project/specs-actors/actors/crypto"
+	runtime "github.com/filecoin-project/specs-actors/actors/runtime"
+	exitcode "github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+)
+
+// Tracker accumulates gas charged against a limit.
+// A single tracker is shared by all the invocations made in the course of processing a top-level message.
+type Tracker struct {
+	limit     int64
+	used      int64
+	exhausted bool
+	charges   []GasCharge
+}
+
+func NewTracker(limit int64) *Tracker {
+	return &Tracker{limit: limit}
+}
+
+// The gas limit.
+func (t *Tracker) Limit() int64 {
+	return t.limit
+}
+
+// The total gas charged so far, which never exceeds the limit.
+func (t *Tracker) Used() int64 {
+	return t.used
+}
+
+// The charges made so far, in order.
+func (t *Tracker) Charges() []GasCharge {
+	return t.charges
+}
+
+// Whether a charge has been refused for exceeding the limit.
+func (t *Tracker) Exhausted() bool {
+	return t.exhausted
+}
+
+// Adds a charge to the gas used, returning false if doing so would exceed the limit.
+// When the limit would be exceeded, the gas used is set to the limit.
+func (t *Tracker) TryCharge(charge GasCharge) bool {
+	t.charges = append(t.charges, charge)
+	toUse := charge.Total()
+	if t.used+toUse > t.limit {
+		t.used = t.limit
+		t.exhausted = true
+		return false
+	}
+	t.used += toUse
+	return true
+}
+
+// Wraps a runtime so that operations are charged to a tracker, according to a pricelist.
+// The wrapped runtime aborts with exitcode.SysErrOutOfGas when the tracker's limit is exceeded.
+//
+// Store and state access is charged according to the serialized size of the objects read and written.
+// Sends are charged for the invocation only: the invoked actor must be wrapped with the same tracker by the
+// runtime implementation for its execution to be charged.
+// BatchVerifySeals is not charged here, since the power actor charges for each seal as it is submitted.
+func NewMeteredRuntime(rt runtime.Runtime, pricelist Pricelist, tracker *Tracker) runtime.Runtime {
+	return &meteredRuntime{
+		Runtime:   rt,
+		pricelist: pricelist,
+		tracker:   tracker,
+	}
+}
+
+type meteredRuntime struct {
+	runtime.Runtime
+	pricelist Pricelist
+	tracker   *Tracker
+}
+
+var _ runtime.Runtime = (*meteredRuntime)(nil)
+
+func (rt *meteredRuntime) charge(charge GasCharge) {
+	if !rt.tracker.TryCharge(charge) {
+		rt.Runtime.Abortf(exitcode.SysErrOutOfGas, "not enough gas: used=%d, limit=%d, charge=%s",
+			rt.tracker.Used(), rt.tracker.Limit(), charge)
+	}
+}
+
+func (rt *meteredRuntime) ChargeGas(name string, gas int64, virtual int64) {
+	rt.charge(NewGasCharge(name, gas, 0).WithVirtual(virtual, 0))
+	rt.Runtime.ChargeGas(name, gas, virtual)
+}
+
+func (rt *meteredRuntime) Send(toAddr addr.Address, methodNum abi.MethodNum, params runtime.CBORMarshaler, value abi.TokenAmount) (runtime.SendReturn, exitcode.ExitCode) {
+	rt.charge(rt.pricelist.OnMethodInvocation(value, methodNum))
+	return rt.Runtime.Send(toAddr, methodNum, params, value)
+}
+
+func (rt *meteredRuntime) CreateActor(codeID cid.Cid, address addr.Address) {
+	rt.charge(rt.pricelist.OnCreateActor())
+	rt.Runtime.CreateActor(codeID, address)
+}
+
+func (rt *meteredRuntime) DeleteActor(beneficiary addr.Address) {
+	rt.charge(rt.pricelist.OnDeleteActor())
+	rt.Runtime.DeleteActor(beneficiary)
+}
+
+func (rt *meteredRuntime) Store() runtime.Store {
+	return &meteredStore{rt: rt, inner: rt.Runtime.Store()}
+}
+
+func (rt *meteredRuntime) State() runtime.StateHandle {
+	return &meteredStateHandle{rt: rt, inner: rt.Runtime.State()}
+}
+
+func (rt *meteredRuntime) Syscalls() runtime.Syscalls {
+	return &meteredSyscalls{rt: rt, inner: rt.Runtime.Syscalls()}
+}
+
+type meteredStore struct {
+	rt    *meteredRuntime
+	inner runtime.Store
+}
+
+func (s *meteredStore) Get(c cid.Cid, o runtime.CBORUnmarshaler) bool {
+	found := s.inner.Get(c, o)
+	if found {
+		s.rt.charge(s.rt.pricelist.OnIpldGet(serializedSize(o)))
+	} else {
+		s.rt.charge(s.rt.pricelist.OnIpldGet(0))
+	}
+	return found
+}
+
+func (s *meteredStore) Put(x runtime.CBORMarshaler) cid.Cid {
+	s.rt.charge(s.rt.pricelist.OnIpldPut(serializedSize(x)))
+	return s.inner.Put(x)
+}
+
+type meteredStateHandle struct {
+	rt    *meteredRuntime
+	inner runtime.StateHandle
+}
+
+func (h *meteredStateHandle) Create(obj runtime.CBORMarshaler) {
+	h.rt.charge(h.rt.pricelist.OnIpldPut(serializedSize(obj)))
+	h.inner.Create(obj)
+}
+
+func (h *meteredStateHandle) Readonly(obj runtime.CBORUnmarshaler) {
+	h.inner.Readonly(obj)
+	h.rt.charge(h.rt.pricelist.OnIpldGet(serializedSize(obj)))
+}
+
+func (h *meteredStateHandle) Transaction(obj runtime.CBORer, f func()) {
+	h.inner.Transaction(obj, func() {
+		// The state has been loaded but not yet mutated.
+		h.rt.charge(h.rt.pricelist.OnIpldGet(serializedSize(obj)))
+		f()
+	})
+	h.rt.charge(h.rt.pricelist.OnIpldPut(serializedSize(obj)))
+}
+
+type meteredSyscalls struct {
+	rt    *meteredRuntime
+	inner runtime.Syscalls
+}
+
+func (s *meteredSyscalls) VerifySignature(signature crypto.Signature, signer addr.Address, plaintext []byte) error {
+	charge, err := s.rt.pricelist.OnVerifySignature(signature.Type, len(plaintext))
+	if err != nil {
+		return err
+	}
+	s.rt.charge(charge)
+	return s.inner.VerifySignature(signature, signer, plaintext)
+}
+
+func (s *meteredSyscalls) HashBlake2b(data []byte) [32]byte {
+	s.rt.charge(s.rt.pricelist.OnHashing(len(data)))
+	return s.inner.HashBlake2b(data)
+}
+
+func (s *meteredSyscalls) ComputeUnsealedSectorCID(reg abi.RegisteredSealProof, pieces []abi.PieceInfo) (cid.Cid, error) {
+	s.rt.charge(s.rt.pricelist.OnComputeUnsealedSectorCid(reg, pieces))
+	return s.inner.ComputeUnsealedSectorCID(reg, pieces)
+}
+
+func (s *meteredSyscalls) VerifySeal(vi abi.SealVerifyInfo) error {
+	s.rt.charge(s.rt.pricelist.OnVerifySeal(vi))
+	return s.inner.VerifySeal(vi)
+}
+
+func (s *meteredSyscalls) BatchVerifySeals(vis map[addr.Address][]abi.SealVerifyInfo) (map[addr.Address][]bool, error) {
+	return s.inner.BatchVerifySeals(vis)
+}
+
+func (s *meteredSyscalls) VerifyPoSt(vi abi.WindowPoStVerifyInfo) error {
+	s.rt.charge(s.rt.pricelist.OnVerifyPost(vi))
+	return s.inner.VerifyPoSt(vi)
+}
+
+func (s *meteredSyscalls) VerifyConsensusFault(h1, h2, extra []byte) (*runtime.ConsensusFault, error) {
+	s.rt.charge(s.rt.pricelist.OnVerifyConsensusFault())
+	return s.inner.VerifyConsensusFault(h1, h2, extra)
+}
+
+// Returns the size of an object's serialization, or zero if it cannot be serialized.
+func serializedSize(o interface{}) int {
+	m, ok := o.(runtime.CBORMarshaler)
+	if !ok {
+		return 0
+	}
+	buf := bytes.Buffer{}
+	if err := m.MarshalCBOR(&buf); err != nil {
+		return 0
+	}
+	return buf.Len()
+}
@@ -0,0 +1,252 @@
+package gas
+
+import (
+	"fmt"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	crypto "github.com/filecoin-project/specs-actors/actors/crypto"
+)
+
+// The prices in effect from genesis.
+var pricelistV0 = pricelistV0Params{
+	storageGasMulti: 1000,
+
+	onChainMessageComputeBase:    38863,
+	onChainMessageStorageBase:    36,
+	onChainMessageStoragePerByte: 1,
+
+	onChainReturnValuePerByte: 1,
+
+	sendBase:                29233,
+	sendTransferFunds:       27500,
+	sendTransferOnlyPremium: 159672,
+	sendInvokeMethod:        -5377,
+
+	ipldGetBase:    75242,
+	ipldPutBase:    84070,
+	ipldPutPerByte: 1,
+
+	createActorCompute: 1108454,
+	createActorStorage: 36 + 40,
+	deleteActor:        -(36 + 40), // -createActorStorage
+
+	verifySignature: map[crypto.SigType]int64{
+		crypto.SigTypeBLS:       16598605,
+		crypto.SigTypeSecp256k1: 1637292,
+	},
+
+	hashingBase:                  31355,
+	computeUnsealedSectorCidBase: 98647,
+	verifySealBase:               2000,
+	verifyPostLookup: map[abi.RegisteredPoStProof]scalingCost{
+		abi.RegisteredPoStProof_StackedDrgWindow512MiBV1: {
+			flat:  123861062,
+			scale: 9226981,
+		},
+		abi.RegisteredPoStProof_StackedDrgWindow32GiBV1: {
+			flat:  748593537,
+			scale: 85639,
+		},
+		abi.RegisteredPoStProof_StackedDrgWindow64GiBV1: {
+			flat:  748593537,
+			scale: 85639,
+		},
+	},
+	verifyPostDiscount:   true,
+	verifyConsensusFault: 495422,
+}
+
+type scalingCost struct {
+	flat  int64
+	scale int64
+}
+
+type pricelistV0Params struct {
+	// Multiplier applied to the storage component of all charges.
+	storageGasMulti int64
+
+	///////////////////////////////////////////////////////////////////////////
+	// System operations
+	///////////////////////////////////////////////////////////////////////////
+
+	// Gas cost charged to the originator of an on-chain message (regardless of
+	// whether it succeeds or fails in application) is given by:
+	//   OnChainMessageBase + len(serialized message)*OnChainMessagePerByte
+	// Together, these account for the cost of message propagation and validation,
+	// up to but excluding any actual processing by the VM.
+	// This is the cost a block producer burns when including an invalid message.
+	onChainMessageComputeBase    int64
+	onChainMessageStorageBase    int64
+	onChainMessageStoragePerByte int64
+
+	// Gas cost charged to the originator of a non-nil return value produced
+	// by an on-chain message is given by:
+	//   len(return value)*OnChainReturnValuePerByte
+	onChainReturnValuePerByte int64
+
+	// Gas cost for any message send execution (including the top-level one
+	// initiated by an on-chain message).
+	// This accounts for the cost of loading sender and receiver actors and
+	// (for top-level messages) incrementing the sender's sequence number.
+	// Load and store of actor sub-state is charged separately.
+	sendBase int64
+
+	// Gas cost charged, in addition to SendBase, if a message send
+	// is accompanied by any nonzero currency amount.
+	// Accounts for writing receiver's new balance (the sender's state is
+	// already accounted for).
+	sendTransferFunds int64
+
+	// Gas cost charged, in addition to SendBase, if message only transfers funds.
+	sendTransferOnlyPremium int64
+
+	// Gas cost charged, in addition to SendBase, if a message invokes
+	// a method on the receiver.
+	// Accounts for the cost of loading receiver code and method dispatch.
+	sendInvokeMethod int64
+
+	// Gas cost for any Get operation to the IPLD store
+	// in the runtime VM context.
+	ipldGetBase int64
+
+	// Gas cost (Base + len*PerByte) for any Put operation to the IPLD store
+	// in the runtime VM context.
+	//
+	// Note: these costs should be significantly higher than the costs for Get
+	// operations, since they reflect not only serialization/deserialization
+	// but also persistent storage of chain data.
+	ipldPutBase    int64
+	ipldPutPerByte int64
+
+	// Gas cost for creating a new actor (via InitActor's Exec method).
+	//
+	// Note: this costs assume that the extra will be partially or totally refunded while
+	// the base is covering for the put.
+	createActorCompute int64
+	createActorStorage int64
+
+	// Gas cost for deleting an actor.
+	//
+	// Note: this partially refunds the create cost to incentivise the deletion of the actors.
+	deleteActor int64
+
+	verifySignature map[crypto.SigType]int64
+
+	hashingBase int64
+
+	computeUnsealedSectorCidBase int64
+	verifySealBase               int64
+	verifyPostLookup             map[abi.RegisteredPoStProof]scalingCost
+	verifyPostDiscount           bool
+	verifyConsensusFault         int64
+}
+
+var _ Pricelist = (*pricelistV0Params)(nil)
+
+func (pl *pricelistV0Params) OnChainMessage(msgSize int) GasCharge {
+	return NewGasCharge("OnChainMessage", pl.onChainMessageComputeBase,
+		(pl.onChainMessageStorageBase+pl.onChainMessageStoragePerByte*int64(msgSize))*pl.storageGasMulti)
+}
+
+func (pl *pricelistV0Params) OnChainReturnValue(dataSize int) GasCharge {
+	return NewGasCharge("OnChainReturnValue", 0, int64(dataSize)*pl.onChainReturnValuePerByte*pl.storageGasMulti)
+}
+
+func (pl *pricelistV0Params) OnMethodInvocation(value abi.TokenAmount, methodNum abi.MethodNum) GasCharge {
+	ret := pl.sendBase
+	extra := ""
+
+	if !value.Nil() && !value.IsZero() {
+		ret += pl.sendTransferFunds
+		if methodNum == builtin.MethodSend {
+			// transfer only
+			ret += pl.sendTransferOnlyPremium
+		}
+		extra += "t"
+	}
+
+	if methodNum != builtin.MethodSend {
+		extra += "i"
+		// running actors is cheaper because we hand over to actors
+		ret += pl.sendInvokeMethod
+	}
+	return NewGasCharge("OnMethodInvocation", ret, 0).WithExtra(extra)
+}
+
+func (pl *pricelistV0Params) OnIpldGet(_ int) GasCharge {
+	return NewGasCharge("OnIpldGet", pl.ipldGetBase, 0)
+}
+
+func (pl *pricelistV0Params) OnIpldPut(dataSize int) GasCharge {
+	return NewGasCharge("OnIpldPut", pl.ipldPutBase, int64(dataSize)*pl.ipldPutPerByte*pl.storageGasMulti).
+		WithExtra(dataSize)
+}
+
+func (pl *pricelistV0Params) OnCreateActor() GasCharge {
+	return NewGasCharge("OnCreateActor", pl.createActorCompute, pl.createActorStorage*pl.storageGasMulti)
+}
+
+func (pl *pricelistV0Params) OnDeleteActor() GasCharge {
+	return NewGasCharge("OnDeleteActor", 0, pl.deleteActor*pl.storageGasMulti)
+}
+
+func (pl *pricelistV0Params) OnVerifySignature(sigType crypto.SigType, planTextSize int) (GasCharge, error) {
+	cost, ok := pl.verifySignature[sigType]
+	if !ok {
+		return GasCharge{}, fmt.Errorf("cost function for signature type %d not supported", sigType)
+	}
+
+	sigName, _ := sigType.Name()
+	return NewGasCharge("OnVerifySignature", cost, 0).
+		WithExtra(map[string]interface{}{
+			"type": sigName,
+			"size": planTextSize,
+		}), nil
+}
+
+func (pl *pricelistV0Params) OnHashing(dataSize int) GasCharge {
+	return NewGasCharge("OnHashing", pl.hashingBase, 0).WithExtra(dataSize)
+}
+
+func (pl *pricelistV0Params) OnComputeUnsealedSectorCid(proofType abi.RegisteredSealProof, pieces []abi.PieceInfo) GasCharge {
+	return NewGasCharge("OnComputeUnsealedSectorCid", pl.computeUnsealedSectorCidBase, 0)
+}
+
+func (pl *pricelistV0Params) OnVerifySeal(info abi.SealVerifyInfo) GasCharge {
+	// Actors verify seals in batches through the power actor, so this price is nominal.
+	return NewGasCharge("OnVerifySeal", pl.verifySealBase, 0)
+}
+
+func (pl *pricelistV0Params) OnVerifyPost(info abi.WindowPoStVerifyInfo) GasCharge {
+	sectorSize := "unknown"
+	var proofType abi.RegisteredPoStProof
+
+	if len(info.Proofs) != 0 {
+		proofType = info.Proofs[0].PoStProof
+		ss, err := info.Proofs[0].PoStProof.SectorSize()
+		if err == nil {
+			sectorSize = ss.ShortString()
+		}
+	}
+
+	cost, ok := pl.verifyPostLookup[proofType]
+	if !ok {
+		cost = pl.verifyPostLookup[abi.RegisteredPoStProof_StackedDrgWindow512MiBV1]
+	}
+
+	gasUsed := cost.flat + int64(len(info.ChallengedSectors))*cost.scale
+	if pl.verifyPostDiscount {
+		gasUsed /= 2 // XXX: this is an artificial discount
+	}
+
+	return NewGasCharge("OnVerifyPost", gasUsed, 0).
+		WithExtra(map[string]interface{}{
+			"type": sectorSize,
+			"size": len(info.ChallengedSectors),
+		})
+}
+
+func (pl *pricelistV0Params) OnVerifyConsensusFault() GasCharge {
+	return NewGasCharge("OnVerifyConsensusFault", pl.verifyConsensusFault, 0)
+}
@@ -0,0 +1,159 @@
+package runtime
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+
+	cid "github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	exitcode "github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+)
+
+// Abort is the value with which a Runtime implementation's Abortf panics.
+// Panicking with an Abort allows the panic to be recovered into an exit code by an Invoker.
+type Abort struct {
+	Code exitcode.ExitCode
+	Msg  string
+}
+
+func (a Abort) Error() string {
+	return fmt.Sprintf("abort(%s): %s", a.Code, a.Msg)
+}
+
+// Panics with an Abort. Runtime implementations may use this to implement Abortf.
+func Abortf(code exitcode.ExitCode, msg string, args ...interface{}) {
+	panic(Abort{code, fmt.Sprintf(msg, args...)})
+}
+
+// The result of invoking an actor method.
+type InvokeResult struct {
+	// The value returned by the method, or nil if it aborted.
+	Value CBORMarshaler
+	// The serialized return value, or nil if the method aborted.
+	Return   []byte
+	ExitCode exitcode.ExitCode
+	// The abort message, if the method aborted.
+	Message string
+}
+
+// An Invoker dispatches method invocations to the actor implementations registered with it.
+// It decodes serialized parameters into each method's parameter type, recovers aborts into exit codes and
+// serializes return values.
+type Invoker struct {
+	actors map[cid.Cid][]reflect.Value // Exported methods, indexed by method number.
+}
+
+func NewInvoker() *Invoker {
+	return &Invoker{actors: map[cid.Cid][]reflect.Value{}}
+}
+
+// Registers an actor implementation for a code CID, validating the signatures of its exported methods.
+func (inv *Invoker) Register(code cid.Cid, actor abi.Invokee) error {
+	if _, ok := inv.actors[code]; ok {
+		return xerrors.Errorf("actor code %s already registered", code)
+	}
+	exports := actor.Exports()
+	methods := make([]reflect.Value, len(exports))
+	for i, export := range exports {
+		if export == nil {
+			continue
+		}
+		meth := reflect.ValueOf(export)
+		if err := VerifyMethodType(meth.Type()); err != nil {
+			return xerrors.Errorf("invalid method %d of actor %s: %w", i, code, err)
+		}
+		methods[i] = meth
+	}
+	inv.actors[code] = methods
+	return nil
+}
+
+// Whether an actor implementation is registered for a code CID.
+func (inv *Invoker) Registered(code cid.Cid) bool {
+	_, ok := inv.actors[code]
+	return ok
+}
+
+// Invokes a method of the actor registered for a code CID, passing the runtime and the decoded parameters.
+// Aborts are recovered into the result's exit code. Any other panic is propagated.
+func (inv *Invoker) Invoke(rt Runtime, code cid.Cid, method abi.MethodNum, params []byte) (result InvokeResult) {
+	methods, ok := inv.actors[code]
+	if !ok {
+		return abortResult(exitcode.SysErrorIllegalActor, "no actor registered for code %s", code)
+	}
+	if uint64(method) >= uint64(len(methods)) || !methods[method].IsValid() {
+		return abortResult(exitcode.SysErrInvalidMethod, "no method %d on actor %s", method, code)
+	}
+	meth := methods[method]
+
+	paramsType := meth.Type().In(1)
+	var arg reflect.Value
+	if len(params) == 0 && isEmptyStruct(paramsType.Elem()) {
+		// Parameter types of methods taking no params are typed nil pointers.
+		arg = reflect.Zero(paramsType)
+	} else {
+		arg = reflect.New(paramsType.Elem())
+		if err := arg.Interface().(CBORUnmarshaler).UnmarshalCBOR(bytes.NewReader(params)); err != nil {
+			return abortResult(exitcode.SysErrInvalidParameters, "failed to unmarshal params into %s: %s", paramsType, err)
+		}
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			a, ok := r.(Abort)
+			if !ok {
+				panic(r)
+			}
+			result = abortResult(a.Code, "%s", a.Msg)
+		}
+	}()
+
+	ret := meth.Call([]reflect.Value{reflect.ValueOf(&rt).Elem(), arg})[0].Interface().(CBORMarshaler)
+	buf := bytes.Buffer{}
+	if err := ret.MarshalCBOR(&buf); err != nil {
+		return abortResult(exitcode.SysErrSerialization, "failed to marshal return value of method %d: %s", method, err)
+	}
+	return InvokeResult{Value: ret, Return: buf.Bytes(), ExitCode: exitcode.Ok}
+}
+
+// Checks that a function has the signature of an exported actor method: it must take a Runtime and a pointer to
+// CBOR-unmarshalable params, and return a single CBOR-marshalable value.
+func VerifyMethodType(t reflect.Type) error {
+	if t.Kind() != reflect.Func {
+		return xerrors.Errorf("%v is not a function", t)
+	}
+	if t.NumIn() != 2 {
+		return xerrors.Errorf("exported method %v must have two parameters, got %v", t, t.NumIn())
+	}
+	if t.In(0) != typeOfRuntimeInterface {
+		return xerrors.Errorf("exported method first parameter must be runtime, got %v", t.In(0))
+	}
+	if t.In(1).Kind() != reflect.Ptr {
+		return xerrors.Errorf("exported method second parameter must be pointer to params, got %v", t.In(1))
+	}
+	if !t.In(1).Implements(typeOfCborUnmarshaler) {
+		return xerrors.Errorf("exported method second parameter must be CBOR-unmarshalable params, got %v", t.In(1))
+	}
+	if t.NumOut() != 1 {
+		return xerrors.Errorf("exported method must return a single value, got %v", t.NumOut())
+	}
+	if !t.Out(0).Implements(typeOfCborMarshaler) {
+		return xerrors.Errorf("exported method must return CBOR-marshalable value, got %v", t.Out(0))
+	}
+	return nil
+}
+
+func abortResult(code exitcode.ExitCode, msg string, args ...interface{}) InvokeResult {
+	return InvokeResult{ExitCode: code, Message: fmt.Sprintf(msg, args...)}
+}
+
+func isEmptyStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t.NumField() == 0
+}
+
+var typeOfRuntimeInterface = reflect.TypeOf((*Runtime)(nil)).Elem()
+var typeOfCborUnmarshaler = reflect.TypeOf((*CBORUnmarshaler)(nil)).Elem()
+var typeOfCborMarshaler = reflect.TypeOf((*CBORMarshaler)(nil)).Elem()
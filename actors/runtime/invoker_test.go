@@ -0,0 +1,126 @@
+package runtime_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	runtime "github.com/filecoin-project/specs-actors/actors/runtime"
+	exitcode "github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+	tutil "github.com/filecoin-project/specs-actors/support/testing"
+)
+
+var testCode = tutil.MakeCID("test actor", nil)
+
+type testActor struct{}
+
+func (a testActor) Exports() []interface{} {
+	return []interface{}{
+		1: a.Double,
+		2: a.Abort,
+		3: nil,
+		4: a.NoParams,
+		5: a.Panic,
+	}
+}
+
+func (a testActor) Double(_ runtime.Runtime, params *cbg.CborInt) *cbg.CborInt {
+	ret := *params * 2
+	return &ret
+}
+
+func (a testActor) Abort(_ runtime.Runtime, params *cbg.CborInt) *adt.EmptyValue {
+	runtime.Abortf(exitcode.ErrIllegalArgument, "bad value %d", *params)
+	return nil
+}
+
+func (a testActor) NoParams(_ runtime.Runtime, params *adt.EmptyValue) *cbg.CborInt {
+	ret := cbg.CborInt(1)
+	if params != nil {
+		ret = 0
+	}
+	return &ret
+}
+
+func (a testActor) Panic(_ runtime.Runtime, _ *adt.EmptyValue) *adt.EmptyValue {
+	panic("not an abort")
+}
+
+type badActor struct{}
+
+func (a badActor) Exports() []interface{} {
+	return []interface{}{
+		1: func(rt runtime.Runtime, x int) *adt.EmptyValue { return nil },
+	}
+}
+
+func TestInvoker(t *testing.T) {
+	inv := runtime.NewInvoker()
+	require.NoError(t, inv.Register(testCode, testActor{}))
+	assert.True(t, inv.Registered(testCode))
+
+	t.Run("decodes params and encodes return", func(t *testing.T) {
+		result := inv.Invoke(nil, testCode, 1, serialize(t, 21))
+		require.Equal(t, exitcode.Ok, result.ExitCode)
+		assert.Equal(t, serialize(t, 42), result.Return)
+		assert.Equal(t, cbg.CborInt(42), *result.Value.(*cbg.CborInt))
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		result := inv.Invoke(nil, testCode, 2, serialize(t, 7))
+		assert.Equal(t, exitcode.ErrIllegalArgument, result.ExitCode)
+		assert.Equal(t, "bad value 7", result.Message)
+		assert.Nil(t, result.Return)
+	})
+
+	t.Run("empty params are nil", func(t *testing.T) {
+		result := inv.Invoke(nil, testCode, 4, nil)
+		require.Equal(t, exitcode.Ok, result.ExitCode)
+		assert.Equal(t, cbg.CborInt(1), *result.Value.(*cbg.CborInt))
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		result := inv.Invoke(nil, testCode, 1, []byte{0xff})
+		assert.Equal(t, exitcode.SysErrInvalidParameters, result.ExitCode)
+		result = inv.Invoke(nil, testCode, 1, nil)
+		assert.Equal(t, exitcode.SysErrInvalidParameters, result.ExitCode)
+	})
+
+	t.Run("invalid method", func(t *testing.T) {
+		assert.Equal(t, exitcode.SysErrInvalidMethod, inv.Invoke(nil, testCode, 3, nil).ExitCode)
+		assert.Equal(t, exitcode.SysErrInvalidMethod, inv.Invoke(nil, testCode, 99, nil).ExitCode)
+	})
+
+	t.Run("unknown code", func(t *testing.T) {
+		assert.Equal(t, exitcode.SysErrorIllegalActor, inv.Invoke(nil, tutil.MakeCID("other", nil), 1, nil).ExitCode)
+	})
+
+	t.Run("other panics propagate", func(t *testing.T) {
+		assert.PanicsWithValue(t, "not an abort", func() {
+			inv.Invoke(nil, testCode, 5, nil)
+		})
+	})
+}
+
+func TestInvokerRegistration(t *testing.T) {
+	inv := runtime.NewInvoker()
+	assert.Error(t, inv.Register(testCode, badActor{}))
+	assert.False(t, inv.Registered(testCode))
+
+	require.NoError(t, inv.Register(testCode, testActor{}))
+	assert.Error(t, inv.Register(testCode, testActor{}))
+}
+
+func serialize(t *testing.T, i int64) []byte {
+	v := cbg.CborInt(i)
+	buf := bytes.Buffer{}
+	require.NoError(t, v.MarshalCBOR(&buf))
+	return buf.Bytes()
+}
+
+var _ abi.Invokee = testActor{}
@@ -0,0 +1,176 @@
+package states
+
+import (
+	"sort"
+
+	addr "github.com/filecoin-project/go-address"
+	cid "github.com/ipfs/go-cid"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	big "github.com/filecoin-project/specs-actors/actors/abi/big"
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	market "github.com/filecoin-project/specs-actors/actors/builtin/market"
+	miner "github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	power "github.com/filecoin-project/specs-actors/actors/builtin/power"
+	verifreg "github.com/filecoin-project/specs-actors/actors/builtin/verifreg"
+	runtime "github.com/filecoin-project/specs-actors/actors/runtime"
+)
+
+// Checks the internal invariants of every miner, market, power and verified registry actor in a state tree, then the
+// consistency of their states with each other, returning the violations found.
+// The epoch is that at which the state was computed. Deals past their end epoch may remain in the market until its
+// cron next processes them, so are not required to be in a sector.
+// An error is returned only if the tree cannot be traversed.
+func CheckStateInvariants(tree *Tree, epoch abi.ChainEpoch) (*builtin.MessageAccumulator, error) {
+	acc := &builtin.MessageAccumulator{}
+
+	minerSummaries := map[addr.Address]*miner.StateSummary{}
+	minerPledge := big.Zero()
+	var powerState *power.State
+	var powerSummary *power.StateSummary
+	var marketSummary *market.StateSummary
+	var verifregSummary *verifreg.StateSummary
+
+	if err := tree.ForEach(func(key addr.Address, actor *Actor) error {
+		switch actor.Code {
+		case builtin.StorageMinerActorCodeID:
+			var st miner.State
+			if loadState(acc, tree, key, actor.Head, &st) {
+				summary, msgs := miner.CheckStateInvariants(tree.Store, &st, actor.Balance)
+				acc.WithPrefix("miner %v: ", key).AddAll(msgs)
+				minerSummaries[key] = summary
+				minerPledge = big.Sum(minerPledge, st.InitialPledgeRequirement, st.LockedFunds)
+			}
+		case builtin.StorageMarketActorCodeID:
+			var st market.State
+			if loadState(acc, tree, key, actor.Head, &st) {
+				summary, msgs := market.CheckStateInvariants(tree.Store, &st, actor.Balance)
+				acc.WithPrefix("market: ").AddAll(msgs)
+				marketSummary = summary
+			}
+		case builtin.StoragePowerActorCodeID:
+			var st power.State
+			if loadState(acc, tree, key, actor.Head, &st) {
+				summary, msgs := power.CheckStateInvariants(tree.Store, &st)
+				acc.WithPrefix("power: ").AddAll(msgs)
+				powerState, powerSummary = &st, summary
+			}
+		case builtin.VerifiedRegistryActorCodeID:
+			var st verifreg.State
+			if loadState(acc, tree, key, actor.Head, &st) {
+				summary, msgs := verifreg.CheckStateInvariants(tree.Store, &st)
+				acc.WithPrefix("verified registry: ").AddAll(msgs)
+				verifregSummary = summary
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if powerSummary != nil {
+		checkMinersAgainstPower(acc, minerSummaries, powerSummary)
+		acc.Require(powerState.TotalPledgeCollateral.Equals(minerPledge),
+			"total pledge collateral %v differs from miners' initial pledge requirements plus locked funds %v",
+			powerState.TotalPledgeCollateral, minerPledge)
+	} else {
+		acc.Add("no power actor")
+	}
+	if marketSummary != nil {
+		checkDealsAgainstSectors(acc, minerSummaries, marketSummary, epoch)
+	} else {
+		acc.Add("no market actor")
+	}
+	if verifregSummary == nil {
+		acc.Add("no verified registry actor")
+	} else if marketSummary != nil {
+		checkVerifiedDeals(acc, marketSummary)
+	}
+	return acc, nil
+}
+
+func loadState(acc *builtin.MessageAccumulator, tree *Tree, a addr.Address, head cid.Cid, out runtime.CBORUnmarshaler) bool {
+	err := tree.Store.Get(tree.Store.Context(), head, out)
+	return acc.RequireNoError(err, "failed to load state of actor %v", a)
+}
+
+// Checks that each claim belongs to a miner, and equals the power of the miner's live sectors that are not faulty.
+func checkMinersAgainstPower(acc *builtin.MessageAccumulator, minerSummaries map[addr.Address]*miner.StateSummary,
+	powerSummary *power.StateSummary) {
+	for _, a := range sortedClaimAddresses(powerSummary.Claims) {
+		claim := powerSummary.Claims[a]
+		minerSummary, found := minerSummaries[a]
+		if !found {
+			acc.Addf("claim for %v has no miner actor", a)
+			continue
+		}
+		active := minerSummary.ActivePower()
+		acc.Require(claim.RawBytePower.Equals(active.Raw),
+			"miner %v raw byte power claim %v differs from active power %v", a, claim.RawBytePower, active.Raw)
+		acc.Require(claim.QualityAdjPower.Equals(active.QA),
+			"miner %v quality adjusted power claim %v differs from active power %v", a, claim.QualityAdjPower, active.QA)
+	}
+}
+
+// Checks that each activated deal that has neither been slashed nor ended is in a live sector of its provider, and
+// that the sector's terms agree with the deal's.
+// Sectors may include deals that have since ended and been removed from the market, so sectors' deals are not
+// required to be in the market.
+func checkDealsAgainstSectors(acc *builtin.MessageAccumulator, minerSummaries map[addr.Address]*miner.StateSummary,
+	marketSummary *market.StateSummary, epoch abi.ChainEpoch) {
+	for _, id := range sortedDealIDs(marketSummary.Deals) {
+		deal := marketSummary.Deals[id]
+		if deal.SectorStartEpoch == -1 {
+			continue // Not yet activated.
+		}
+		minerSummary, found := minerSummaries[deal.Provider]
+		if !found {
+			acc.Addf("provider %v of deal %d has no miner actor", deal.Provider, id)
+			continue
+		}
+		sectorDeal, found := minerSummary.Deals[id]
+		if !found {
+			acc.Require(deal.SlashEpoch != -1 || deal.EndEpoch <= epoch,
+				"active deal %d not in a live sector of provider %v", id, deal.Provider)
+			continue
+		}
+		acc.Require(deal.SectorStartEpoch == sectorDeal.SectorStart,
+			"deal %d sector start epoch %d differs from activation %d of sector %d", id, deal.SectorStartEpoch,
+			sectorDeal.SectorStart, sectorDeal.Sector)
+		acc.Require(deal.EndEpoch <= sectorDeal.SectorExpiration,
+			"deal %d end epoch %d after expiration %d of sector %d", id, deal.EndEpoch, sectorDeal.SectorExpiration,
+			sectorDeal.Sector)
+	}
+}
+
+// Checks that verified deals are of at least the minimum size for which data cap may be used.
+// The registry records only remaining data cap, so the cap used by each deal cannot be reconciled further.
+func checkVerifiedDeals(acc *builtin.MessageAccumulator, marketSummary *market.StateSummary) {
+	for _, id := range sortedDealIDs(marketSummary.Deals) {
+		deal := marketSummary.Deals[id]
+		if !deal.VerifiedDeal {
+			continue
+		}
+		size := big.NewIntUnsigned(uint64(deal.PieceSize))
+		acc.Require(size.GreaterThanEqual(verifreg.MinVerifiedDealSize),
+			"verified deal %d size %v below minimum verified deal size %v", id, size, verifreg.MinVerifiedDealSize)
+	}
+}
+
+func sortedClaimAddresses(claims map[addr.Address]power.Claim) []addr.Address {
+	addrs := make([]addr.Address, 0, len(claims))
+	for a := range claims { //nolint:nomaprange
+		addrs = append(addrs, a)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i].String() < addrs[j].String() })
+	return addrs
+}
+
+func sortedDealIDs(deals map[abi.DealID]*market.DealSummary) []abi.DealID {
+	ids := make([]abi.DealID, 0, len(deals))
+	for id := range deals { //nolint:nomaprange
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
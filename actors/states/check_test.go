@@ -0,0 +1,166 @@
+package states_test
+
+import (
+	"context"
+	"testing"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	big "github.com/filecoin-project/specs-actors/actors/abi/big"
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	market "github.com/filecoin-project/specs-actors/actors/builtin/market"
+	miner "github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	power "github.com/filecoin-project/specs-actors/actors/builtin/power"
+	crypto "github.com/filecoin-project/specs-actors/actors/crypto"
+	states "github.com/filecoin-project/specs-actors/actors/states"
+	"github.com/filecoin-project/specs-actors/support/driver"
+	tutil "github.com/filecoin-project/specs-actors/support/testing"
+	vm "github.com/filecoin-project/specs-actors/support/vm"
+)
+
+func TestCheckStateInvariants(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("singletons only", func(t *testing.T) {
+		v := vm.NewVMWithSingletons(ctx, t)
+		assertNoViolations(t, v)
+	})
+
+	t.Run("missing singletons", func(t *testing.T) {
+		tree := states.NewTree(vm.NewVMWithSingletons(ctx, t).Store())
+		acc, err := states.CheckStateInvariants(tree, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"no power actor", "no market actor", "no verified registry actor"}, acc.Messages())
+	})
+
+	t.Run("deal stored in a sector", func(t *testing.T) {
+		v := vm.NewVMWithSingletons(ctx, t)
+		addrs := vm.CreateAccounts(ctx, t, v, 2, big.Mul(big.NewInt(10_000), vm.FIL), 93837778)
+		worker, client := addrs[0], addrs[1]
+		minerAddr := createMiner(t, v, worker, big.Mul(big.NewInt(1_000), vm.FIL))
+		assertNoViolations(t, v)
+
+		collateral := big.Mul(big.NewInt(10), vm.FIL)
+		vm.ApplyOk(t, v, client, builtin.StorageMarketActorAddr, collateral, builtin.MethodsMarket.AddBalance, &client)
+		vm.ApplyOk(t, v, worker, builtin.StorageMarketActorAddr, collateral, builtin.MethodsMarket.AddBalance, &minerAddr)
+
+		// The deal starts after the sector has been proven, and lasts the minimum duration.
+		d := driver.NewDriver(v)
+		dealStart := d.Epoch() + miner.PreCommitChallengeDelay + 100
+		deal := market.DealProposal{
+			PieceCID:             tutil.MakeCID("piece", &market.PieceCIDPrefix),
+			PieceSize:            abi.PaddedPieceSize(2048),
+			Client:               client,
+			Provider:             minerAddr,
+			StartEpoch:           dealStart,
+			EndEpoch:             dealStart + 180*builtin.EpochsInDay,
+			StoragePricePerEpoch: abi.NewTokenAmount(10),
+			ProviderCollateral:   abi.NewTokenAmount(1_000),
+			ClientCollateral:     abi.NewTokenAmount(1_000),
+		}
+		ret := vm.ApplyOk(t, v, worker, builtin.StorageMarketActorAddr, big.Zero(), builtin.MethodsMarket.PublishStorageDeals,
+			&market.PublishStorageDealsParams{Deals: []market.ClientDealProposal{{
+				Proposal:        deal,
+				ClientSignature: crypto.Signature{Type: crypto.SigTypeBLS, Data: []byte("does not matter")},
+			}}})
+		var published market.PublishStorageDealsReturn
+		require.NoError(t, ret.Into(&published))
+		assertNoViolations(t, v)
+
+		sectorNo := abi.SectorNumber(100)
+		precommitEpoch := d.Epoch()
+		applyTipset(t, d, minerAddr, driver.Message{
+			From: worker, To: minerAddr, Value: big.Zero(), Method: builtin.MethodsMiner.PreCommitSector,
+			Params: &miner.SectorPreCommitInfo{
+				SealProof:     abi.RegisteredSealProof_StackedDrg32GiBV1,
+				SectorNumber:  sectorNo,
+				SealedCID:     tutil.MakeCID("sealed", &miner.SealedCIDPrefix),
+				SealRandEpoch: precommitEpoch - 1,
+				DealIDs:       published.IDs,
+				Expiration:    deal.EndEpoch + builtin.EpochsInDay,
+			},
+		})
+		assertNoViolations(t, v)
+		for d.Epoch() <= precommitEpoch+miner.PreCommitChallengeDelay {
+			applyTipset(t, d, minerAddr)
+			assertNoViolations(t, v)
+		}
+
+		// The proof is confirmed by the power actor's cron, in the same tipset.
+		applyTipset(t, d, minerAddr, driver.Message{
+			From: worker, To: minerAddr, Value: big.Zero(), Method: builtin.MethodsMiner.ProveCommitSector,
+			Params: &miner.ProveCommitSectorParams{SectorNumber: sectorNo},
+		})
+		var powerSt power.State
+		require.NoError(t, d.VM().GetState(builtin.StoragePowerActorAddr, &powerSt))
+		require.False(t, powerSt.TotalBytesCommitted.IsZero(), "sector not proven")
+
+		assertNoViolations(t, v)
+
+		// The deal's first payment is made at its start; without window PoSts the sector later becomes faulty.
+		// The state is checked at the end of each deadline.
+		for d.Epoch() < dealStart+miner.WPoStProvingPeriod {
+			applyTipset(t, d, minerAddr)
+			if d.Epoch()%miner.WPoStChallengeWindow == 0 {
+				assertNoViolations(t, v)
+			}
+		}
+		require.NoError(t, v.GetState(builtin.StoragePowerActorAddr, &powerSt))
+		require.True(t, powerSt.TotalBytesCommitted.IsZero(), "sector not faulty")
+	})
+
+	t.Run("inconsistent power", func(t *testing.T) {
+		v := vm.NewVMWithSingletons(ctx, t)
+		addrs := vm.CreateAccounts(ctx, t, v, 1, big.Mul(big.NewInt(10_000), vm.FIL), 93837778)
+		minerAddr := createMiner(t, v, addrs[0], big.Zero())
+
+		var st power.State
+		require.NoError(t, v.GetState(builtin.StoragePowerActorAddr, &st))
+		require.NoError(t, st.AddToClaim(v.Store(), minerAddr, big.NewInt(2048), big.NewInt(2048)))
+		st.TotalPledgeCollateral = abi.NewTokenAmount(1)
+		require.NoError(t, v.SetActorState(ctx, builtin.StoragePowerActorAddr, &st))
+
+		acc := checkState(t, v)
+		tutil.AssertMsgContains(t, acc, "miner "+minerAddr.String()+" raw byte power claim 2048 differs from active power 0")
+		tutil.AssertMsgContains(t, acc, "total pledge collateral 1 differs from miners' initial pledge requirements plus locked funds 0")
+	})
+}
+
+func createMiner(t *testing.T, v *vm.VM, owner addr.Address, value abi.TokenAmount) addr.Address {
+	params := power.CreateMinerParams{
+		Owner:         owner,
+		Worker:        owner,
+		SealProofType: abi.RegisteredSealProof_StackedDrg32GiBV1,
+		Peer:          abi.PeerID("not really a peer id"),
+	}
+	ret := vm.ApplyOk(t, v, owner, builtin.StoragePowerActorAddr, value, builtin.MethodsPower.CreateMiner, &params)
+	var minerAddrs power.CreateMinerReturn
+	require.NoError(t, ret.Into(&minerAddrs))
+	return minerAddrs.IDAddress
+}
+
+// Applies a tipset of one block, requiring its messages succeed.
+func applyTipset(t *testing.T, d *driver.Driver, blockMiner addr.Address, msgs ...driver.Message) {
+	results, err := d.ApplyTipset(driver.Block{Miner: blockMiner, WinCount: 1, Messages: msgs})
+	require.NoError(t, err)
+	for _, result := range results {
+		require.True(t, result.ExitCode.IsSuccess(), "message to %v method %d failed: %v", result.Message.To,
+			result.Message.Method, result.ExitCode)
+	}
+}
+
+func checkState(t *testing.T, v *vm.VM) *builtin.MessageAccumulator {
+	tree, err := states.LoadTree(v.Store(), v.StateRoot())
+	require.NoError(t, err)
+	acc, err := states.CheckStateInvariants(tree, v.GetEpoch())
+	require.NoError(t, err)
+	return acc
+}
+
+func assertNoViolations(t *testing.T, v *vm.VM) {
+	acc := checkState(t, v)
+	require.Empty(t, acc.Messages(), "state invariants violated at epoch %d", v.GetEpoch())
+}
@@ -0,0 +1,136 @@
+package states
+
+import (
+	addr "github.com/filecoin-project/go-address"
+	cid "github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+	"golang.org/x/xerrors"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	init_ "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+)
+
+// Value type for a map from ID addresses to actors in the state tree.
+type Actor struct {
+	Code       cid.Cid // The actor's code (type).
+	Head       cid.Cid // The root of the actor's state.
+	CallSeqNum uint64  // The number of messages sent by this actor (non-zero only for accounts).
+	Balance    abi.TokenAmount
+}
+
+// A specialization of a map of ID addresses to actors.
+// Keys are always ID addresses: callers must resolve other address protocols via ResolveAddress first.
+type Tree struct {
+	Map   *adt.Map
+	Store adt.Store
+}
+
+// Initializes a new, empty state tree backed by a store.
+func NewTree(store adt.Store) *Tree {
+	return &Tree{
+		Map:   adt.MakeEmptyMap(store),
+		Store: store,
+	}
+}
+
+// Loads a tree from a root CID and store.
+func LoadTree(store adt.Store, root cid.Cid) (*Tree, error) {
+	m, err := adt.AsMap(store, root)
+	if err != nil {
+		return nil, err
+	}
+	return &Tree{
+		Map:   m,
+		Store: store,
+	}, nil
+}
+
+// Writes the tree root node to the store, and returns its CID.
+func (t *Tree) Flush() (cid.Cid, error) {
+	return t.Map.Root()
+}
+
+// Loads the actor associated with an ID address.
+func (t *Tree) GetActor(a addr.Address) (*Actor, bool, error) {
+	if a.Protocol() != addr.ID {
+		return nil, false, xerrors.Errorf("non-ID address %v invalid as actor key", a)
+	}
+	var actor Actor
+	found, err := t.Map.Get(adt.AddrKey(a), &actor)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "failed to load actor %v", a)
+	}
+	if !found {
+		return nil, false, nil
+	}
+	return &actor, true, nil
+}
+
+// Sets the actor associated with an ID address, overwriting any existing actor.
+func (t *Tree) SetActor(a addr.Address, actor *Actor) error {
+	if a.Protocol() != addr.ID {
+		return xerrors.Errorf("non-ID address %v invalid as actor key", a)
+	}
+	if err := t.Map.Put(adt.AddrKey(a), actor); err != nil {
+		return errors.Wrapf(err, "failed to set actor %v", a)
+	}
+	return nil
+}
+
+// Removes the actor associated with an ID address.
+// It is an error to delete an actor that does not exist.
+func (t *Tree) DeleteActor(a addr.Address) error {
+	if a.Protocol() != addr.ID {
+		return xerrors.Errorf("non-ID address %v invalid as actor key", a)
+	}
+	if err := t.Map.Delete(adt.AddrKey(a)); err != nil {
+		return errors.Wrapf(err, "failed to delete actor %v", a)
+	}
+	return nil
+}
+
+// Resolves an address to an ID address via the init actor's address table.
+// ID addresses resolve to themselves, without checking that an actor exists.
+// Returns false if the address is not known to the init actor.
+func (t *Tree) ResolveAddress(a addr.Address) (addr.Address, bool, error) {
+	if a.Protocol() == addr.ID {
+		return a, true, nil
+	}
+
+	initActor, found, err := t.GetActor(builtin.InitActorAddr)
+	if err != nil {
+		return addr.Undef, false, err
+	}
+	if !found {
+		return addr.Undef, false, xerrors.Errorf("no init actor in state tree")
+	}
+
+	var initState init_.State
+	if err := t.Store.Get(t.Store.Context(), initActor.Head, &initState); err != nil {
+		return addr.Undef, false, errors.Wrapf(err, "failed to load init actor state")
+	}
+
+	idAddr, err := initState.ResolveAddress(t.Store, a)
+	if err == init_.ErrAddressNotFound {
+		return addr.Undef, false, nil
+	} else if err != nil {
+		return addr.Undef, false, errors.Wrapf(err, "failed to resolve address %v", a)
+	}
+	return idAddr, true, nil
+}
+
+// Traverses all entries in the tree, in an undefined order.
+func (t *Tree) ForEach(fn func(addr.Address, *Actor) error) error {
+	var actor Actor
+	return t.Map.ForEach(&actor, func(key string) error {
+		a, err := addr.NewFromBytes([]byte(key))
+		if err != nil {
+			return err
+		}
+		// Copy the actor so that callers may retain it across iterations.
+		actorCopy := actor
+		return fn(a, &actorCopy)
+	})
+}
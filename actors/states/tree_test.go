@@ -0,0 +1,140 @@
+package states_test
+
+import (
+	"context"
+	"testing"
+
+	addr "github.com/filecoin-project/go-address"
+	cid "github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	init_ "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	states "github.com/filecoin-project/specs-actors/actors/states"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/support/ipld"
+	tutil "github.com/filecoin-project/specs-actors/support/testing"
+)
+
+func TestTree(t *testing.T) {
+	t.Run("get, set and delete actors", func(t *testing.T) {
+		store := ipld.NewADTStore(context.Background())
+		tree := states.NewTree(store)
+		a := tutil.NewIDAddr(t, 101)
+
+		_, found, err := tree.GetActor(a)
+		require.NoError(t, err)
+		assert.False(t, found)
+
+		actor := newActor(builtin.AccountActorCodeID, 7, abi.NewTokenAmount(1000))
+		require.NoError(t, tree.SetActor(a, actor))
+
+		loaded, found, err := tree.GetActor(a)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, actor, loaded)
+
+		require.NoError(t, tree.DeleteActor(a))
+		_, found, err = tree.GetActor(a)
+		require.NoError(t, err)
+		assert.False(t, found)
+
+		// Deleting a missing actor fails.
+		assert.Error(t, tree.DeleteActor(a))
+	})
+
+	t.Run("rejects non-ID keys", func(t *testing.T) {
+		store := ipld.NewADTStore(context.Background())
+		tree := states.NewTree(store)
+		a := tutil.NewBLSAddr(t, 1)
+		actor := newActor(builtin.AccountActorCodeID, 0, abi.NewTokenAmount(0))
+
+		assert.Error(t, tree.SetActor(a, actor))
+		_, _, err := tree.GetActor(a)
+		assert.Error(t, err)
+		assert.Error(t, tree.DeleteActor(a))
+	})
+
+	t.Run("root is stable across flush and load", func(t *testing.T) {
+		store := ipld.NewADTStore(context.Background())
+		tree := states.NewTree(store)
+		a1 := tutil.NewIDAddr(t, 101)
+		a2 := tutil.NewIDAddr(t, 102)
+		require.NoError(t, tree.SetActor(a1, newActor(builtin.AccountActorCodeID, 1, abi.NewTokenAmount(1))))
+		require.NoError(t, tree.SetActor(a2, newActor(builtin.MultisigActorCodeID, 0, abi.NewTokenAmount(2))))
+
+		root, err := tree.Flush()
+		require.NoError(t, err)
+
+		loaded, err := states.LoadTree(store, root)
+		require.NoError(t, err)
+		loadedRoot, err := loaded.Flush()
+		require.NoError(t, err)
+		assert.Equal(t, root, loadedRoot)
+
+		balances := map[addr.Address]abi.TokenAmount{}
+		err = loaded.ForEach(func(a addr.Address, actor *states.Actor) error {
+			balances[a] = actor.Balance
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, map[addr.Address]abi.TokenAmount{
+			a1: abi.NewTokenAmount(1),
+			a2: abi.NewTokenAmount(2),
+		}, balances)
+
+		// The root changes with any actor field.
+		require.NoError(t, loaded.SetActor(a1, newActor(builtin.AccountActorCodeID, 2, abi.NewTokenAmount(1))))
+		changedRoot, err := loaded.Flush()
+		require.NoError(t, err)
+		assert.NotEqual(t, root, changedRoot)
+	})
+
+	t.Run("resolve address", func(t *testing.T) {
+		store := ipld.NewADTStore(context.Background())
+		tree := states.NewTree(store)
+		pubkey := tutil.NewSECP256K1Addr(t, "account")
+		idAddr := tutil.NewIDAddr(t, 101)
+
+		// Without an init actor, only ID addresses resolve.
+		resolved, found, err := tree.ResolveAddress(idAddr)
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, idAddr, resolved)
+		_, _, err = tree.ResolveAddress(pubkey)
+		assert.Error(t, err)
+
+		emptyMap, err := adt.MakeEmptyMap(store).Root()
+		require.NoError(t, err)
+		initState := init_.ConstructState(emptyMap, "test")
+		mapped, err := initState.MapAddressToNewID(store, pubkey)
+		require.NoError(t, err)
+		initHead, err := store.Put(context.Background(), initState)
+		require.NoError(t, err)
+		require.NoError(t, tree.SetActor(builtin.InitActorAddr, &states.Actor{
+			Code:    builtin.InitActorCodeID,
+			Head:    initHead,
+			Balance: abi.NewTokenAmount(0),
+		}))
+
+		resolved, found, err = tree.ResolveAddress(pubkey)
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, mapped, resolved)
+
+		_, found, err = tree.ResolveAddress(tutil.NewSECP256K1Addr(t, "unknown"))
+		require.NoError(t, err)
+		assert.False(t, found)
+	})
+}
+
+func newActor(code cid.Cid, callSeq uint64, balance abi.TokenAmount) *states.Actor {
+	return &states.Actor{
+		Code:       code,
+		Head:       tutil.MakeCID(code.String(), nil),
+		CallSeqNum: callSeq,
+		Balance:    balance,
+	}
+}
@@ -16,14 +16,23 @@ import (
 	reward "github.com/filecoin-project/specs-actors/actors/builtin/reward"
 	system "github.com/filecoin-project/specs-actors/actors/builtin/system"
 	verifreg "github.com/filecoin-project/specs-actors/actors/builtin/verifreg"
+	migration "github.com/filecoin-project/specs-actors/actors/migration"
 	puppet "github.com/filecoin-project/specs-actors/actors/puppet"
+	states "github.com/filecoin-project/specs-actors/actors/states"
 
 	smoothing "github.com/filecoin-project/specs-actors/actors/util/smoothing"
 )
 
-func main() {
+// A file of generated encoders, and the types for which encoders are generated.
+type target struct {
+	file  string
+	pkg   string
+	types []interface{}
+}
+
+var targets = []target{
 	// Common types
-	if err := gen.WriteTupleEncodersToFile("./actors/abi/cbor_gen.go", "abi",
+	{"./actors/abi/cbor_gen.go", "abi", []interface{}{
 		abi.PieceInfo{},
 		abi.SectorID{},
 		abi.SectorInfo{},
@@ -31,65 +40,44 @@ func main() {
 		abi.PoStProof{},
 		abi.WindowPoStVerifyInfo{},
 		abi.WinningPoStVerifyInfo{},
-	); err != nil {
-		panic(err)
-	}
-
-	if err := gen.WriteTupleEncodersToFile("./actors/builtin/cbor_gen.go", "builtin",
+	}},
+	{"./actors/builtin/cbor_gen.go", "builtin", []interface{}{
 		builtin.MinerAddrs{},
 		builtin.ConfirmSectorProofsParams{},
-	); err != nil {
-		panic(err)
-	}
-
+	}},
 	// Actors
-	if err := gen.WriteTupleEncodersToFile("./actors/builtin/system/cbor_gen.go", "system",
+	{"./actors/builtin/system/cbor_gen.go", "system", []interface{}{
 		// actor state
 		system.State{},
-	); err != nil {
-		panic(err)
-	}
-
-	if err := gen.WriteTupleEncodersToFile("./actors/builtin/account/cbor_gen.go", "account",
+	}},
+	{"./actors/builtin/account/cbor_gen.go", "account", []interface{}{
 		// actor state
 		account.State{},
-	); err != nil {
-		panic(err)
-	}
-
-	if err := gen.WriteTupleEncodersToFile("./actors/builtin/init/cbor_gen.go", "init",
+	}},
+	{"./actors/builtin/init/cbor_gen.go", "init", []interface{}{
 		// actor state
 		init_.State{},
 		// method params
 		init_.ConstructorParams{},
 		init_.ExecParams{},
 		init_.ExecReturn{},
-	); err != nil {
-		panic(err)
-	}
-
-	if err := gen.WriteTupleEncodersToFile("./actors/builtin/cron/cbor_gen.go", "cron",
+	}},
+	{"./actors/builtin/cron/cbor_gen.go", "cron", []interface{}{
 		// actor state
 		cron.State{},
 		cron.Entry{},
 		// method params
 		cron.ConstructorParams{},
-	); err != nil {
-		panic(err)
-	}
-
-	if err := gen.WriteTupleEncodersToFile("./actors/builtin/reward/cbor_gen.go", "reward",
+	}},
+	{"./actors/builtin/reward/cbor_gen.go", "reward", []interface{}{
 		// actor state
 		reward.State{},
 		// method params
 		reward.AwardBlockRewardParams{},
 		// method returns
 		reward.ThisEpochRewardReturn{},
-	); err != nil {
-		panic(err)
-	}
-
-	if err := gen.WriteTupleEncodersToFile("./actors/builtin/multisig/cbor_gen.go", "multisig",
+	}},
+	{"./actors/builtin/multisig/cbor_gen.go", "multisig", []interface{}{
 		// actor state
 		multisig.State{},
 		multisig.Transaction{},
@@ -105,11 +93,8 @@ func main() {
 		// method returns
 		multisig.ApproveReturn{},
 		multisig.ProposeReturn{},
-	); err != nil {
-		panic(err)
-	}
-
-	if err := gen.WriteTupleEncodersToFile("./actors/builtin/paych/cbor_gen.go", "paych",
+	}},
+	{"./actors/builtin/paych/cbor_gen.go", "paych", []interface{}{
 		// actor state
 		paych.State{},
 		paych.LaneState{},
@@ -120,11 +105,8 @@ func main() {
 		paych.SignedVoucher{},
 		paych.ModVerifyParams{},
 		paych.PaymentVerifyParams{},
-	); err != nil {
-		panic(err)
-	}
-
-	if err := gen.WriteTupleEncodersToFile("./actors/builtin/power/cbor_gen.go", "power",
+	}},
+	{"./actors/builtin/power/cbor_gen.go", "power", []interface{}{
 		// actors state
 		power.State{},
 		power.Claim{},
@@ -139,11 +121,8 @@ func main() {
 		// other types
 		power.MinerConstructorParams{},
 		power.SectorStorageWeightDesc{},
-	); err != nil {
-		panic(err)
-	}
-
-	if err := gen.WriteTupleEncodersToFile("./actors/builtin/market/cbor_gen.go", "market",
+	}},
+	{"./actors/builtin/market/cbor_gen.go", "market", []interface{}{
 		// actor state
 		market.State{},
 
@@ -161,11 +140,8 @@ func main() {
 		market.DealProposal{},
 		market.ClientDealProposal{},
 		market.DealState{},
-	); err != nil {
-		panic(err)
-	}
-
-	if err := gen.WriteTupleEncodersToFile("./actors/builtin/miner/cbor_gen.go", "miner",
+	}},
+	{"./actors/builtin/miner/cbor_gen.go", "miner", []interface{}{
 		// actor state
 		miner.State{},
 		miner.MinerInfo{},
@@ -202,11 +178,8 @@ func main() {
 		miner.ExpirationExtension{},
 		miner.TerminationDeclaration{},
 		miner.PoStPartition{},
-	); err != nil {
-		panic(err)
-	}
-
-	if err := gen.WriteTupleEncodersToFile("./actors/builtin/verifreg/cbor_gen.go", "verifreg",
+	}},
+	{"./actors/builtin/verifreg/cbor_gen.go", "verifreg", []interface{}{
 		// actor state
 		verifreg.State{},
 		// method params
@@ -215,24 +188,29 @@ func main() {
 		verifreg.UseBytesParams{},
 		verifreg.RestoreBytesParams{},
 		// other types
-	); err != nil {
-		panic(err)
-	}
-
-	if err := gen.WriteTupleEncodersToFile("./actors/puppet/cbor_gen.go", "puppet",
+	}},
+	{"./actors/puppet/cbor_gen.go", "puppet", []interface{}{
 		// actor state
 		puppet.State{},
 		// method params
 		puppet.SendParams{},
 		puppet.SendReturn{},
-	); err != nil {
-		panic(err)
-	}
-
-	if err := gen.WriteTupleEncodersToFile("./actors/util/smoothing/cbor_gen.go", "smoothing",
+	}},
+	{"./actors/util/smoothing/cbor_gen.go", "smoothing", []interface{}{
 		smoothing.FilterEstimate{},
-	); err != nil {
-		panic(err)
-	}
+	}},
+	{"./actors/states/cbor_gen.go", "states", []interface{}{
+		states.Actor{},
+	}},
+	{"./actors/migration/cbor_gen.go", "migration", []interface{}{
+		migration.Journal{},
+	}},
+}
 
+func main() {
+	for _, t := range targets {
+		if err := gen.WriteTupleEncodersToFile(t.file, t.pkg, t.types...); err != nil {
+			panic(err)
+		}
+	}
 }
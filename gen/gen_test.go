@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	addr "github.com/filecoin-project/go-address"
+	bitfield "github.com/filecoin-project/go-bitfield"
+	cid "github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	big "github.com/filecoin-project/specs-actors/actors/abi/big"
+	market "github.com/filecoin-project/specs-actors/actors/builtin/market"
+	miner "github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	crypto "github.com/filecoin-project/specs-actors/actors/crypto"
+	puppet "github.com/filecoin-project/specs-actors/actors/puppet"
+	runtime "github.com/filecoin-project/specs-actors/actors/runtime"
+	tutil "github.com/filecoin-project/specs-actors/support/testing"
+)
+
+// Every type with generated CBOR encoders round-trips through JSON.
+// A value is populated in every field and the CBOR encodings before and after the round trip compared, since CBOR
+// encodes all state while the decoded in-memory representations of some types (such as bitfields) differ.
+func TestJSONRoundTrip(t *testing.T) {
+	for _, target := range targets {
+		for _, typ := range target.types {
+			rt := reflect.TypeOf(typ)
+			t.Run(rt.String(), func(t *testing.T) {
+				original := reflect.New(rt)
+				fill(original.Elem(), 1)
+
+				encoded, err := json.Marshal(original.Interface())
+				require.NoError(t, err)
+				decoded := reflect.New(rt)
+				require.NoError(t, json.Unmarshal(encoded, decoded.Interface()), "%s", encoded)
+
+				assert.Equal(t, cborBytes(t, original.Interface()), cborBytes(t, decoded.Interface()), "%s", encoded)
+				reencoded, err := json.Marshal(decoded.Interface())
+				require.NoError(t, err)
+				assert.JSONEq(t, string(encoded), string(reencoded))
+			})
+		}
+	}
+}
+
+// Every type round-trips through JSON from its zero value, which is encoded when fields have not been set.
+// Zero values of some types cannot be encoded as CBOR (such as undefined CIDs), in which case only the JSON is compared.
+func TestJSONRoundTripZero(t *testing.T) {
+	for _, target := range targets {
+		for _, typ := range target.types {
+			rt := reflect.TypeOf(typ)
+			t.Run(rt.String(), func(t *testing.T) {
+				original := reflect.New(rt)
+				encoded, err := json.Marshal(original.Interface())
+				require.NoError(t, err)
+				decoded := reflect.New(rt)
+				require.NoError(t, json.Unmarshal(encoded, decoded.Interface()), "%s", encoded)
+
+				reencoded, err := json.Marshal(decoded.Interface())
+				require.NoError(t, err)
+				assert.JSONEq(t, string(encoded), string(reencoded))
+
+				buf := bytes.Buffer{}
+				if original.Interface().(runtime.CBORMarshaler).MarshalCBOR(&buf) == nil {
+					assert.Equal(t, buf.Bytes(), cborBytes(t, decoded.Interface()), "%s", encoded)
+				}
+			})
+		}
+	}
+}
+
+func TestJSONEncoding(t *testing.T) {
+	t.Run("deal proposal", func(t *testing.T) {
+		var proposal market.DealProposal
+		fill(reflect.ValueOf(&proposal).Elem(), 1)
+		encoded, err := json.Marshal(proposal)
+		require.NoError(t, err)
+		assert.Contains(t, string(encoded), fmt.Sprintf(`"PieceCID":{"/":"%s"}`, proposal.PieceCID))
+		assert.Contains(t, string(encoded), fmt.Sprintf(`"Client":"%s"`, proposal.Client))
+		assert.Contains(t, string(encoded), fmt.Sprintf(`"StoragePricePerEpoch":"%s"`, proposal.StoragePricePerEpoch))
+	})
+
+	t.Run("partition", func(t *testing.T) {
+		partition := miner.Partition{
+			Sectors:     bitfield.NewFromSet([]uint64{2, 3, 4, 8}),
+			LivePower:   miner.NewPowerPair(big.NewInt(1), big.Lsh(big.NewInt(1), 80)),
+			FaultyPower: miner.NewPowerPairZero(),
+		}
+		encoded, err := json.Marshal(partition)
+		require.NoError(t, err)
+		assert.Contains(t, string(encoded), `"Sectors":[2,3,3,1]`)
+		assert.Contains(t, string(encoded), `"LivePower":{"Raw":"1","QA":"1208925819614629174706176"}`)
+	})
+
+	t.Run("signature", func(t *testing.T) {
+		encoded, err := json.Marshal(crypto.Signature{Type: crypto.SigTypeBLS, Data: []byte{1, 2}})
+		require.NoError(t, err)
+		assert.Equal(t, `{"Type":"bls","Data":"AQI="}`, string(encoded))
+
+		// Types without a name encode as their number.
+		encoded, err = json.Marshal(crypto.Signature{})
+		require.NoError(t, err)
+		assert.Equal(t, `{"Type":0,"Data":null}`, string(encoded))
+		var sig crypto.Signature
+		require.NoError(t, json.Unmarshal([]byte(`{"Type":"secp256k1","Data":null}`), &sig))
+		assert.Equal(t, crypto.SigTypeSecp256k1, sig.Type)
+		require.NoError(t, json.Unmarshal([]byte(`{"Type":7,"Data":null}`), &sig))
+		assert.Equal(t, crypto.SigType(7), sig.Type)
+		assert.Error(t, json.Unmarshal([]byte(`{"Type":"rsa","Data":null}`), &sig))
+	})
+}
+
+var (
+	addressType   = reflect.TypeOf(addr.Address{})
+	cidType       = reflect.TypeOf(cid.Cid{})
+	bigIntType    = reflect.TypeOf(big.Int{})
+	bitFieldType  = reflect.TypeOf(bitfield.BitField{})
+	signatureType = reflect.TypeOf(crypto.Signature{})
+	failType      = reflect.TypeOf(puppet.FailToMarshalCBOR{})
+)
+
+// Sets every field of a value to a non-zero value derived from a seed.
+func fill(v reflect.Value, seed int) {
+	switch v.Type() {
+	case addressType:
+		a, err := addr.NewIDAddress(uint64(100 + seed))
+		if err != nil {
+			panic(err)
+		}
+		v.Set(reflect.ValueOf(a))
+		return
+	case cidType:
+		v.Set(reflect.ValueOf(tutil.MakeCID(fmt.Sprint(seed), nil)))
+		return
+	case bigIntType:
+		// Large enough to exceed an int64, and negative for even seeds.
+		n := big.Add(big.Lsh(big.NewInt(1), 70), big.NewInt(int64(seed)))
+		if seed%2 == 0 {
+			n = n.Neg()
+		}
+		v.Set(reflect.ValueOf(n))
+		return
+	case bitFieldType:
+		v.Set(reflect.ValueOf(bitfield.NewFromSet([]uint64{uint64(seed), uint64(seed + 1), uint64(seed + 5)})))
+		return
+	case signatureType:
+		v.Set(reflect.ValueOf(crypto.Signature{Type: crypto.SigTypeBLS, Data: []byte{byte(seed), 1}}))
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(seed))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(seed))
+	case reflect.String:
+		v.SetString(fmt.Sprintf("string %d", seed))
+	case reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+		fill(v.Elem(), seed)
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			fill(v.Index(i), seed+i)
+		}
+	case reflect.Slice:
+		if v.Type().Elem() == reflect.PtrTo(failType) {
+			return // Present only to fail encoding.
+		}
+		v.Set(reflect.MakeSlice(v.Type(), 2, 2))
+		for i := 0; i < v.Len(); i++ {
+			fill(v.Index(i), seed+i+1)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				fill(v.Field(i), seed+i+1)
+			}
+		}
+	default:
+		panic(fmt.Sprintf("cannot fill %s", v.Type()))
+	}
+}
+
+func cborBytes(t *testing.T, v interface{}) []byte {
+	buf := bytes.Buffer{}
+	require.NoError(t, v.(runtime.CBORMarshaler).MarshalCBOR(&buf))
+	return buf.Bytes()
+}
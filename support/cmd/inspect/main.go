@@ -0,0 +1,146 @@
+// Command inspect prints the state of an actor in a state tree as JSON, expanding the HAMTs, AMTs and bitfields
+// to which it refers.
+//
+// Usage:
+//
+//	inspect state.car f01000
+//	inspect state.car f01000 sector 12
+//	inspect state.car f01000 deadline 3
+//	inspect state.car f05 deal 100
+//
+// The root of the CAR is a state tree, from which the actor is looked up and the kind of its state determined from
+// its code CID. The sector and deadline sub-commands apply to miners, and the deal sub-command to the market.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	addr "github.com/filecoin-project/go-address"
+	cid "github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"golang.org/x/xerrors"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	states "github.com/filecoin-project/specs-actors/actors/states"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+	inspect "github.com/filecoin-project/specs-actors/support/inspect"
+	ipld "github.com/filecoin-project/specs-actors/support/ipld"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s state.car address [sector n | deadline i | deal id]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 && flag.NArg() != 4 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), flag.Arg(1), flag.Args()[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(path, address string, sub []string) error {
+	bs := ipld.NewBlockStoreInMemory()
+	store := adt.WrapStore(context.Background(), cbor.NewCborStore(bs))
+	root, err := importRoot(bs, path)
+	if err != nil {
+		return err
+	}
+	a, err := addr.NewFromString(address)
+	if err != nil {
+		return xerrors.Errorf("invalid address %q: %w", address, err)
+	}
+	act, err := lookupActor(store, root, a)
+	if err != nil {
+		return err
+	}
+
+	var out interface{}
+	if len(sub) == 0 {
+		out, err = inspect.ActorState(store, act.Code, act.Head)
+	} else {
+		out, err = inspectPart(store, act, sub[0], sub[1])
+	}
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// Expands one sector, deadline or deal of an actor's state.
+func inspectPart(store adt.Store, act *states.Actor, kind, arg string) (interface{}, error) {
+	n, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil {
+		return nil, xerrors.Errorf("invalid %s %q: %w", kind, arg, err)
+	}
+	switch kind {
+	case "sector":
+		if !act.Code.Equals(builtin.StorageMinerActorCodeID) {
+			return nil, xerrors.Errorf("sector requires a miner actor, not %s", builtin.ActorNameByCode(act.Code))
+		}
+		return inspect.MinerSector(store, act.Head, abi.SectorNumber(n))
+	case "deadline":
+		if !act.Code.Equals(builtin.StorageMinerActorCodeID) {
+			return nil, xerrors.Errorf("deadline requires a miner actor, not %s", builtin.ActorNameByCode(act.Code))
+		}
+		return inspect.MinerDeadline(store, act.Head, n)
+	case "deal":
+		if !act.Code.Equals(builtin.StorageMarketActorCodeID) {
+			return nil, xerrors.Errorf("deal requires the market actor, not %s", builtin.ActorNameByCode(act.Code))
+		}
+		return inspect.MarketDeal(store, act.Head, abi.DealID(n))
+	default:
+		return nil, xerrors.Errorf("unknown sub-command %q", kind)
+	}
+}
+
+func importRoot(bs ipld.Blockstore, path string) (cid.Cid, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return cid.Undef, err
+	}
+	defer func() { _ = f.Close() }()
+	roots, err := ipld.ImportCAR(bs, f)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to import %s: %w", path, err)
+	}
+	if len(roots) != 1 {
+		return cid.Undef, xerrors.Errorf("%s has %d roots, expected 1", path, len(roots))
+	}
+	return roots[0], nil
+}
+
+func lookupActor(store adt.Store, root cid.Cid, a addr.Address) (*states.Actor, error) {
+	tree, err := states.LoadTree(store, root)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load state tree %s: %w", root, err)
+	}
+	idAddr, found, err := tree.ResolveAddress(a)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to resolve %s: %w", a, err)
+	}
+	if !found {
+		return nil, xerrors.Errorf("no actor %s in state tree %s", a, root)
+	}
+	act, found, err := tree.GetActor(idAddr)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load actor %s: %w", a, err)
+	}
+	if !found {
+		return nil, xerrors.Errorf("no actor %s in state tree %s", a, root)
+	}
+	return act, nil
+}
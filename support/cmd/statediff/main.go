@@ -0,0 +1,137 @@
+// Command statediff prints the differences between two versions of a miner, market or power actor's state as JSON.
+//
+// Usage:
+//
+//	statediff -actor miner before.car after.car
+//	statediff -address f01000 before.car after.car
+//
+// Without -address, the root of each CAR is the actor's state. With -address, the root of each CAR is a state
+// tree, from which the actor's state is looked up and the kind of actor determined from its code CID.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	addr "github.com/filecoin-project/go-address"
+	cid "github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"golang.org/x/xerrors"
+
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	states "github.com/filecoin-project/specs-actors/actors/states"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+	ipld "github.com/filecoin-project/specs-actors/support/ipld"
+	statediff "github.com/filecoin-project/specs-actors/support/statediff"
+)
+
+var actorCodes = map[string]cid.Cid{
+	"miner":  builtin.StorageMinerActorCodeID,
+	"market": builtin.StorageMarketActorCodeID,
+	"power":  builtin.StoragePowerActorCodeID,
+}
+
+func main() {
+	actor := flag.String("actor", "", "kind of actor state at the CAR roots: miner, market or power")
+	address := flag.String("address", "", "address of the actor, when the CAR roots are state trees")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s (-actor kind | -address addr) before.car after.car\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 || (*actor == "") == (*address == "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*actor, *address, flag.Arg(0), flag.Arg(1)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(actor, address, beforePath, afterPath string) error {
+	bs := ipld.NewBlockStoreInMemory()
+	store := adt.WrapStore(context.Background(), cbor.NewCborStore(bs))
+	before, err := importRoot(bs, beforePath)
+	if err != nil {
+		return err
+	}
+	after, err := importRoot(bs, afterPath)
+	if err != nil {
+		return err
+	}
+
+	var code cid.Cid
+	if actor != "" {
+		var ok bool
+		if code, ok = actorCodes[actor]; !ok {
+			return xerrors.Errorf("unknown actor kind %q", actor)
+		}
+	} else {
+		a, err := addr.NewFromString(address)
+		if err != nil {
+			return xerrors.Errorf("invalid address %q: %w", address, err)
+		}
+		var beforeAct, afterAct *states.Actor
+		if beforeAct, err = lookupActor(store, before, a); err != nil {
+			return err
+		}
+		if afterAct, err = lookupActor(store, after, a); err != nil {
+			return err
+		}
+		if !beforeAct.Code.Equals(afterAct.Code) {
+			return xerrors.Errorf("actor %s code changed from %s to %s", a, beforeAct.Code, afterAct.Code)
+		}
+		code, before, after = beforeAct.Code, beforeAct.Head, afterAct.Head
+	}
+
+	diff, err := statediff.DiffActorState(store, code, before, after)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(diff)
+}
+
+func importRoot(bs ipld.Blockstore, path string) (cid.Cid, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return cid.Undef, err
+	}
+	defer func() { _ = f.Close() }()
+	roots, err := ipld.ImportCAR(bs, f)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to import %s: %w", path, err)
+	}
+	if len(roots) != 1 {
+		return cid.Undef, xerrors.Errorf("%s has %d roots, expected 1", path, len(roots))
+	}
+	return roots[0], nil
+}
+
+func lookupActor(store adt.Store, root cid.Cid, a addr.Address) (*states.Actor, error) {
+	tree, err := states.LoadTree(store, root)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load state tree %s: %w", root, err)
+	}
+	idAddr, found, err := tree.ResolveAddress(a)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to resolve %s: %w", a, err)
+	}
+	if !found {
+		return nil, xerrors.Errorf("no actor %s in state tree %s", a, root)
+	}
+	act, found, err := tree.GetActor(idAddr)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load actor %s: %w", a, err)
+	}
+	if !found {
+		return nil, xerrors.Errorf("no actor %s in state tree %s", a, root)
+	}
+	return act, nil
+}
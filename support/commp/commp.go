@@ -0,0 +1,162 @@
+// Package commp computes the piece and sector data commitments that the proofs library would otherwise compute,
+// using the sha256-trunc254 binary merkle tree over padded data.
+package commp
+
+import (
+	"math/bits"
+
+	cid "github.com/ipfs/go-cid"
+	"github.com/minio/sha256-simd"
+	mh "github.com/multiformats/go-multihash"
+	"golang.org/x/xerrors"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// The size in bytes of a commitment, and of each leaf of the merkle tree.
+const NodeSize = 32
+
+// The largest supported tree has leaves spanning a 64GiB sector.
+const maxLayers = 32
+
+// Commitments to data consisting entirely of zeros, indexed by tree height.
+// Layer i commits to NodeSize<<i bytes.
+var zeroCommitments = func() [][NodeSize]byte {
+	comms := make([][NodeSize]byte, maxLayers)
+	for i := 1; i < maxLayers; i++ {
+		comms[i] = hashNodes(comms[i-1], comms[i-1])
+	}
+	return comms
+}()
+
+// Returns the commitment to a piece of the given padded size consisting entirely of zeros.
+func ZeroPieceCommitment(size abi.PaddedPieceSize) ([NodeSize]byte, error) {
+	if err := size.Validate(); err != nil {
+		return [NodeSize]byte{}, err
+	}
+	layer := bits.TrailingZeros64(uint64(size)) - bits.TrailingZeros64(NodeSize)
+	if layer >= maxLayers {
+		return [NodeSize]byte{}, xerrors.Errorf("piece size %d too large", size)
+	}
+	return zeroCommitments[layer], nil
+}
+
+// Combines two sibling nodes into their parent: the sha256 of their concatenation, truncated to 254 bits.
+func hashNodes(left, right [NodeSize]byte) [NodeSize]byte {
+	h := sha256.New()
+	_, _ = h.Write(left[:])
+	_, _ = h.Write(right[:])
+	var out [NodeSize]byte
+	copy(out[:], h.Sum(nil))
+	out[NodeSize-1] &= 0x3f
+	return out
+}
+
+// Converts an unsealed data commitment (CommP or CommD) to a CID.
+func DataCommitmentToCID(commitment [NodeSize]byte) (cid.Cid, error) {
+	hash, err := mh.Encode(commitment[:], mh.SHA2_256_TRUNC254_PADDED)
+	if err != nil {
+		return cid.Undef, err
+	}
+	return cid.NewCidV1(cid.FilCommitmentUnsealed, hash), nil
+}
+
+// Extracts the unsealed data commitment from a CID, checking its codec and hash function.
+func CIDToDataCommitment(c cid.Cid) ([NodeSize]byte, error) {
+	var commitment [NodeSize]byte
+	if c.Prefix().Codec != cid.FilCommitmentUnsealed {
+		return commitment, xerrors.Errorf("cid %s has codec %#x, expected unsealed commitment", c, c.Prefix().Codec)
+	}
+	decoded, err := mh.Decode(c.Hash())
+	if err != nil {
+		return commitment, xerrors.Errorf("failed to decode multihash of %s: %w", c, err)
+	}
+	if decoded.Code != mh.SHA2_256_TRUNC254_PADDED {
+		return commitment, xerrors.Errorf("cid %s has hash function %#x, expected sha2-256-trunc254-padded", c, decoded.Code)
+	}
+	if len(decoded.Digest) != NodeSize {
+		return commitment, xerrors.Errorf("cid %s has digest length %d, expected %d", c, len(decoded.Digest), NodeSize)
+	}
+	copy(commitment[:], decoded.Digest)
+	return commitment, nil
+}
+
+// Computes the unsealed sector CID (CommD) of a sector holding the given pieces, in order.
+// Each piece is placed at the next offset aligned to its size, and the space before it and after the last piece
+// is filled with zeros, as done by the proofs library when packing pieces into a sector.
+func ComputeUnsealedSectorCID(proofType abi.RegisteredSealProof, pieces []abi.PieceInfo) (cid.Cid, error) {
+	sectorSize, err := proofType.SectorSize()
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	tree := treeBuilder{}
+	for i, piece := range pieces {
+		if err := piece.Size.Validate(); err != nil {
+			return cid.Undef, xerrors.Errorf("invalid size of piece %d: %w", i, err)
+		}
+		commitment, err := CIDToDataCommitment(piece.PieceCID)
+		if err != nil {
+			return cid.Undef, xerrors.Errorf("invalid cid of piece %d: %w", i, err)
+		}
+
+		// Check before padding, which can't build a tree larger than the largest sector.
+		size := uint64(piece.Size)
+		if size > uint64(sectorSize) || alignUp(tree.offset, size)+size > uint64(sectorSize) {
+			return cid.Undef, xerrors.Errorf("pieces exceed sector size %d at piece %d", sectorSize, i)
+		}
+		tree.padTo(alignUp(tree.offset, size))
+		tree.push(size, commitment)
+	}
+	tree.padTo(uint64(sectorSize))
+
+	return DataCommitmentToCID(tree.root())
+}
+
+// Builds a merkle root incrementally from a sequence of subtrees, each aligned to its own size.
+// The stack holds the roots of complete subtrees not yet combined, in strictly decreasing order of size.
+type treeBuilder struct {
+	offset uint64
+	stack  []subtree
+}
+
+type subtree struct {
+	size       uint64
+	commitment [NodeSize]byte
+}
+
+func (b *treeBuilder) push(size uint64, commitment [NodeSize]byte) {
+	b.stack = append(b.stack, subtree{size, commitment})
+	b.offset += size
+	for len(b.stack) >= 2 {
+		left, right := b.stack[len(b.stack)-2], b.stack[len(b.stack)-1]
+		if left.size != right.size {
+			break
+		}
+		b.stack = append(b.stack[:len(b.stack)-2], subtree{left.size * 2, hashNodes(left.commitment, right.commitment)})
+	}
+}
+
+// Fills with zeros up to the given offset, using the largest aligned zero subtrees possible.
+func (b *treeBuilder) padTo(offset uint64) {
+	for b.offset < offset {
+		size := uint64(NodeSize)
+		for b.offset%(size*2) == 0 && b.offset+size*2 <= offset {
+			size *= 2
+		}
+		layer := bits.TrailingZeros64(size) - bits.TrailingZeros64(NodeSize)
+		b.push(size, zeroCommitments[layer])
+	}
+}
+
+// The root of the tree, which must consist of a single complete subtree.
+func (b *treeBuilder) root() [NodeSize]byte {
+	if len(b.stack) != 1 {
+		panic(xerrors.Errorf("incomplete tree with %d subtrees", len(b.stack)))
+	}
+	return b.stack[0].commitment
+}
+
+func alignUp(offset, alignment uint64) uint64 {
+	return (offset + alignment - 1) / alignment * alignment
+}
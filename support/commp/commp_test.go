@@ -0,0 +1,112 @@
+package commp_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	market "github.com/filecoin-project/specs-actors/actors/builtin/market"
+	"github.com/filecoin-project/specs-actors/support/commp"
+	tutil "github.com/filecoin-project/specs-actors/support/testing"
+)
+
+const proof = abi.RegisteredSealProof_StackedDrg2KiBV1
+
+func TestZeroPieceCommitment(t *testing.T) {
+	comm, err := commp.ZeroPieceCommitment(128)
+	require.NoError(t, err)
+	assert.Equal(t, "3731bb99ac689f66eef5973e4a94da188f4ddcae580724fc6f3fd60dfd488333", hex.EncodeToString(comm[:]))
+
+	_, err = commp.ZeroPieceCommitment(100)
+	assert.Error(t, err)
+}
+
+func TestCIDConversion(t *testing.T) {
+	pieceCID := tutil.MakeCID("piece", &market.PieceCIDPrefix)
+	comm, err := commp.CIDToDataCommitment(pieceCID)
+	require.NoError(t, err)
+	c, err := commp.DataCommitmentToCID(comm)
+	require.NoError(t, err)
+	assert.Equal(t, pieceCID, c)
+
+	_, err = commp.CIDToDataCommitment(tutil.MakeCID("piece", nil))
+	assert.Error(t, err)
+}
+
+func TestComputeUnsealedSectorCID(t *testing.T) {
+	sectorSize, err := proof.SectorSize()
+	require.NoError(t, err)
+
+	t.Run("empty sector", func(t *testing.T) {
+		commD, err := commp.ComputeUnsealedSectorCID(proof, nil)
+		require.NoError(t, err)
+		assert.Equal(t, zeroPiece(t, abi.PaddedPieceSize(sectorSize)).PieceCID, commD)
+	})
+
+	t.Run("piece filling the sector", func(t *testing.T) {
+		piece := makePiece("full", abi.PaddedPieceSize(sectorSize))
+		commD, err := commp.ComputeUnsealedSectorCID(proof, []abi.PieceInfo{piece})
+		require.NoError(t, err)
+		assert.Equal(t, piece.PieceCID, commD)
+	})
+
+	t.Run("remaining space is zero filled", func(t *testing.T) {
+		piece := makePiece("half", 1024)
+		commD, err := commp.ComputeUnsealedSectorCID(proof, []abi.PieceInfo{piece})
+		require.NoError(t, err)
+		explicit, err := commp.ComputeUnsealedSectorCID(proof, []abi.PieceInfo{piece, zeroPiece(t, 1024)})
+		require.NoError(t, err)
+		assert.Equal(t, explicit, commD)
+		assert.NotEqual(t, piece.PieceCID, commD)
+	})
+
+	t.Run("pieces are aligned to their size", func(t *testing.T) {
+		a := makePiece("a", 128)
+		b := makePiece("b", 256)
+		commD, err := commp.ComputeUnsealedSectorCID(proof, []abi.PieceInfo{a, b})
+		require.NoError(t, err)
+		explicit, err := commp.ComputeUnsealedSectorCID(proof, []abi.PieceInfo{a, zeroPiece(t, 128), b, zeroPiece(t, 512), zeroPiece(t, 1024)})
+		require.NoError(t, err)
+		assert.Equal(t, explicit, commD)
+
+		// order matters
+		reversed, err := commp.ComputeUnsealedSectorCID(proof, []abi.PieceInfo{b, a})
+		require.NoError(t, err)
+		assert.NotEqual(t, commD, reversed)
+	})
+
+	t.Run("pieces exceeding the sector", func(t *testing.T) {
+		_, err := commp.ComputeUnsealedSectorCID(proof, []abi.PieceInfo{makePiece("a", 1024), makePiece("b", 2048)})
+		assert.Error(t, err)
+
+		// a piece larger than any sector
+		_, err = commp.ComputeUnsealedSectorCID(proof, []abi.PieceInfo{makePiece("a", 128), makePiece("b", 1<<40)})
+		assert.Error(t, err)
+
+		// a piece that fits in the remaining space, but not once aligned to its size
+		_, err = commp.ComputeUnsealedSectorCID(proof, []abi.PieceInfo{makePiece("a", 128), makePiece("b", 1024), makePiece("c", 512)})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid pieces", func(t *testing.T) {
+		_, err := commp.ComputeUnsealedSectorCID(proof, []abi.PieceInfo{makePiece("a", 100)})
+		assert.Error(t, err)
+		_, err = commp.ComputeUnsealedSectorCID(proof, []abi.PieceInfo{{Size: 128, PieceCID: tutil.MakeCID("a", nil)}})
+		assert.Error(t, err)
+	})
+}
+
+func makePiece(data string, size abi.PaddedPieceSize) abi.PieceInfo {
+	return abi.PieceInfo{Size: size, PieceCID: tutil.MakeCID(data, &market.PieceCIDPrefix)}
+}
+
+func zeroPiece(t *testing.T, size abi.PaddedPieceSize) abi.PieceInfo {
+	comm, err := commp.ZeroPieceCommitment(size)
+	require.NoError(t, err)
+	c, err := commp.DataCommitmentToCID(comm)
+	require.NoError(t, err)
+	return abi.PieceInfo{Size: size, PieceCID: c}
+}
@@ -0,0 +1,165 @@
+package driver
+
+import (
+	addr "github.com/filecoin-project/go-address"
+	cid "github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	big "github.com/filecoin-project/specs-actors/actors/abi/big"
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	reward "github.com/filecoin-project/specs-actors/actors/builtin/reward"
+	runtime "github.com/filecoin-project/specs-actors/actors/runtime"
+	exitcode "github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	vm "github.com/filecoin-project/specs-actors/support/vm"
+)
+
+// A Driver advances a VM through a sequence of epochs, applying the messages of each tipset followed by the
+// implicit messages a node would apply: a block reward for each block, then the cron tick.
+//
+// As on a node, a null round applies no messages and pays no rewards, but cron still runs at its epoch.
+type Driver struct {
+	vm *vm.VM
+}
+
+// A message to be applied as part of a block.
+type Message struct {
+	From   addr.Address
+	To     addr.Address
+	Value  abi.TokenAmount
+	Method abi.MethodNum
+	Params interface{}
+}
+
+// A block in a tipset, and the messages it includes.
+type Block struct {
+	Miner    addr.Address // The block producer, to which the block reward is paid.
+	WinCount int64        // Number of reward units won; at least one.
+	Messages []Message
+}
+
+// The outcome of applying a message.
+type MessageResult struct {
+	Message  Message
+	Ret      runtime.SendReturn
+	ExitCode exitcode.ExitCode
+}
+
+// Creates a driver that applies the next tipset at the VM's current epoch.
+func NewDriver(v *vm.VM) *Driver {
+	return &Driver{vm: v}
+}
+
+// The VM at the driver's current epoch.
+// The driver replaces its VM each time the epoch advances, so this should not be retained across tipsets.
+func (d *Driver) VM() *vm.VM {
+	return d.vm
+}
+
+// The epoch at which the next tipset will be applied.
+func (d *Driver) Epoch() abi.ChainEpoch {
+	return d.vm.GetEpoch()
+}
+
+// Applies a tipset of blocks at the current epoch, then advances to the next epoch.
+// The messages of each block are applied in order, followed by the block's reward. Cron runs once all blocks have
+// been applied.
+// Returns the results of the messages, in order. A message failure is reported only in its result; a failure of
+// an implicit message is an error, in which case the state is rolled back to that before the tipset and the epoch
+// does not advance.
+func (d *Driver) ApplyTipset(blocks ...Block) ([]MessageResult, error) {
+	if len(blocks) == 0 {
+		return nil, xerrors.Errorf("tipset at epoch %d has no blocks", d.Epoch())
+	}
+	for i, block := range blocks {
+		if block.WinCount < 1 {
+			return nil, xerrors.Errorf("block %d at epoch %d has win count %d, expected at least 1", i, d.Epoch(), block.WinCount)
+		}
+	}
+
+	priorRoot := d.vm.StateRoot()
+	results, err := d.applyBlocks(blocks)
+	if err != nil {
+		return results, d.rollback(priorRoot, err)
+	}
+	return results, d.advance()
+}
+
+// Applies the messages and implicit messages of a tipset's blocks, then the cron tick.
+func (d *Driver) applyBlocks(blocks []Block) ([]MessageResult, error) {
+	var results []MessageResult
+	for i, block := range blocks {
+		for _, msg := range block.Messages {
+			ret, code := d.vm.ApplyMessage(msg.From, msg.To, msg.Value, msg.Method, msg.Params)
+			results = append(results, MessageResult{Message: msg, Ret: ret, ExitCode: code})
+		}
+
+		params := reward.AwardBlockRewardParams{
+			Miner:     block.Miner,
+			Penalty:   big.Zero(),
+			GasReward: big.Zero(),
+			WinCount:  block.WinCount,
+		}
+		if _, code := d.vm.ApplyImplicitMessage(builtin.SystemActorAddr, builtin.RewardActorAddr, big.Zero(),
+			builtin.MethodsReward.AwardBlockReward, &params); code != exitcode.Ok {
+			return results, xerrors.Errorf("block reward for block %d at epoch %d failed: exit code %s", i, d.Epoch(), code)
+		}
+	}
+
+	return results, d.cronTick()
+}
+
+// Applies n null rounds, running cron at each epoch without applying any messages or rewards.
+// A failure of cron is an error, in which case the state is rolled back to that before the failed null round,
+// at whose epoch the driver remains.
+func (d *Driver) NullRounds(n int) error {
+	if n < 0 {
+		return xerrors.Errorf("negative null round count %d", n)
+	}
+	for i := 0; i < n; i++ {
+		priorRoot := d.vm.StateRoot()
+		if err := d.cronTick(); err != nil {
+			return d.rollback(priorRoot, err)
+		}
+		if err := d.advance(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Advances to the given epoch, applying a tipset of a single block from the miner, with no messages, at each
+// epoch along the way.
+func (d *Driver) AdvanceTo(epoch abi.ChainEpoch, miner addr.Address) error {
+	for d.Epoch() < epoch {
+		if _, err := d.ApplyTipset(Block{Miner: miner, WinCount: 1}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Driver) cronTick() error {
+	if _, code := d.vm.ApplyImplicitMessage(builtin.SystemActorAddr, builtin.CronActorAddr, big.Zero(),
+		builtin.MethodsCron.EpochTick, nil); code != exitcode.Ok {
+		return xerrors.Errorf("cron tick at epoch %d failed: exit code %s", d.Epoch(), code)
+	}
+	return nil
+}
+
+// Rolls back to a prior state after a failure, returning the failure.
+func (d *Driver) rollback(root cid.Cid, err error) error {
+	if rbErr := d.vm.Rollback(root); rbErr != nil {
+		return xerrors.Errorf("failed to roll back (%v) after failure: %w", rbErr, err)
+	}
+	return err
+}
+
+func (d *Driver) advance() error {
+	next, err := d.vm.WithEpoch(d.Epoch() + 1)
+	if err != nil {
+		return err
+	}
+	d.vm = next
+	return nil
+}
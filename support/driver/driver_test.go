@@ -0,0 +1,166 @@
+package driver_test
+
+import (
+	"context"
+	"testing"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	big "github.com/filecoin-project/specs-actors/actors/abi/big"
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	power "github.com/filecoin-project/specs-actors/actors/builtin/power"
+	reward "github.com/filecoin-project/specs-actors/actors/builtin/reward"
+	exitcode "github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/support/driver"
+	vm "github.com/filecoin-project/specs-actors/support/vm"
+)
+
+func TestDriver(t *testing.T) {
+	ctx := context.Background()
+	v := vm.NewVMWithSingletons(ctx, t)
+	addrs := vm.CreateAccounts(ctx, t, v, 2, big.Mul(big.NewInt(10_000), vm.FIL), 93837778)
+	owner, other := addrs[0], addrs[1]
+
+	params := power.CreateMinerParams{
+		Owner:         owner,
+		Worker:        owner,
+		SealProofType: abi.RegisteredSealProof_StackedDrg32GiBV1,
+		Peer:          abi.PeerID("not really a peer id"),
+	}
+	ret := vm.ApplyOk(t, v, owner, builtin.StoragePowerActorAddr, big.Zero(), builtin.MethodsPower.CreateMiner, &params)
+	var minerAddrs power.CreateMinerReturn
+	require.NoError(t, ret.Into(&minerAddrs))
+
+	d := driver.NewDriver(v)
+	require.Equal(t, abi.ChainEpoch(0), d.Epoch())
+
+	t.Run("tipset applies messages, rewards and cron", func(t *testing.T) {
+		rewardBefore := balance(t, d.VM(), builtin.RewardActorAddr)
+
+		results, err := d.ApplyTipset(driver.Block{
+			Miner:    minerAddrs.IDAddress,
+			WinCount: 1,
+			Messages: []driver.Message{
+				{From: owner, To: other, Value: vm.FIL, Method: builtin.MethodSend},
+				{From: owner, To: other, Value: big.Mul(big.NewInt(1e6), vm.FIL), Method: builtin.MethodSend},
+			},
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, exitcode.Ok, results[0].ExitCode)
+		assert.Equal(t, exitcode.SysErrInsufficientFunds, results[1].ExitCode)
+		assert.Equal(t, abi.ChainEpoch(1), d.Epoch())
+
+		// the reward has been paid to the miner
+		rewardAfter := balance(t, d.VM(), builtin.RewardActorAddr)
+		assert.True(t, rewardAfter.LessThan(rewardBefore))
+		assert.Equal(t, big.Sub(rewardBefore, rewardAfter), balance(t, d.VM(), minerAddrs.IDAddress))
+
+		// cron has updated the reward for the next epoch
+		var st reward.State
+		require.NoError(t, d.VM().GetState(builtin.RewardActorAddr, &st))
+		assert.Equal(t, abi.ChainEpoch(1), st.Epoch)
+	})
+
+	t.Run("cron runs in null rounds", func(t *testing.T) {
+		rewardBefore := balance(t, d.VM(), builtin.RewardActorAddr)
+		require.NoError(t, d.NullRounds(5))
+		assert.Equal(t, abi.ChainEpoch(6), d.Epoch())
+
+		// no rewards are paid, but the reward actor has been updated at each epoch
+		assert.Equal(t, rewardBefore, balance(t, d.VM(), builtin.RewardActorAddr))
+		var st reward.State
+		require.NoError(t, d.VM().GetState(builtin.RewardActorAddr, &st))
+		assert.Equal(t, abi.ChainEpoch(6), st.Epoch)
+
+		_, err := d.ApplyTipset(driver.Block{Miner: minerAddrs.IDAddress, WinCount: 1})
+		require.NoError(t, err)
+		require.NoError(t, d.VM().GetState(builtin.RewardActorAddr, &st))
+		assert.Equal(t, abi.ChainEpoch(7), st.Epoch)
+	})
+
+	t.Run("advance to epoch", func(t *testing.T) {
+		require.NoError(t, d.AdvanceTo(20, minerAddrs.IDAddress))
+		assert.Equal(t, abi.ChainEpoch(20), d.Epoch())
+
+		var st reward.State
+		require.NoError(t, d.VM().GetState(builtin.RewardActorAddr, &st))
+		assert.Equal(t, abi.ChainEpoch(20), st.Epoch)
+	})
+
+	t.Run("invalid tipsets", func(t *testing.T) {
+		root := d.VM().StateRoot()
+		transfer := driver.Message{From: owner, To: other, Value: vm.FIL, Method: builtin.MethodSend}
+
+		_, err := d.ApplyTipset()
+		assert.Error(t, err)
+
+		_, err = d.ApplyTipset(
+			driver.Block{Miner: minerAddrs.IDAddress, WinCount: 1, Messages: []driver.Message{transfer}},
+			driver.Block{Miner: minerAddrs.IDAddress, WinCount: 0},
+		)
+		assert.Error(t, err)
+		assert.Equal(t, abi.ChainEpoch(20), d.Epoch())
+		assert.Equal(t, root, d.VM().StateRoot())
+
+		// The reward for a block from an unresolvable miner address fails after the messages have been applied.
+		unknown, err := addr.NewActorAddress([]byte("unknown miner"))
+		require.NoError(t, err)
+		results, err := d.ApplyTipset(driver.Block{Miner: unknown, WinCount: 1, Messages: []driver.Message{transfer}})
+		assert.Error(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, exitcode.Ok, results[0].ExitCode)
+		assert.Equal(t, abi.ChainEpoch(20), d.Epoch())
+		assert.Equal(t, root, d.VM().StateRoot())
+
+		assert.Error(t, d.NullRounds(-1))
+	})
+
+	t.Run("cron event scheduled in a null round", func(t *testing.T) {
+		// The miner enrolled a cron event for the end of its first proving deadline.
+		eventEpoch := firstCronEvent(t, d.VM())
+		require.True(t, eventEpoch >= d.Epoch())
+
+		require.NoError(t, d.NullRounds(int(eventEpoch-d.Epoch())+1))
+		assert.Equal(t, eventEpoch+1, d.Epoch())
+
+		// the event was handled at its epoch, and the miner enrolled another for its next deadline
+		var st power.State
+		require.NoError(t, d.VM().GetState(builtin.StoragePowerActorAddr, &st))
+		assert.Equal(t, eventEpoch+1, st.FirstCronEpoch)
+		assert.Equal(t, eventEpoch, st.LastProcessedCronEpoch)
+		assert.True(t, firstCronEvent(t, d.VM()) > eventEpoch)
+	})
+}
+
+func balance(t *testing.T, v *vm.VM, a addr.Address) abi.TokenAmount {
+	act, found, err := v.GetActor(a)
+	require.NoError(t, err)
+	require.True(t, found)
+	return act.Balance
+}
+
+// The earliest epoch with an event in the power actor's cron queue.
+func firstCronEvent(t *testing.T, v *vm.VM) abi.ChainEpoch {
+	var st power.State
+	require.NoError(t, v.GetState(builtin.StoragePowerActorAddr, &st))
+	events, err := adt.AsMultimap(v.Store(), st.CronEventQueue)
+	require.NoError(t, err)
+	first := abi.ChainEpoch(-1)
+	require.NoError(t, events.ForAll(func(k string, _ *adt.Array) error {
+		epoch, err := adt.ParseIntKey(k)
+		if err != nil {
+			return err
+		}
+		if first < 0 || abi.ChainEpoch(epoch) < first {
+			first = abi.ChainEpoch(epoch)
+		}
+		return nil
+	}))
+	require.True(t, first >= 0, "no cron events")
+	return first
+}
@@ -0,0 +1,398 @@
+package genesis
+
+import (
+	"bytes"
+	"context"
+
+	addr "github.com/filecoin-project/go-address"
+	cid "github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	big "github.com/filecoin-project/specs-actors/actors/abi/big"
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	account "github.com/filecoin-project/specs-actors/actors/builtin/account"
+	cron "github.com/filecoin-project/specs-actors/actors/builtin/cron"
+	init_ "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	market "github.com/filecoin-project/specs-actors/actors/builtin/market"
+	miner "github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	multisig "github.com/filecoin-project/specs-actors/actors/builtin/multisig"
+	power "github.com/filecoin-project/specs-actors/actors/builtin/power"
+	crypto "github.com/filecoin-project/specs-actors/actors/crypto"
+	runtime "github.com/filecoin-project/specs-actors/actors/runtime"
+	exitcode "github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	states "github.com/filecoin-project/specs-actors/actors/states"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+	vm "github.com/filecoin-project/specs-actors/support/vm"
+)
+
+// Config declares the initial state of a network.
+// Any balance left nil, as when omitted from a declarative configuration, is zero.
+type Config struct {
+	NetworkName string
+	// Holder of the verified registry's root key. This must be an ID address, or the address of an
+	// account or multisig declared in this configuration.
+	VerifregRootKey addr.Address
+	// Initial balance of the reward actor, from which block rewards are paid.
+	RewardBalance abi.TokenAmount
+
+	Accounts  []Account
+	Multisigs []Multisig
+	Miners    []Miner
+}
+
+// An account actor funded at genesis.
+type Account struct {
+	Address addr.Address // A public key (SECP256K1 or BLS) address.
+	Balance abi.TokenAmount
+}
+
+// A multisig actor funded at genesis.
+// The balance vests linearly over UnlockDuration epochs from genesis.
+type Multisig struct {
+	Signers        []addr.Address
+	Threshold      uint64
+	UnlockDuration abi.ChainEpoch
+	Balance        abi.TokenAmount
+}
+
+// A storage miner with sectors that are proven at genesis.
+type Miner struct {
+	// Owner and worker must be accounts declared in this configuration.
+	// The owner must be able to fund the miner's balance and the provider collateral of all deals.
+	Owner         addr.Address
+	Worker        addr.Address
+	SealProofType abi.RegisteredSealProof
+	PeerID        abi.PeerID
+	// Balance transferred from the owner to the new miner, from which the sectors' initial pledge is taken.
+	Balance abi.TokenAmount
+	Sectors []Sector
+}
+
+// A pre-sealed sector.
+type Sector struct {
+	SectorNumber abi.SectorNumber
+	SealedCID    cid.Cid // CommR
+	Expiration   abi.ChainEpoch
+	// Deals stored in the sector. The provider of each deal is set to the miner.
+	// Deal clients must be accounts declared in this configuration, and are charged their escrow requirement.
+	Deals []market.DealProposal
+}
+
+// The state produced from a configuration, with the ID addresses allocated to the declared actors.
+type Result struct {
+	StateRoot cid.Cid
+	Accounts  []addr.Address
+	Multisigs []addr.Address
+	Miners    []addr.Address
+}
+
+// Builds the genesis state described by a configuration in a store.
+//
+// Singleton actors are constructed by invoking their constructors from the system actor, and other actors
+// are created and funded with messages, so the result is consistent with the actors' own validation.
+// The sectors of pre-sealed miners are confirmed as if their proofs had been verified by the power actor.
+func Build(ctx context.Context, store adt.Store, cfg *Config) (*Result, error) {
+	v := vm.NewVM(ctx, vm.BuiltinActorImpls(), store)
+
+	// The system actor is funded with the total initial balance of accounts and multisigs, which it then
+	// distributes by sending value to them.
+	distributed := big.Zero()
+	for _, a := range cfg.Accounts {
+		distributed = big.Add(distributed, zeroIfNil(a.Balance))
+	}
+	for _, m := range cfg.Multisigs {
+		distributed = big.Add(distributed, zeroIfNil(m.Balance))
+	}
+	rewardBalance := zeroIfNil(cfg.RewardBalance)
+
+	singletons := []struct {
+		addr    addr.Address
+		code    cid.Cid
+		balance abi.TokenAmount
+	}{
+		{builtin.SystemActorAddr, builtin.SystemActorCodeID, distributed},
+		{builtin.InitActorAddr, builtin.InitActorCodeID, big.Zero()},
+		{builtin.RewardActorAddr, builtin.RewardActorCodeID, rewardBalance},
+		{builtin.CronActorAddr, builtin.CronActorCodeID, big.Zero()},
+		{builtin.StoragePowerActorAddr, builtin.StoragePowerActorCodeID, big.Zero()},
+		{builtin.StorageMarketActorAddr, builtin.StorageMarketActorCodeID, big.Zero()},
+		{builtin.VerifiedRegistryActorAddr, builtin.VerifiedRegistryActorCodeID, big.Zero()},
+		{builtin.BurntFundsActorAddr, builtin.AccountActorCodeID, big.Zero()},
+	}
+	for _, s := range singletons {
+		if err := v.SetActor(ctx, s.addr, &states.Actor{Code: s.code, Head: v.EmptyObject(), Balance: s.balance}); err != nil {
+			return nil, xerrors.Errorf("failed to create actor %v: %w", s.addr, err)
+		}
+	}
+
+	// The burnt funds actor is an account without a key, so cannot be constructed by message.
+	if err := v.SetActorState(ctx, builtin.BurntFundsActorAddr, &account.State{Address: builtin.BurntFundsActorAddr}); err != nil {
+		return nil, xerrors.Errorf("failed to set burnt funds actor state: %w", err)
+	}
+
+	b := builder{vm: v}
+	b.construct(builtin.SystemActorAddr, nil)
+	b.construct(builtin.InitActorAddr, &init_.ConstructorParams{NetworkName: cfg.NetworkName})
+	initialPower := abi.NewStoragePower(0)
+	b.construct(builtin.RewardActorAddr, &initialPower)
+	b.construct(builtin.CronActorAddr, &cron.ConstructorParams{Entries: cron.BuiltInEntries()})
+	b.construct(builtin.StoragePowerActorAddr, nil)
+	b.construct(builtin.StorageMarketActorAddr, nil)
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	result := &Result{}
+	for _, a := range cfg.Accounts {
+		idAddr, err := b.createAccount(a)
+		if err != nil {
+			return nil, err
+		}
+		result.Accounts = append(result.Accounts, idAddr)
+	}
+	for _, m := range cfg.Multisigs {
+		idAddr, err := b.createMultisig(m)
+		if err != nil {
+			return nil, err
+		}
+		result.Multisigs = append(result.Multisigs, idAddr)
+	}
+
+	rootKey := cfg.VerifregRootKey
+	b.construct(builtin.VerifiedRegistryActorAddr, &rootKey)
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	for i, m := range cfg.Miners {
+		idAddr, err := b.createMiner(m)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to create miner %d: %w", i, err)
+		}
+		result.Miners = append(result.Miners, idAddr)
+	}
+
+	result.StateRoot = v.StateRoot()
+	return result, nil
+}
+
+type builder struct {
+	vm  *vm.VM
+	err error
+}
+
+// Invokes the constructor of a singleton actor from the system actor.
+// The first failure is retained in the builder, and subsequent calls are ignored.
+func (b *builder) construct(a addr.Address, params interface{}) {
+	if b.err != nil {
+		return
+	}
+	_, b.err = b.applyImplicit(builtin.SystemActorAddr, a, big.Zero(), builtin.MethodConstructor, params)
+	if b.err != nil {
+		b.err = xerrors.Errorf("failed to construct actor %v: %w", a, b.err)
+	}
+}
+
+func (b *builder) createAccount(a Account) (addr.Address, error) {
+	if a.Address.Protocol() != addr.SECP256K1 && a.Address.Protocol() != addr.BLS {
+		return addr.Undef, xerrors.Errorf("account address %v is not a public key address", a.Address)
+	}
+	if _, found := b.vm.NormalizeAddress(a.Address); found {
+		return addr.Undef, xerrors.Errorf("duplicate account %v", a.Address)
+	}
+	// Sending value to a public key address creates an account actor for it.
+	if _, err := b.applyImplicit(builtin.SystemActorAddr, a.Address, zeroIfNil(a.Balance), builtin.MethodSend, nil); err != nil {
+		return addr.Undef, xerrors.Errorf("failed to create account %v: %w", a.Address, err)
+	}
+	idAddr, found := b.vm.NormalizeAddress(a.Address)
+	if !found {
+		return addr.Undef, xerrors.Errorf("account %v not found after creation", a.Address)
+	}
+	return idAddr, nil
+}
+
+func (b *builder) createMultisig(m Multisig) (addr.Address, error) {
+	ctorParams, err := serialize(&multisig.ConstructorParams{
+		Signers:               m.Signers,
+		NumApprovalsThreshold: m.Threshold,
+		UnlockDuration:        m.UnlockDuration,
+	})
+	if err != nil {
+		return addr.Undef, err
+	}
+
+	// This message increments the system actor's call sequence number, from which the multisig's robust
+	// address is derived. An implicit message would allocate the same robust address for every multisig.
+	ret, code := b.vm.ApplyMessage(builtin.SystemActorAddr, builtin.InitActorAddr, zeroIfNil(m.Balance), builtin.MethodsInit.Exec,
+		&init_.ExecParams{CodeCID: builtin.MultisigActorCodeID, ConstructorParams: ctorParams})
+	if code != exitcode.Ok {
+		return addr.Undef, xerrors.Errorf("failed to create multisig with signers %v: exit code %d", m.Signers, code)
+	}
+	var execRet init_.ExecReturn
+	if err := ret.Into(&execRet); err != nil {
+		return addr.Undef, err
+	}
+	return execRet.IDAddress, nil
+}
+
+func (b *builder) createMiner(m Miner) (addr.Address, error) {
+	ret, err := b.apply(m.Owner, builtin.StoragePowerActorAddr, zeroIfNil(m.Balance), builtin.MethodsPower.CreateMiner, &power.CreateMinerParams{
+		Owner:         m.Owner,
+		Worker:        m.Worker,
+		SealProofType: m.SealProofType,
+		Peer:          m.PeerID,
+	})
+	if err != nil {
+		return addr.Undef, err
+	}
+	var minerAddrs power.CreateMinerReturn
+	if err := ret.Into(&minerAddrs); err != nil {
+		return addr.Undef, err
+	}
+	minerAddr := minerAddrs.IDAddress
+
+	if len(m.Sectors) == 0 {
+		return minerAddr, nil
+	}
+
+	// Publish the deals of each sector, and record the sectors as pre-committed.
+	var precommits []*miner.SectorPreCommitOnChainInfo
+	for _, sector := range m.Sectors {
+		dealIDs, err := b.publishDeals(m, minerAddr, sector.Deals)
+		if err != nil {
+			return addr.Undef, xerrors.Errorf("failed to publish deals for sector %d: %w", sector.SectorNumber, err)
+		}
+
+		dealWeight := big.Zero()
+		verifiedDealWeight := big.Zero()
+		for _, deal := range sector.Deals {
+			deal := deal
+			if deal.VerifiedDeal {
+				verifiedDealWeight = big.Add(verifiedDealWeight, market.DealWeight(&deal))
+			} else {
+				dealWeight = big.Add(dealWeight, market.DealWeight(&deal))
+			}
+		}
+
+		precommits = append(precommits, &miner.SectorPreCommitOnChainInfo{
+			Info: miner.SectorPreCommitInfo{
+				SealProof:    m.SealProofType,
+				SectorNumber: sector.SectorNumber,
+				SealedCID:    sector.SealedCID,
+				DealIDs:      dealIDs,
+				Expiration:   sector.Expiration,
+			},
+			PreCommitDeposit:   big.Zero(),
+			PreCommitEpoch:     b.vm.GetEpoch(),
+			DealWeight:         dealWeight,
+			VerifiedDealWeight: verifiedDealWeight,
+		})
+	}
+
+	var st miner.State
+	if err := b.vm.GetState(minerAddr, &st); err != nil {
+		return addr.Undef, err
+	}
+	store := b.vm.Store()
+	sectorNos := make([]abi.SectorNumber, len(precommits))
+	for i, precommit := range precommits {
+		if err := st.AllocateSectorNumber(store, precommit.Info.SectorNumber); err != nil {
+			return addr.Undef, err
+		}
+		if err := st.PutPrecommittedSector(store, precommit); err != nil {
+			return addr.Undef, err
+		}
+		sectorNos[i] = precommit.Info.SectorNumber
+	}
+	if err := b.vm.SetActorState(b.vm.Store().Context(), minerAddr, &st); err != nil {
+		return addr.Undef, err
+	}
+
+	// Confirming the sectors activates their deals, takes initial pledge and claims power for the miner.
+	if _, err := b.applyImplicit(builtin.StoragePowerActorAddr, minerAddr, big.Zero(), builtin.MethodsMiner.ConfirmSectorProofsValid,
+		&builtin.ConfirmSectorProofsParams{Sectors: sectorNos}); err != nil {
+		return addr.Undef, xerrors.Errorf("failed to confirm sectors: %w", err)
+	}
+
+	// Sectors with invalid deals are silently dropped by confirmation.
+	var confirmed miner.State
+	if err := b.vm.GetState(minerAddr, &confirmed); err != nil {
+		return addr.Undef, err
+	}
+	for _, sectorNo := range sectorNos {
+		if _, found, err := confirmed.GetSector(store, sectorNo); err != nil {
+			return addr.Undef, err
+		} else if !found {
+			return addr.Undef, xerrors.Errorf("sector %d was not confirmed", sectorNo)
+		}
+	}
+	return minerAddr, nil
+}
+
+// Escrows collateral for and publishes deals with a miner as provider, returning the new deal IDs.
+func (b *builder) publishDeals(m Miner, minerAddr addr.Address, deals []market.DealProposal) ([]abi.DealID, error) {
+	if len(deals) == 0 {
+		return nil, nil
+	}
+
+	params := market.PublishStorageDealsParams{}
+	for _, deal := range deals {
+		deal.Provider = minerAddr
+
+		client := deal.Client
+		if _, err := b.apply(client, builtin.StorageMarketActorAddr, deal.ClientBalanceRequirement(), builtin.MethodsMarket.AddBalance, &client); err != nil {
+			return nil, xerrors.Errorf("failed to escrow client balance for %v: %w", client, err)
+		}
+		if _, err := b.apply(m.Owner, builtin.StorageMarketActorAddr, deal.ProviderBalanceRequirement(), builtin.MethodsMarket.AddBalance, &minerAddr); err != nil {
+			return nil, xerrors.Errorf("failed to escrow provider balance for %v: %w", minerAddr, err)
+		}
+
+		// Genesis deals are not signed. Client signatures are not retained in state.
+		params.Deals = append(params.Deals, market.ClientDealProposal{
+			Proposal:        deal,
+			ClientSignature: crypto.Signature{Type: crypto.SigTypeBLS},
+		})
+	}
+
+	ret, err := b.apply(m.Worker, builtin.StorageMarketActorAddr, big.Zero(), builtin.MethodsMarket.PublishStorageDeals, &params)
+	if err != nil {
+		return nil, err
+	}
+	var published market.PublishStorageDealsReturn
+	if err := ret.Into(&published); err != nil {
+		return nil, err
+	}
+	return published.IDs, nil
+}
+
+func (b *builder) apply(from, to addr.Address, value abi.TokenAmount, method abi.MethodNum, params interface{}) (runtime.SendReturn, error) {
+	ret, code := b.vm.ApplyMessage(from, to, value, method, params)
+	if code != exitcode.Ok {
+		return nil, xerrors.Errorf("message from %v to %v method %d failed: exit code %d", from, to, method, code)
+	}
+	return ret, nil
+}
+
+func (b *builder) applyImplicit(from, to addr.Address, value abi.TokenAmount, method abi.MethodNum, params interface{}) (runtime.SendReturn, error) {
+	ret, code := b.vm.ApplyImplicitMessage(from, to, value, method, params)
+	if code != exitcode.Ok {
+		return nil, xerrors.Errorf("message from %v to %v method %d failed: exit code %d", from, to, method, code)
+	}
+	return ret, nil
+}
+
+func serialize(o runtime.CBORMarshaler) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := o.MarshalCBOR(buf); err != nil {
+		return nil, xerrors.Errorf("failed to serialize %T: %w", o, err)
+	}
+	return buf.Bytes(), nil
+}
+
+func zeroIfNil(amount abi.TokenAmount) abi.TokenAmount {
+	if amount.Nil() {
+		return big.Zero()
+	}
+	return amount
+}
@@ -0,0 +1,244 @@
+package genesis_test
+
+import (
+	"context"
+	"testing"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	big "github.com/filecoin-project/specs-actors/actors/abi/big"
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	init_ "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	market "github.com/filecoin-project/specs-actors/actors/builtin/market"
+	miner "github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	multisig "github.com/filecoin-project/specs-actors/actors/builtin/multisig"
+	power "github.com/filecoin-project/specs-actors/actors/builtin/power"
+	verifreg "github.com/filecoin-project/specs-actors/actors/builtin/verifreg"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/support/genesis"
+	ipld "github.com/filecoin-project/specs-actors/support/ipld"
+	tutil "github.com/filecoin-project/specs-actors/support/testing"
+	vm "github.com/filecoin-project/specs-actors/support/vm"
+)
+
+func TestBuild(t *testing.T) {
+	ctx := context.Background()
+	owner := tutil.NewBLSAddr(t, 1)
+	client := tutil.NewSECP256K1Addr(t, "client")
+	signer := tutil.NewBLSAddr(t, 2)
+
+	deal := market.DealProposal{
+		PieceCID:             tutil.MakeCID("piece", &market.PieceCIDPrefix),
+		PieceSize:            abi.PaddedPieceSize(1 << 20),
+		Client:               client,
+		StartEpoch:           0,
+		EndEpoch:             200 * builtin.EpochsInDay,
+		StoragePricePerEpoch: abi.NewTokenAmount(10),
+		ProviderCollateral:   abi.NewTokenAmount(1000),
+		ClientCollateral:     abi.NewTokenAmount(100),
+	}
+
+	cfg := genesis.Config{
+		NetworkName:     "genesis-test",
+		VerifregRootKey: signer,
+		RewardBalance:   big.Mul(big.NewInt(1e6), vm.FIL),
+		Accounts: []genesis.Account{
+			{Address: owner, Balance: big.Mul(big.NewInt(10_000), vm.FIL)},
+			{Address: client, Balance: vm.FIL},
+			{Address: signer, Balance: big.Zero()},
+		},
+		Multisigs: []genesis.Multisig{
+			{Signers: []addr.Address{owner, signer}, Threshold: 2, UnlockDuration: 1000, Balance: big.Mul(big.NewInt(500), vm.FIL)},
+			{Signers: []addr.Address{signer}, Threshold: 1, Balance: vm.FIL},
+		},
+		Miners: []genesis.Miner{{
+			Owner:         owner,
+			Worker:        owner,
+			SealProofType: abi.RegisteredSealProof_StackedDrg32GiBV1,
+			PeerID:        abi.PeerID("peer"),
+			Balance:       big.Mul(big.NewInt(1000), vm.FIL),
+			Sectors: []genesis.Sector{{
+				SectorNumber: 0,
+				SealedCID:    tutil.MakeCID("commr-0", &miner.SealedCIDPrefix),
+				Expiration:   300 * builtin.EpochsInDay,
+			}, {
+				SectorNumber: 1,
+				SealedCID:    tutil.MakeCID("commr-1", &miner.SealedCIDPrefix),
+				Expiration:   300 * builtin.EpochsInDay,
+				Deals:        []market.DealProposal{deal},
+			}},
+		}},
+	}
+
+	store := ipld.NewADTStore(ctx)
+	result, err := genesis.Build(ctx, store, &cfg)
+	require.NoError(t, err)
+	require.Len(t, result.Accounts, 3)
+	require.Len(t, result.Multisigs, 2)
+	require.Len(t, result.Miners, 1)
+
+	v, err := vm.NewVMAtEpoch(ctx, vm.BuiltinActorImpls(), store, result.StateRoot, 0)
+	require.NoError(t, err)
+
+	t.Run("singletons", func(t *testing.T) {
+		var initState init_.State
+		require.NoError(t, v.GetState(builtin.InitActorAddr, &initState))
+		assert.Equal(t, "genesis-test", initState.NetworkName)
+
+		var vrState verifreg.State
+		require.NoError(t, v.GetState(builtin.VerifiedRegistryActorAddr, &vrState))
+		assert.Equal(t, result.Accounts[2], vrState.RootKey)
+
+		// All distributed funds have left the system actor.
+		act, found, err := v.GetActor(builtin.SystemActorAddr)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, big.Zero(), act.Balance)
+		assert.Equal(t, uint64(len(cfg.Multisigs)), act.CallSeqNum)
+
+		// No value is created or destroyed.
+		total, err := v.GetTotalActorBalance()
+		require.NoError(t, err)
+		expected := cfg.RewardBalance
+		for _, a := range cfg.Accounts {
+			expected = big.Add(expected, a.Balance)
+		}
+		for _, m := range cfg.Multisigs {
+			expected = big.Add(expected, m.Balance)
+		}
+		assert.Equal(t, expected, total)
+	})
+
+	t.Run("accounts and multisigs", func(t *testing.T) {
+		for i, a := range cfg.Accounts {
+			idAddr, found := v.NormalizeAddress(a.Address)
+			require.True(t, found)
+			assert.Equal(t, result.Accounts[i], idAddr)
+		}
+
+		var msigState multisig.State
+		require.NoError(t, v.GetState(result.Multisigs[0], &msigState))
+		assert.Equal(t, uint64(2), msigState.NumApprovalsThreshold)
+		assert.Equal(t, abi.ChainEpoch(1000), msigState.UnlockDuration)
+		assert.Equal(t, cfg.Multisigs[0].Balance, msigState.InitialBalance)
+		assert.NotEqual(t, result.Multisigs[0], result.Multisigs[1])
+	})
+
+	t.Run("miner sectors and deals", func(t *testing.T) {
+		minerAddr := result.Miners[0]
+
+		var minerState miner.State
+		require.NoError(t, v.GetState(minerAddr, &minerState))
+		for _, sector := range cfg.Miners[0].Sectors {
+			info, found, err := minerState.GetSector(v.Store(), sector.SectorNumber)
+			require.NoError(t, err)
+			require.True(t, found)
+			assert.Equal(t, sector.SealedCID, info.SealedCID)
+			assert.Equal(t, len(sector.Deals), len(info.DealIDs))
+		}
+
+		var powerState power.State
+		require.NoError(t, v.GetState(builtin.StoragePowerActorAddr, &powerState))
+		claims, err := adt.AsMap(v.Store(), powerState.Claims)
+		require.NoError(t, err)
+		var claim power.Claim
+		found, err := claims.Get(adt.AddrKey(minerAddr), &claim)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, big.NewIntUnsigned(2*uint64(abi.SectorSize(32<<30))), claim.RawBytePower)
+		assert.True(t, powerState.TotalPledgeCollateral.GreaterThan(big.Zero()))
+
+		var marketState market.State
+		require.NoError(t, v.GetState(builtin.StorageMarketActorAddr, &marketState))
+		proposals, err := market.AsDealProposalArray(v.Store(), marketState.Proposals)
+		require.NoError(t, err)
+		proposal, found, err := proposals.Get(0)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, minerAddr, proposal.Provider)
+		assert.Equal(t, result.Accounts[1], proposal.Client)
+
+		dealStates, err := market.AsDealStateArray(v.Store(), marketState.States)
+		require.NoError(t, err)
+		dealState, found, err := dealStates.Get(0)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, abi.ChainEpoch(0), dealState.SectorStartEpoch)
+	})
+
+	t.Run("deterministic", func(t *testing.T) {
+		again, err := genesis.Build(ctx, ipld.NewADTStore(ctx), &cfg)
+		require.NoError(t, err)
+		assert.Equal(t, result.StateRoot, again.StateRoot)
+	})
+}
+
+func TestBuildUnfunded(t *testing.T) {
+	ctx := context.Background()
+	owner := tutil.NewBLSAddr(t, 1)
+	signer := tutil.NewSECP256K1Addr(t, "signer")
+
+	// Balances are omitted, as from a declarative configuration.
+	store := ipld.NewADTStore(ctx)
+	result, err := genesis.Build(ctx, store, &genesis.Config{
+		VerifregRootKey: signer,
+		Accounts:        []genesis.Account{{Address: owner}, {Address: signer}},
+		Multisigs:       []genesis.Multisig{{Signers: []addr.Address{signer}, Threshold: 1}},
+		Miners: []genesis.Miner{{
+			Owner:         owner,
+			Worker:        owner,
+			SealProofType: abi.RegisteredSealProof_StackedDrg32GiBV1,
+		}},
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Accounts, 2)
+	require.Len(t, result.Multisigs, 1)
+	require.Len(t, result.Miners, 1)
+
+	v, err := vm.NewVMAtEpoch(ctx, vm.BuiltinActorImpls(), store, result.StateRoot, 0)
+	require.NoError(t, err)
+	total, err := v.GetTotalActorBalance()
+	require.NoError(t, err)
+	assert.Equal(t, big.Zero(), total)
+}
+
+func TestBuildFailures(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("duplicate account", func(t *testing.T) {
+		a := tutil.NewBLSAddr(t, 1)
+		_, err := genesis.Build(ctx, ipld.NewADTStore(ctx), &genesis.Config{
+			VerifregRootKey: builtin.SystemActorAddr,
+			Accounts: []genesis.Account{
+				{Address: a, Balance: vm.FIL},
+				{Address: a, Balance: vm.FIL},
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("unfunded miner owner", func(t *testing.T) {
+		owner := tutil.NewBLSAddr(t, 1)
+		_, err := genesis.Build(ctx, ipld.NewADTStore(ctx), &genesis.Config{
+			VerifregRootKey: builtin.SystemActorAddr,
+			Accounts:        []genesis.Account{{Address: owner, Balance: big.Zero()}},
+			Miners: []genesis.Miner{{
+				Owner:         owner,
+				Worker:        owner,
+				SealProofType: abi.RegisteredSealProof_StackedDrg32GiBV1,
+				Balance:       vm.FIL,
+			}},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("unresolvable root key", func(t *testing.T) {
+		_, err := genesis.Build(ctx, ipld.NewADTStore(ctx), &genesis.Config{
+			VerifregRootKey: tutil.NewBLSAddr(t, 1),
+		})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,53 @@
+package inspect
+
+import (
+	"sort"
+
+	addr "github.com/filecoin-project/go-address"
+	cid "github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	init_ "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+)
+
+// An expanded init actor state.
+type InitState struct {
+	AddressMap  []AddressID
+	NextID      abi.ActorID
+	NetworkName string
+}
+
+// An address and the actor ID to which it resolves.
+type AddressID struct {
+	Address addr.Address
+	ID      abi.ActorID
+}
+
+// Loads and expands the init actor state, including every address mapping.
+func Init(store adt.Store, head cid.Cid) (*InitState, error) {
+	var st init_.State
+	if err := store.Get(store.Context(), head, &st); err != nil {
+		return nil, xerrors.Errorf("failed to load init state %s: %w", head, err)
+	}
+	out := &InitState{AddressMap: []AddressID{}, NextID: st.NextID, NetworkName: st.NetworkName}
+	m, err := adt.AsMap(store, st.AddressMap)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load address map: %w", err)
+	}
+	var id cbg.CborInt
+	if err = m.ForEach(&id, func(key string) error {
+		a, err := addr.NewFromBytes([]byte(key))
+		if err != nil {
+			return err
+		}
+		out.AddressMap = append(out.AddressMap, AddressID{Address: a, ID: abi.ActorID(id)})
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("failed to load address map: %w", err)
+	}
+	sort.Slice(out.AddressMap, func(i, j int) bool { return out.AddressMap[i].ID < out.AddressMap[j].ID })
+	return out, nil
+}
@@ -0,0 +1,127 @@
+// Package inspect expands actor state into values for display, loading the contents of the HAMTs, AMTs and bitfields
+// to which the state refers.
+// The expanded values follow the structure of each actor's state, with field names matching the state's, so encode to
+// JSON that reads like the state itself. Collections are sorted by key and bitfields are listed as the numbers they
+// contain.
+package inspect
+
+import (
+	"sort"
+
+	addr "github.com/filecoin-project/go-address"
+	bitfield "github.com/filecoin-project/go-bitfield"
+	cid "github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	account "github.com/filecoin-project/specs-actors/actors/builtin/account"
+	cron "github.com/filecoin-project/specs-actors/actors/builtin/cron"
+	paych "github.com/filecoin-project/specs-actors/actors/builtin/paych"
+	reward "github.com/filecoin-project/specs-actors/actors/builtin/reward"
+	system "github.com/filecoin-project/specs-actors/actors/builtin/system"
+	runtime "github.com/filecoin-project/specs-actors/actors/runtime"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+)
+
+// Expands the state of a builtin actor, selecting the kind of state by the actor's code CID.
+// States without collections are returned as decoded.
+func ActorState(store adt.Store, code cid.Cid, head cid.Cid) (interface{}, error) {
+	switch {
+	case code.Equals(builtin.StorageMinerActorCodeID):
+		return Miner(store, head)
+	case code.Equals(builtin.StorageMarketActorCodeID):
+		return Market(store, head)
+	case code.Equals(builtin.StoragePowerActorCodeID):
+		return Power(store, head)
+	case code.Equals(builtin.VerifiedRegistryActorCodeID):
+		return VerifiedRegistry(store, head)
+	case code.Equals(builtin.InitActorCodeID):
+		return Init(store, head)
+	case code.Equals(builtin.MultisigActorCodeID):
+		return Multisig(store, head)
+	case code.Equals(builtin.AccountActorCodeID):
+		return decodeState(store, head, &account.State{})
+	case code.Equals(builtin.CronActorCodeID):
+		return decodeState(store, head, &cron.State{})
+	case code.Equals(builtin.PaymentChannelActorCodeID):
+		return decodeState(store, head, &paych.State{})
+	case code.Equals(builtin.RewardActorCodeID):
+		return decodeState(store, head, &reward.State{})
+	case code.Equals(builtin.SystemActorCodeID):
+		return decodeState(store, head, &system.State{})
+	default:
+		return nil, xerrors.Errorf("no state inspector for actor code %s", code)
+	}
+}
+
+func decodeState(store adt.Store, head cid.Cid, out runtime.CBORUnmarshaler) (interface{}, error) {
+	if err := store.Get(store.Context(), head, out); err != nil {
+		return nil, xerrors.Errorf("failed to load state %s: %w", head, err)
+	}
+	return out, nil
+}
+
+// The numbers set in a bitfield, in increasing order.
+// Bitfields are listed rather than encoded as runs, so are never null.
+type Bits []uint64
+
+func expandBits(bf bitfield.BitField) (Bits, error) {
+	out := Bits{}
+	err := bf.ForEach(func(i uint64) error {
+		out = append(out, i)
+		return nil
+	})
+	return out, err
+}
+
+// The bitfield stored at an epoch in a queue.
+type EpochBits struct {
+	Epoch abi.ChainEpoch
+	Bits  Bits
+}
+
+// Expands an AMT of bitfields keyed by epoch.
+func expandBitfieldQueue(store adt.Store, root cid.Cid) ([]EpochBits, error) {
+	arr, err := adt.AsArray(store, root)
+	if err != nil {
+		return nil, err
+	}
+	out := []EpochBits{}
+	var bf bitfield.BitField
+	err = arr.ForEach(&bf, func(epoch int64) error {
+		b, err := expandBits(bf)
+		if err != nil {
+			return err
+		}
+		out = append(out, EpochBits{Epoch: abi.ChainEpoch(epoch), Bits: b})
+		return nil
+	})
+	return out, err
+}
+
+// An amount held for an address, as in a balance table or data cap map.
+type Balance struct {
+	Address addr.Address
+	Amount  abi.TokenAmount
+}
+
+// Expands a HAMT of token amounts keyed by address, sorted by address.
+func expandBalances(store adt.Store, root cid.Cid) ([]Balance, error) {
+	m, err := adt.AsMap(store, root)
+	if err != nil {
+		return nil, err
+	}
+	out := []Balance{}
+	var amount abi.TokenAmount
+	err = m.ForEach(&amount, func(key string) error {
+		a, err := addr.NewFromBytes([]byte(key))
+		if err != nil {
+			return err
+		}
+		out = append(out, Balance{Address: a, Amount: amount})
+		return nil
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].Address.String() < out[j].Address.String() })
+	return out, err
+}
@@ -0,0 +1,202 @@
+package inspect_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-bitfield"
+	cid "github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	big "github.com/filecoin-project/specs-actors/actors/abi/big"
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	miner "github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	power "github.com/filecoin-project/specs-actors/actors/builtin/power"
+	states "github.com/filecoin-project/specs-actors/actors/states"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/support/inspect"
+	ipld "github.com/filecoin-project/specs-actors/support/ipld"
+	tutil "github.com/filecoin-project/specs-actors/support/testing"
+	vm "github.com/filecoin-project/specs-actors/support/vm"
+)
+
+func TestMiner(t *testing.T) {
+	ctx := context.Background()
+	store := ipld.NewADTStore(ctx)
+	sealProof := abi.RegisteredSealProof_StackedDrg32GiBV1
+	sectorSize, err := sealProof.SectorSize()
+	require.NoError(t, err)
+	partitionSize, err := sealProof.WindowPoStPartitionSectors()
+	require.NoError(t, err)
+
+	emptyMap, err := adt.MakeEmptyMap(store).Root()
+	require.NoError(t, err)
+	emptyArray, err := adt.MakeEmptyArray(store).Root()
+	require.NoError(t, err)
+	emptyBitfield, err := store.Put(ctx, bitfield.New())
+	require.NoError(t, err)
+	emptyDeadline, err := store.Put(ctx, miner.ConstructDeadline(emptyArray))
+	require.NoError(t, err)
+	emptyDeadlines, err := store.Put(ctx, miner.ConstructDeadlines(emptyDeadline))
+	require.NoError(t, err)
+	info, err := miner.ConstructMinerInfo(tutil.NewIDAddr(t, 100), tutil.NewIDAddr(t, 101), abi.PeerID("peer"), nil, sealProof)
+	require.NoError(t, err)
+	infoCid, err := store.Put(ctx, info)
+	require.NoError(t, err)
+	st, err := miner.ConstructState(infoCid, 0, emptyBitfield, emptyArray, emptyMap, emptyDeadlines)
+	require.NoError(t, err)
+
+	sectors := []*miner.SectorOnChainInfo{makeSector(1, 1000), makeSector(2, 1000), makeSector(3, 5000)}
+	for _, sector := range sectors {
+		require.NoError(t, st.AllocateSectorNumber(store, sector.SectorNumber))
+	}
+	require.NoError(t, st.PutSectors(store, sectors...))
+	livePower, err := st.AssignSectorsToDeadlines(store, 0, sectors, partitionSize, sectorSize)
+	require.NoError(t, err)
+	require.NoError(t, st.AllocateSectorNumber(store, 4))
+	require.NoError(t, st.PutPrecommittedSector(store, &miner.SectorPreCommitOnChainInfo{
+		Info: miner.SectorPreCommitInfo{
+			SealProof:    sealProof,
+			SectorNumber: 4,
+			SealedCID:    tutil.MakeCID("commR", &miner.SealedCIDPrefix),
+			Expiration:   3000,
+		},
+		PreCommitDeposit:   abi.NewTokenAmount(10),
+		DealWeight:         big.Zero(),
+		VerifiedDealWeight: big.Zero(),
+	}))
+	head, err := store.Put(ctx, st)
+	require.NoError(t, err)
+
+	t.Run("state", func(t *testing.T) {
+		out, err := inspect.Miner(store, head)
+		require.NoError(t, err)
+		assert.Equal(t, info.Owner, out.Info.Owner)
+		assert.Equal(t, inspect.Bits{1, 2, 3, 4}, out.AllocatedSectors)
+		require.Len(t, out.Sectors, 3)
+		assert.Equal(t, sectors[2], out.Sectors[2])
+		require.Len(t, out.PreCommittedSectors, 1)
+		assert.Equal(t, abi.SectorNumber(4), out.PreCommittedSectors[0].Info.SectorNumber)
+		assert.Len(t, out.Deadlines, int(miner.WPoStPeriodDeadlines))
+		assert.Equal(t, inspect.Bits{}, out.EarlyTerminations)
+
+		encoded, err := json.Marshal(out)
+		require.NoError(t, err)
+		assert.Contains(t, string(encoded), `"AllocatedSectors":[1,2,3,4]`)
+		assert.Contains(t, string(encoded), `"PreCommitDeposit":"10"`)
+	})
+
+	t.Run("sector and deadline", func(t *testing.T) {
+		sector, err := inspect.MinerSector(store, head, 2)
+		require.NoError(t, err)
+		assert.Equal(t, sectors[1], sector.Info)
+		assert.False(t, sector.Faulty || sector.Recovering || sector.Terminated)
+
+		dl, err := inspect.MinerDeadline(store, head, sector.Deadline)
+		require.NoError(t, err)
+		assert.Equal(t, sector.Deadline, dl.Index)
+		assert.Equal(t, uint64(3), dl.LiveSectors)
+		require.Len(t, dl.Partitions, 1)
+		part := dl.Partitions[sector.Partition]
+		assert.Equal(t, inspect.Bits{1, 2, 3}, part.Sectors)
+		assert.Equal(t, inspect.Bits{}, part.Faults)
+		assert.Equal(t, livePower, part.LivePower)
+		require.NotEmpty(t, part.ExpirationsEpochs)
+		assert.Equal(t, inspect.Bits{1, 2}, part.ExpirationsEpochs[0].OnTimeSectors)
+
+		_, err = inspect.MinerSector(store, head, 4)
+		assert.Error(t, err, "pre-committed sector is not proven")
+		_, err = inspect.MinerDeadline(store, head, miner.WPoStPeriodDeadlines)
+		assert.Error(t, err)
+	})
+}
+
+func TestActorState(t *testing.T) {
+	ctx := context.Background()
+	v := vm.NewVMWithSingletons(ctx, t)
+	addrs := vm.CreateAccounts(ctx, t, v, 1, big.Mul(big.NewInt(10_000), vm.FIL), 93837778)
+	owner := addrs[0]
+
+	vm.ApplyOk(t, v, owner, builtin.StorageMarketActorAddr, vm.FIL, builtin.MethodsMarket.AddBalance, &owner)
+	ret := vm.ApplyOk(t, v, owner, builtin.StoragePowerActorAddr, big.Zero(), builtin.MethodsPower.CreateMiner, &power.CreateMinerParams{
+		Owner:         owner,
+		Worker:        owner,
+		SealProofType: abi.RegisteredSealProof_StackedDrg32GiBV1,
+		Peer:          abi.PeerID("peer"),
+	})
+	var minerAddrs power.CreateMinerReturn
+	require.NoError(t, ret.Into(&minerAddrs))
+
+	t.Run("every actor", func(t *testing.T) {
+		tree, err := states.LoadTree(v.Store(), v.StateRoot())
+		require.NoError(t, err)
+		require.NoError(t, tree.ForEach(func(a addr.Address, act *states.Actor) error {
+			out, err := inspect.ActorState(v.Store(), act.Code, act.Head)
+			require.NoError(t, err, "actor %v", a)
+			_, err = json.Marshal(out)
+			require.NoError(t, err, "actor %v", a)
+			return nil
+		}))
+	})
+
+	t.Run("market", func(t *testing.T) {
+		out, err := inspect.ActorState(v.Store(), builtin.StorageMarketActorCodeID, actorHead(t, v, builtin.StorageMarketActorAddr))
+		require.NoError(t, err)
+		market := out.(*inspect.MarketState)
+		ownerID, found := v.NormalizeAddress(owner)
+		require.True(t, found)
+		assert.Equal(t, []inspect.Balance{{Address: ownerID, Amount: vm.FIL}}, market.EscrowTable)
+		assert.Empty(t, market.Deals)
+
+		_, err = inspect.MarketDeal(v.Store(), actorHead(t, v, builtin.StorageMarketActorAddr), 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("power and init", func(t *testing.T) {
+		out, err := inspect.Power(v.Store(), actorHead(t, v, builtin.StoragePowerActorAddr))
+		require.NoError(t, err)
+		require.Len(t, out.Claims, 1)
+		assert.Equal(t, minerAddrs.IDAddress, out.Claims[0].Address)
+		assert.Nil(t, out.ProofValidationBatch)
+
+		initSt, err := inspect.Init(v.Store(), actorHead(t, v, builtin.InitActorAddr))
+		require.NoError(t, err)
+		assert.Contains(t, initSt.AddressMap, inspect.AddressID{Address: minerAddrs.RobustAddress, ID: mustIDFromAddress(t, minerAddrs.IDAddress)})
+	})
+
+	t.Run("unknown code", func(t *testing.T) {
+		_, err := inspect.ActorState(v.Store(), tutil.MakeCID("unknown", nil), actorHead(t, v, builtin.InitActorAddr))
+		assert.Error(t, err)
+	})
+}
+
+func makeSector(number abi.SectorNumber, expiration abi.ChainEpoch) *miner.SectorOnChainInfo {
+	return &miner.SectorOnChainInfo{
+		SectorNumber:          number,
+		SealProof:             abi.RegisteredSealProof_StackedDrg32GiBV1,
+		SealedCID:             tutil.MakeCID("commR", &miner.SealedCIDPrefix),
+		Expiration:            expiration,
+		DealWeight:            big.Zero(),
+		VerifiedDealWeight:    big.Zero(),
+		InitialPledge:         abi.NewTokenAmount(1 << 20),
+		ExpectedDayReward:     big.Zero(),
+		ExpectedStoragePledge: big.Zero(),
+	}
+}
+
+func actorHead(t *testing.T, v *vm.VM, a addr.Address) cid.Cid {
+	act, found, err := v.GetActor(a)
+	require.NoError(t, err)
+	require.True(t, found)
+	return act.Head
+}
+
+func mustIDFromAddress(t *testing.T, a addr.Address) abi.ActorID {
+	id, err := addr.IDFromAddress(a)
+	require.NoError(t, err)
+	return abi.ActorID(id)
+}
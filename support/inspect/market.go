@@ -0,0 +1,199 @@
+package inspect
+
+import (
+	"sort"
+
+	cid "github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	market "github.com/filecoin-project/specs-actors/actors/builtin/market"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+)
+
+// An expanded market state.
+// The Proposals and States arrays are joined by deal ID into Deals.
+type MarketState struct {
+	Deals                         []*Deal
+	PendingProposals              []cid.Cid
+	EscrowTable                   []Balance
+	LockedTable                   []Balance
+	NextID                        abi.DealID
+	DealOpsByEpoch                []EpochDeals
+	LastCron                      abi.ChainEpoch
+	TotalClientLockedCollateral   abi.TokenAmount
+	TotalProviderLockedCollateral abi.TokenAmount
+	TotalClientStorageFee         abi.TokenAmount
+}
+
+// A deal's proposal and, once the deal has been activated, its state.
+type Deal struct {
+	ID       abi.DealID
+	Proposal *market.DealProposal
+	State    *market.DealState
+}
+
+// The deals scheduled for processing at an epoch.
+type EpochDeals struct {
+	Epoch abi.ChainEpoch
+	Deals []abi.DealID
+}
+
+// Loads and expands the market state, including every deal.
+func Market(store adt.Store, head cid.Cid) (*MarketState, error) {
+	st, err := loadMarket(store, head)
+	if err != nil {
+		return nil, err
+	}
+	out := &MarketState{
+		NextID:                        st.NextID,
+		LastCron:                      st.LastCron,
+		TotalClientLockedCollateral:   st.TotalClientLockedCollateral,
+		TotalProviderLockedCollateral: st.TotalProviderLockedCollateral,
+		TotalClientStorageFee:         st.TotalClientStorageFee,
+	}
+
+	proposals, err := adt.AsArray(store, st.Proposals)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load proposals: %w", err)
+	}
+	states, err := adt.AsArray(store, st.States)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load deal states: %w", err)
+	}
+	out.Deals = []*Deal{}
+	var proposal market.DealProposal
+	if err = proposals.ForEach(&proposal, func(id int64) error {
+		deal, err := loadDealState(states, abi.DealID(id))
+		if err != nil {
+			return err
+		}
+		p := proposal
+		deal.Proposal = &p
+		out.Deals = append(out.Deals, deal)
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("failed to load deals: %w", err)
+	}
+
+	pending, err := adt.AsMap(store, st.PendingProposals)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load pending proposals: %w", err)
+	}
+	out.PendingProposals = []cid.Cid{}
+	if err = pending.ForEach(nil, func(key string) error {
+		c, err := cid.Cast([]byte(key))
+		if err != nil {
+			return err
+		}
+		out.PendingProposals = append(out.PendingProposals, c)
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("failed to load pending proposals: %w", err)
+	}
+	sort.Slice(out.PendingProposals, func(i, j int) bool {
+		return out.PendingProposals[i].String() < out.PendingProposals[j].String()
+	})
+
+	if out.EscrowTable, err = expandBalances(store, st.EscrowTable); err != nil {
+		return nil, xerrors.Errorf("failed to load escrow table: %w", err)
+	}
+	if out.LockedTable, err = expandBalances(store, st.LockedTable); err != nil {
+		return nil, xerrors.Errorf("failed to load locked table: %w", err)
+	}
+	if out.DealOpsByEpoch, err = expandDealOps(store, st.DealOpsByEpoch); err != nil {
+		return nil, xerrors.Errorf("failed to load deal ops: %w", err)
+	}
+	return out, nil
+}
+
+// Loads a deal's proposal and state.
+func MarketDeal(store adt.Store, head cid.Cid, id abi.DealID) (*Deal, error) {
+	st, err := loadMarket(store, head)
+	if err != nil {
+		return nil, err
+	}
+	proposals, err := adt.AsArray(store, st.Proposals)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load proposals: %w", err)
+	}
+	states, err := adt.AsArray(store, st.States)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load deal states: %w", err)
+	}
+	var proposal market.DealProposal
+	found, err := proposals.Get(uint64(id), &proposal)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load proposal %d: %w", id, err)
+	}
+	if !found {
+		return nil, xerrors.Errorf("no deal %d", id)
+	}
+	deal, err := loadDealState(states, id)
+	if err != nil {
+		return nil, err
+	}
+	deal.Proposal = &proposal
+	return deal, nil
+}
+
+func loadMarket(store adt.Store, head cid.Cid) (*market.State, error) {
+	var st market.State
+	if err := store.Get(store.Context(), head, &st); err != nil {
+		return nil, xerrors.Errorf("failed to load market state %s: %w", head, err)
+	}
+	return &st, nil
+}
+
+// Returns a deal with its state, if any.
+func loadDealState(states *adt.Array, id abi.DealID) (*Deal, error) {
+	var state market.DealState
+	found, err := states.Get(uint64(id), &state)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load state of deal %d: %w", id, err)
+	}
+	deal := &Deal{ID: id}
+	if found {
+		deal.State = &state
+	}
+	return deal, nil
+}
+
+// Expands the set multimap of deals by epoch, sorted by epoch and deal ID.
+func expandDealOps(store adt.Store, root cid.Cid) ([]EpochDeals, error) {
+	m, err := adt.AsMap(store, root)
+	if err != nil {
+		return nil, err
+	}
+	out := []EpochDeals{}
+	var setRoot cbg.CborCid
+	if err = m.ForEach(&setRoot, func(key string) error {
+		epoch, err := adt.ParseUIntKey(key)
+		if err != nil {
+			return err
+		}
+		set, err := adt.AsSet(store, cid.Cid(setRoot))
+		if err != nil {
+			return err
+		}
+		ops := EpochDeals{Epoch: abi.ChainEpoch(epoch), Deals: []abi.DealID{}}
+		if err = set.ForEach(func(k string) error {
+			id, err := adt.ParseUIntKey(k)
+			if err != nil {
+				return err
+			}
+			ops.Deals = append(ops.Deals, abi.DealID(id))
+			return nil
+		}); err != nil {
+			return err
+		}
+		sort.Slice(ops.Deals, func(i, j int) bool { return ops.Deals[i] < ops.Deals[j] })
+		out = append(out, ops)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Epoch < out[j].Epoch })
+	return out, nil
+}
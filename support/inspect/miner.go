@@ -0,0 +1,331 @@
+package inspect
+
+import (
+	"sort"
+
+	bitfield "github.com/filecoin-project/go-bitfield"
+	cid "github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	miner "github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+)
+
+// An expanded miner state.
+type MinerState struct {
+	Info                      *miner.MinerInfo
+	PreCommitDeposits         abi.TokenAmount
+	LockedFunds               abi.TokenAmount
+	VestingFunds              []VestingFund
+	InitialPledgeRequirement  abi.TokenAmount
+	PreCommittedSectors       []*miner.SectorPreCommitOnChainInfo
+	PreCommittedSectorsExpiry []EpochBits
+	AllocatedSectors          Bits
+	Sectors                   []*miner.SectorOnChainInfo
+	ProvingPeriodStart        abi.ChainEpoch
+	CurrentDeadline           uint64
+	Deadlines                 []*Deadline
+	EarlyTerminations         Bits
+}
+
+// An amount of locked funds vesting at an epoch.
+type VestingFund struct {
+	Epoch  abi.ChainEpoch
+	Amount abi.TokenAmount
+}
+
+// An expanded deadline.
+type Deadline struct {
+	Index             uint64
+	Partitions        []*Partition
+	ExpirationsEpochs []EpochBits
+	PostSubmissions   Bits
+	EarlyTerminations Bits
+	LiveSectors       uint64
+	TotalSectors      uint64
+	FaultyPower       miner.PowerPair
+}
+
+// An expanded partition.
+type Partition struct {
+	Index             uint64
+	Sectors           Bits
+	Faults            Bits
+	Recoveries        Bits
+	Terminated        Bits
+	ExpirationsEpochs []*ExpirationSet
+	EarlyTerminated   []EpochBits
+	LivePower         miner.PowerPair
+	FaultyPower       miner.PowerPair
+	RecoveringPower   miner.PowerPair
+}
+
+// An expanded expiration set, with the epoch at which it is queued.
+type ExpirationSet struct {
+	Epoch         abi.ChainEpoch
+	OnTimeSectors Bits
+	EarlySectors  Bits
+	OnTimePledge  abi.TokenAmount
+	ActivePower   miner.PowerPair
+	FaultyPower   miner.PowerPair
+}
+
+// A proven sector, with its location and status.
+type Sector struct {
+	Info       *miner.SectorOnChainInfo
+	Deadline   uint64
+	Partition  uint64
+	Faulty     bool
+	Recovering bool
+	Terminated bool
+}
+
+// Loads and expands a miner's state, including every sector and deadline, so is expensive for miners with many
+// sectors.
+func Miner(store adt.Store, head cid.Cid) (*MinerState, error) {
+	st, err := loadMiner(store, head)
+	if err != nil {
+		return nil, err
+	}
+	out := &MinerState{
+		PreCommitDeposits:        st.PreCommitDeposits,
+		LockedFunds:              st.LockedFunds,
+		InitialPledgeRequirement: st.InitialPledgeRequirement,
+		ProvingPeriodStart:       st.ProvingPeriodStart,
+		CurrentDeadline:          st.CurrentDeadline,
+	}
+	if out.Info, err = st.GetInfo(store); err != nil {
+		return nil, xerrors.Errorf("failed to load info: %w", err)
+	}
+	if out.VestingFunds, err = expandVestingFunds(store, st.VestingFunds); err != nil {
+		return nil, xerrors.Errorf("failed to load vesting funds: %w", err)
+	}
+	if out.PreCommittedSectors, err = expandPreCommits(store, st.PreCommittedSectors); err != nil {
+		return nil, xerrors.Errorf("failed to load pre-committed sectors: %w", err)
+	}
+	if out.PreCommittedSectorsExpiry, err = expandBitfieldQueue(store, st.PreCommittedSectorsExpiry); err != nil {
+		return nil, xerrors.Errorf("failed to load pre-commit expiry queue: %w", err)
+	}
+	var allocated bitfield.BitField
+	if err = store.Get(store.Context(), st.AllocatedSectors, &allocated); err != nil {
+		return nil, xerrors.Errorf("failed to load allocated sectors: %w", err)
+	}
+	if out.AllocatedSectors, err = expandBits(allocated); err != nil {
+		return nil, xerrors.Errorf("failed to expand allocated sectors: %w", err)
+	}
+	out.Sectors = []*miner.SectorOnChainInfo{}
+	if err = st.ForEachSector(store, func(sector *miner.SectorOnChainInfo) {
+		info := *sector
+		out.Sectors = append(out.Sectors, &info)
+	}); err != nil {
+		return nil, xerrors.Errorf("failed to load sectors: %w", err)
+	}
+
+	deadlines, err := st.LoadDeadlines(store)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load deadlines: %w", err)
+	}
+	for dlIdx := range deadlines.Due {
+		dl, err := expandDeadline(store, deadlines, uint64(dlIdx))
+		if err != nil {
+			return nil, err
+		}
+		out.Deadlines = append(out.Deadlines, dl)
+	}
+	if out.EarlyTerminations, err = expandBits(st.EarlyTerminations); err != nil {
+		return nil, xerrors.Errorf("failed to expand early terminations: %w", err)
+	}
+	return out, nil
+}
+
+// Loads and expands one of a miner's deadlines.
+func MinerDeadline(store adt.Store, head cid.Cid, dlIdx uint64) (*Deadline, error) {
+	st, err := loadMiner(store, head)
+	if err != nil {
+		return nil, err
+	}
+	deadlines, err := st.LoadDeadlines(store)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load deadlines: %w", err)
+	}
+	return expandDeadline(store, deadlines, dlIdx)
+}
+
+// Loads a miner's proven sector and finds its deadline, partition and status.
+func MinerSector(store adt.Store, head cid.Cid, sectorNo abi.SectorNumber) (*Sector, error) {
+	st, err := loadMiner(store, head)
+	if err != nil {
+		return nil, err
+	}
+	info, found, err := st.GetSector(store, sectorNo)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load sector %d: %w", sectorNo, err)
+	}
+	if !found {
+		return nil, xerrors.Errorf("no sector %d", sectorNo)
+	}
+	dlIdx, partIdx, err := st.FindSector(store, sectorNo)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to find sector %d: %w", sectorNo, err)
+	}
+	deadlines, err := st.LoadDeadlines(store)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load deadlines: %w", err)
+	}
+	dl, err := deadlines.LoadDeadline(store, dlIdx)
+	if err != nil {
+		return nil, err
+	}
+	partition, err := dl.LoadPartition(store, partIdx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &Sector{Info: info, Deadline: dlIdx, Partition: partIdx}
+	for _, status := range []struct {
+		bf  bitfield.BitField
+		out *bool
+	}{
+		{partition.Faults, &out.Faulty},
+		{partition.Recoveries, &out.Recovering},
+		{partition.Terminated, &out.Terminated},
+	} {
+		if *status.out, err = status.bf.IsSet(uint64(sectorNo)); err != nil {
+			return nil, xerrors.Errorf("failed to read status of sector %d: %w", sectorNo, err)
+		}
+	}
+	return out, nil
+}
+
+func loadMiner(store adt.Store, head cid.Cid) (*miner.State, error) {
+	var st miner.State
+	if err := store.Get(store.Context(), head, &st); err != nil {
+		return nil, xerrors.Errorf("failed to load miner state %s: %w", head, err)
+	}
+	return &st, nil
+}
+
+func expandVestingFunds(store adt.Store, root cid.Cid) ([]VestingFund, error) {
+	arr, err := adt.AsArray(store, root)
+	if err != nil {
+		return nil, err
+	}
+	out := []VestingFund{}
+	var amount abi.TokenAmount
+	err = arr.ForEach(&amount, func(epoch int64) error {
+		out = append(out, VestingFund{Epoch: abi.ChainEpoch(epoch), Amount: amount})
+		return nil
+	})
+	return out, err
+}
+
+// Expands the pre-committed sectors, sorted by sector number.
+func expandPreCommits(store adt.Store, root cid.Cid) ([]*miner.SectorPreCommitOnChainInfo, error) {
+	m, err := adt.AsMap(store, root)
+	if err != nil {
+		return nil, err
+	}
+	out := []*miner.SectorPreCommitOnChainInfo{}
+	var info miner.SectorPreCommitOnChainInfo
+	if err = m.ForEach(&info, func(_ string) error {
+		precommit := info
+		out = append(out, &precommit)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Info.SectorNumber < out[j].Info.SectorNumber })
+	return out, nil
+}
+
+func expandDeadline(store adt.Store, deadlines *miner.Deadlines, dlIdx uint64) (*Deadline, error) {
+	dl, err := deadlines.LoadDeadline(store, dlIdx)
+	if err != nil {
+		return nil, err
+	}
+	out := &Deadline{
+		Index:        dlIdx,
+		Partitions:   []*Partition{},
+		LiveSectors:  dl.LiveSectors,
+		TotalSectors: dl.TotalSectors,
+		FaultyPower:  dl.FaultyPower,
+	}
+	partitions, err := dl.PartitionsArray(store)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load partitions of deadline %d: %w", dlIdx, err)
+	}
+	var partition miner.Partition
+	if err = partitions.ForEach(&partition, func(partIdx int64) error {
+		p, err := expandPartition(store, &partition, uint64(partIdx))
+		if err != nil {
+			return xerrors.Errorf("failed to expand partition %d: %w", partIdx, err)
+		}
+		out.Partitions = append(out.Partitions, p)
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("failed to load partitions of deadline %d: %w", dlIdx, err)
+	}
+	if out.ExpirationsEpochs, err = expandBitfieldQueue(store, dl.ExpirationsEpochs); err != nil {
+		return nil, xerrors.Errorf("failed to load expirations of deadline %d: %w", dlIdx, err)
+	}
+	if out.PostSubmissions, err = expandBits(dl.PostSubmissions); err != nil {
+		return nil, xerrors.Errorf("failed to expand post submissions of deadline %d: %w", dlIdx, err)
+	}
+	if out.EarlyTerminations, err = expandBits(dl.EarlyTerminations); err != nil {
+		return nil, xerrors.Errorf("failed to expand early terminations of deadline %d: %w", dlIdx, err)
+	}
+	return out, nil
+}
+
+func expandPartition(store adt.Store, p *miner.Partition, partIdx uint64) (*Partition, error) {
+	out := &Partition{
+		Index:           partIdx,
+		LivePower:       p.LivePower,
+		FaultyPower:     p.FaultyPower,
+		RecoveringPower: p.RecoveringPower,
+	}
+	var err error
+	for _, field := range []struct {
+		bf  bitfield.BitField
+		out *Bits
+	}{
+		{p.Sectors, &out.Sectors},
+		{p.Faults, &out.Faults},
+		{p.Recoveries, &out.Recoveries},
+		{p.Terminated, &out.Terminated},
+	} {
+		if *field.out, err = expandBits(field.bf); err != nil {
+			return nil, err
+		}
+	}
+
+	expirations, err := adt.AsArray(store, p.ExpirationsEpochs)
+	if err != nil {
+		return nil, err
+	}
+	out.ExpirationsEpochs = []*ExpirationSet{}
+	var es miner.ExpirationSet
+	if err = expirations.ForEach(&es, func(epoch int64) error {
+		set := &ExpirationSet{
+			Epoch:        abi.ChainEpoch(epoch),
+			OnTimePledge: es.OnTimePledge,
+			ActivePower:  es.ActivePower,
+			FaultyPower:  es.FaultyPower,
+		}
+		if set.OnTimeSectors, err = expandBits(es.OnTimeSectors); err != nil {
+			return err
+		}
+		if set.EarlySectors, err = expandBits(es.EarlySectors); err != nil {
+			return err
+		}
+		out.ExpirationsEpochs = append(out.ExpirationsEpochs, set)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if out.EarlyTerminated, err = expandBitfieldQueue(store, p.EarlyTerminated); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,64 @@
+package inspect
+
+import (
+	"sort"
+
+	addr "github.com/filecoin-project/go-address"
+	cid "github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	multisig "github.com/filecoin-project/specs-actors/actors/builtin/multisig"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+)
+
+// An expanded multisig state.
+type MultisigState struct {
+	Signers               []addr.Address
+	NumApprovalsThreshold uint64
+	NextTxnID             multisig.TxnID
+	InitialBalance        abi.TokenAmount
+	StartEpoch            abi.ChainEpoch
+	UnlockDuration        abi.ChainEpoch
+	PendingTxns           []*Transaction
+}
+
+// A pending multisig transaction.
+type Transaction struct {
+	ID multisig.TxnID
+	multisig.Transaction
+}
+
+// Loads and expands a multisig state, including its pending transactions.
+func Multisig(store adt.Store, head cid.Cid) (*MultisigState, error) {
+	var st multisig.State
+	if err := store.Get(store.Context(), head, &st); err != nil {
+		return nil, xerrors.Errorf("failed to load multisig state %s: %w", head, err)
+	}
+	out := &MultisigState{
+		Signers:               st.Signers,
+		NumApprovalsThreshold: st.NumApprovalsThreshold,
+		NextTxnID:             st.NextTxnID,
+		InitialBalance:        st.InitialBalance,
+		StartEpoch:            st.StartEpoch,
+		UnlockDuration:        st.UnlockDuration,
+		PendingTxns:           []*Transaction{},
+	}
+	txns, err := adt.AsMap(store, st.PendingTxns)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load pending transactions: %w", err)
+	}
+	var txn multisig.Transaction
+	if err = txns.ForEach(&txn, func(key string) error {
+		id, err := adt.ParseIntKey(key)
+		if err != nil {
+			return err
+		}
+		out.PendingTxns = append(out.PendingTxns, &Transaction{ID: multisig.TxnID(id), Transaction: txn})
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("failed to load pending transactions: %w", err)
+	}
+	sort.Slice(out.PendingTxns, func(i, j int) bool { return out.PendingTxns[i].ID < out.PendingTxns[j].ID })
+	return out, nil
+}
@@ -0,0 +1,169 @@
+package inspect
+
+import (
+	"sort"
+
+	addr "github.com/filecoin-project/go-address"
+	cid "github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	power "github.com/filecoin-project/specs-actors/actors/builtin/power"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+	smoothing "github.com/filecoin-project/specs-actors/actors/util/smoothing"
+)
+
+// An expanded power state.
+type PowerState struct {
+	TotalRawBytePower         abi.StoragePower
+	TotalBytesCommitted       abi.StoragePower
+	TotalQualityAdjPower      abi.StoragePower
+	TotalQABytesCommitted     abi.StoragePower
+	TotalPledgeCollateral     abi.TokenAmount
+	ThisEpochRawBytePower     abi.StoragePower
+	ThisEpochQualityAdjPower  abi.StoragePower
+	ThisEpochPledgeCollateral abi.TokenAmount
+	ThisEpochQAPowerSmoothed  *smoothing.FilterEstimate
+	MinerCount                int64
+	MinerAboveMinPowerCount   int64
+	CronEventQueue            []EpochCronEvents
+	FirstCronEpoch            abi.ChainEpoch
+	LastProcessedCronEpoch    abi.ChainEpoch
+	Claims                    []Claim
+	ProofValidationBatch      []MinerProofs // Null if there is no batch.
+}
+
+// The cron events enrolled for an epoch.
+type EpochCronEvents struct {
+	Epoch  abi.ChainEpoch
+	Events []power.CronEvent
+}
+
+// A miner's claimed power.
+type Claim struct {
+	Address         addr.Address
+	RawBytePower    abi.StoragePower
+	QualityAdjPower abi.StoragePower
+}
+
+// The seal proofs batched for verification for a miner.
+type MinerProofs struct {
+	Miner  addr.Address
+	Proofs []abi.SealVerifyInfo
+}
+
+// Loads and expands the power state, including every claim.
+func Power(store adt.Store, head cid.Cid) (*PowerState, error) {
+	var st power.State
+	if err := store.Get(store.Context(), head, &st); err != nil {
+		return nil, xerrors.Errorf("failed to load power state %s: %w", head, err)
+	}
+	out := &PowerState{
+		TotalRawBytePower:         st.TotalRawBytePower,
+		TotalBytesCommitted:       st.TotalBytesCommitted,
+		TotalQualityAdjPower:      st.TotalQualityAdjPower,
+		TotalQABytesCommitted:     st.TotalQABytesCommitted,
+		TotalPledgeCollateral:     st.TotalPledgeCollateral,
+		ThisEpochRawBytePower:     st.ThisEpochRawBytePower,
+		ThisEpochQualityAdjPower:  st.ThisEpochQualityAdjPower,
+		ThisEpochPledgeCollateral: st.ThisEpochPledgeCollateral,
+		ThisEpochQAPowerSmoothed:  st.ThisEpochQAPowerSmoothed,
+		MinerCount:                st.MinerCount,
+		MinerAboveMinPowerCount:   st.MinerAboveMinPowerCount,
+		FirstCronEpoch:            st.FirstCronEpoch,
+		LastProcessedCronEpoch:    st.LastProcessedCronEpoch,
+	}
+	var err error
+	if out.CronEventQueue, err = expandCronEvents(store, st.CronEventQueue); err != nil {
+		return nil, xerrors.Errorf("failed to load cron event queue: %w", err)
+	}
+	if out.Claims, err = expandClaims(store, st.Claims); err != nil {
+		return nil, xerrors.Errorf("failed to load claims: %w", err)
+	}
+	if st.ProofValidationBatch != nil {
+		if out.ProofValidationBatch, err = expandProofBatch(store, *st.ProofValidationBatch); err != nil {
+			return nil, xerrors.Errorf("failed to load proof validation batch: %w", err)
+		}
+	}
+	return out, nil
+}
+
+// Expands the cron event multimap, sorted by epoch.
+func expandCronEvents(store adt.Store, root cid.Cid) ([]EpochCronEvents, error) {
+	queue, err := adt.AsMultimap(store, root)
+	if err != nil {
+		return nil, err
+	}
+	out := []EpochCronEvents{}
+	if err = queue.ForAll(func(key string, events *adt.Array) error {
+		epoch, err := adt.ParseIntKey(key)
+		if err != nil {
+			return err
+		}
+		epochEvents := EpochCronEvents{Epoch: abi.ChainEpoch(epoch), Events: []power.CronEvent{}}
+		var event power.CronEvent
+		if err = events.ForEach(&event, func(_ int64) error {
+			epochEvents.Events = append(epochEvents.Events, event)
+			return nil
+		}); err != nil {
+			return err
+		}
+		out = append(out, epochEvents)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Epoch < out[j].Epoch })
+	return out, nil
+}
+
+// Expands the claims, sorted by miner address.
+func expandClaims(store adt.Store, root cid.Cid) ([]Claim, error) {
+	claims, err := adt.AsMap(store, root)
+	if err != nil {
+		return nil, err
+	}
+	out := []Claim{}
+	var claim power.Claim
+	if err = claims.ForEach(&claim, func(key string) error {
+		a, err := addr.NewFromBytes([]byte(key))
+		if err != nil {
+			return err
+		}
+		out = append(out, Claim{Address: a, RawBytePower: claim.RawBytePower, QualityAdjPower: claim.QualityAdjPower})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Address.String() < out[j].Address.String() })
+	return out, nil
+}
+
+// Expands the proof validation batch, sorted by miner address.
+func expandProofBatch(store adt.Store, root cid.Cid) ([]MinerProofs, error) {
+	batch, err := adt.AsMultimap(store, root)
+	if err != nil {
+		return nil, err
+	}
+	out := []MinerProofs{}
+	if err = batch.ForAll(func(key string, infos *adt.Array) error {
+		a, err := addr.NewFromBytes([]byte(key))
+		if err != nil {
+			return err
+		}
+		proofs := MinerProofs{Miner: a, Proofs: []abi.SealVerifyInfo{}}
+		var info abi.SealVerifyInfo
+		if err = infos.ForEach(&info, func(_ int64) error {
+			proofs.Proofs = append(proofs.Proofs, info)
+			return nil
+		}); err != nil {
+			return err
+		}
+		out = append(out, proofs)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Miner.String() < out[j].Miner.String() })
+	return out, nil
+}
@@ -0,0 +1,34 @@
+package inspect
+
+import (
+	addr "github.com/filecoin-project/go-address"
+	cid "github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	verifreg "github.com/filecoin-project/specs-actors/actors/builtin/verifreg"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+)
+
+// An expanded verified registry state. Data caps are listed as balances.
+type VerifiedRegistryState struct {
+	RootKey         addr.Address
+	Verifiers       []Balance
+	VerifiedClients []Balance
+}
+
+// Loads and expands the verified registry state.
+func VerifiedRegistry(store adt.Store, head cid.Cid) (*VerifiedRegistryState, error) {
+	var st verifreg.State
+	if err := store.Get(store.Context(), head, &st); err != nil {
+		return nil, xerrors.Errorf("failed to load verified registry state %s: %w", head, err)
+	}
+	out := &VerifiedRegistryState{RootKey: st.RootKey}
+	var err error
+	if out.Verifiers, err = expandBalances(store, st.Verifiers); err != nil {
+		return nil, xerrors.Errorf("failed to load verifiers: %w", err)
+	}
+	if out.VerifiedClients, err = expandBalances(store, st.VerifiedClients); err != nil {
+		return nil, xerrors.Errorf("failed to load verified clients: %w", err)
+	}
+	return out, nil
+}
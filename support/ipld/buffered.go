@@ -0,0 +1,144 @@
+package ipld
+
+import (
+	"bytes"
+	"context"
+
+	cid "github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	mh "github.com/multiformats/go-multihash"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+)
+
+// BufferedStore is an adt.Store that holds written blocks in memory until flushed to an underlying store.
+// Reads are served from the buffer, then from the underlying store.
+// Flushing persists only the buffered blocks reachable from a root, discarding the intermediate HAMT and AMT
+// nodes and superseded state objects written while the state was being mutated.
+type BufferedStore struct {
+	base   adt.Store
+	buffer map[cid.Cid][]byte
+}
+
+var _ adt.Store = (*BufferedStore)(nil)
+
+func NewBufferedStore(base adt.Store) *BufferedStore {
+	return &BufferedStore{
+		base:   base,
+		buffer: map[cid.Cid][]byte{},
+	}
+}
+
+func (s *BufferedStore) Context() context.Context {
+	return s.base.Context()
+}
+
+func (s *BufferedStore) Get(ctx context.Context, c cid.Cid, out interface{}) error {
+	data, ok := s.buffer[c]
+	if !ok {
+		return s.base.Get(ctx, c, out)
+	}
+	return decodeBlock(data, out)
+}
+
+// Serializes a value into the buffer, returning its CID as the underlying store would compute it.
+func (s *BufferedStore) Put(_ context.Context, v interface{}) (cid.Cid, error) {
+	c, data, err := encodeBlock(v)
+	if err != nil {
+		return cid.Undef, err
+	}
+	s.buffer[c] = data
+	return c, nil
+}
+
+// The number of blocks held in the buffer.
+func (s *BufferedStore) Buffered() int {
+	return len(s.buffer)
+}
+
+// Writes the buffered blocks reachable from a root to the underlying store, then empties the buffer.
+// The traversal does not descend into blocks that are not buffered, since they and their descendants must already
+// be in the underlying store. Returns the number of blocks written.
+func (s *BufferedStore) Flush(root cid.Cid) (int, error) {
+	ctx := s.Context()
+	written := 0
+	seen := cid.NewSet()
+	queue := []cid.Cid{root}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		if !seen.Visit(c) {
+			continue
+		}
+		data, ok := s.buffer[c]
+		if !ok {
+			continue
+		}
+
+		if stored, err := s.base.Put(ctx, &rawBlock{cid: c, data: data}); err != nil {
+			return written, xerrors.Errorf("failed to write block %s: %w", c, err)
+		} else if !stored.Equals(c) {
+			return written, xerrors.Errorf("block %s stored as %s", c, stored)
+		}
+		written++
+
+		if c.Prefix().Codec != cid.DagCBOR {
+			continue
+		}
+		if err := cbg.ScanForLinks(bytes.NewReader(data), func(link cid.Cid) {
+			queue = append(queue, link)
+		}); err != nil {
+			return written, xerrors.Errorf("failed to scan block %s for links: %w", c, err)
+		}
+	}
+	s.buffer = map[cid.Cid][]byte{}
+	return written, nil
+}
+
+// Serializes a value and computes its CID as cbor.IpldStore does: with the prefix of the value's own CID if it
+// provides one, or else as DAG-CBOR hashed with blake2b-256.
+func encodeBlock(v interface{}) (cid.Cid, []byte, error) {
+	prefix := cid.Prefix{
+		Version:  1,
+		Codec:    cid.DagCBOR,
+		MhType:   mh.BLAKE2B_MIN + 31,
+		MhLength: -1,
+	}
+	if p, ok := v.(interface{ Cid() cid.Cid }); ok && p.Cid().Defined() {
+		prefix = p.Cid().Prefix()
+	}
+
+	var data []byte
+	if m, ok := v.(cbg.CBORMarshaler); ok {
+		buf := bytes.Buffer{}
+		if err := m.MarshalCBOR(&buf); err != nil {
+			return cid.Undef, nil, err
+		}
+		data = buf.Bytes()
+	} else {
+		nd, err := cbor.WrapObject(v, prefix.MhType, prefix.MhLength)
+		if err != nil {
+			return cid.Undef, nil, err
+		}
+		data = nd.RawData()
+	}
+
+	c, err := prefix.Sum(data)
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	return c, data, nil
+}
+
+// Deserializes a block's data into a value as cbor.IpldStore does.
+func decodeBlock(data []byte, out interface{}) error {
+	if u, ok := out.(cbg.CBORUnmarshaler); ok {
+		if err := u.UnmarshalCBOR(bytes.NewReader(data)); err != nil {
+			return cbor.NewSerializationError(err)
+		}
+		return nil
+	}
+	return cbor.DecodeInto(data, out)
+}
@@ -0,0 +1,113 @@
+package ipld_test
+
+import (
+	"context"
+	"testing"
+
+	block "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	big "github.com/filecoin-project/specs-actors/actors/abi/big"
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/support/ipld"
+	vm "github.com/filecoin-project/specs-actors/support/vm"
+)
+
+func TestBufferedStore(t *testing.T) {
+	ctx := context.Background()
+
+	// Writes a map, taking its root after every put as actor code does between state transactions.
+	writeMap := func(store adt.Store) cid.Cid {
+		m := adt.MakeEmptyMap(store)
+		var root cid.Cid
+		for i := int64(0); i < 200; i++ {
+			v := cbg.CborInt(i)
+			require.NoError(t, m.Put(adt.IntKey(i), &v))
+			var err error
+			root, err = m.Root()
+			require.NoError(t, err)
+		}
+		return root
+	}
+
+	t.Run("flush writes only reachable blocks", func(t *testing.T) {
+		direct := &countingBlockstore{Blockstore: ipld.NewBlockStoreInMemory()}
+		expected := writeMap(adt.WrapStore(ctx, cbor.NewCborStore(direct)))
+
+		base := &countingBlockstore{Blockstore: ipld.NewBlockStoreInMemory()}
+		buffered := ipld.NewBufferedStore(adt.WrapStore(ctx, cbor.NewCborStore(base)))
+		root := writeMap(buffered)
+		assert.Equal(t, expected, root)
+		assert.Equal(t, 0, base.puts)
+
+		written, err := buffered.Flush(root)
+		require.NoError(t, err)
+		assert.Equal(t, written, base.puts)
+		assert.True(t, base.puts < direct.puts)
+		assert.Equal(t, 0, buffered.Buffered())
+
+		m, err := adt.AsMap(adt.WrapStore(ctx, cbor.NewCborStore(base)), root)
+		require.NoError(t, err)
+		keys, err := m.CollectKeys()
+		require.NoError(t, err)
+		assert.Len(t, keys, 200)
+	})
+
+	t.Run("reads are served from buffer and underlying store", func(t *testing.T) {
+		base := ipld.NewADTStore(ctx)
+		one, two := cbg.CborInt(1), cbg.CborInt(2)
+		persisted, err := base.Put(ctx, &one)
+		require.NoError(t, err)
+
+		buffered := ipld.NewBufferedStore(base)
+		pending, err := buffered.Put(ctx, &two)
+		require.NoError(t, err)
+
+		var v cbg.CborInt
+		require.NoError(t, buffered.Get(ctx, persisted, &v))
+		assert.Equal(t, one, v)
+		require.NoError(t, buffered.Get(ctx, pending, &v))
+		assert.Equal(t, two, v)
+		assert.Error(t, base.Get(ctx, pending, &v))
+
+		// flushing another root discards the unreachable block
+		_, err = buffered.Flush(persisted)
+		require.NoError(t, err)
+		assert.Error(t, buffered.Get(ctx, pending, &v))
+	})
+
+	t.Run("VM state flushed through buffer", func(t *testing.T) {
+		v := vm.NewVMWithSingletons(ctx, t)
+		addrs := vm.CreateAccounts(ctx, t, v, 2, big.Mul(big.NewInt(100), vm.FIL), 93837778)
+
+		buffered := ipld.NewBufferedStore(v.Store())
+		bv, err := vm.NewVMAtEpoch(ctx, vm.BuiltinActorImpls(), buffered, v.StateRoot(), v.GetEpoch())
+		require.NoError(t, err)
+		vm.ApplyOk(t, bv, addrs[0], addrs[1], vm.FIL, builtin.MethodSend, nil)
+		root := bv.StateRoot()
+		_, err = buffered.Flush(root)
+		require.NoError(t, err)
+
+		after, err := vm.NewVMAtEpoch(ctx, vm.BuiltinActorImpls(), v.Store(), root, v.GetEpoch())
+		require.NoError(t, err)
+		act, found, err := after.GetActor(addrs[1])
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, big.Mul(big.NewInt(101), vm.FIL), act.Balance)
+	})
+}
+
+type countingBlockstore struct {
+	ipld.Blockstore
+	puts int
+}
+
+func (bs *countingBlockstore) Put(b block.Block) error {
+	bs.puts++
+	return bs.Blockstore.Put(b)
+}
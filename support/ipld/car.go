@@ -0,0 +1,236 @@
+package ipld
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+
+	block "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+)
+
+// The interface to a store of raw blocks, satisfied by BlockStoreInMemory and wrapped by cbor.NewCborStore.
+type Blockstore interface {
+	Get(c cid.Cid) (block.Block, error)
+	Put(b block.Block) error
+}
+
+var _ Blockstore = (*BlockStoreInMemory)(nil)
+
+// Writes a CARv1 containing the blocks reachable from the roots to w.
+// Links are found by scanning each DAG-CBOR block for CIDs, so this follows HAMT and AMT nodes as well as CIDs
+// nested anywhere in actor state. Blocks of other codecs are written but not scanned, and identity-hashed CIDs
+// (such as actor code CIDs) carry their data inline so have no block to write.
+func ExportCAR(ctx context.Context, store adt.Store, w io.Writer, roots ...cid.Cid) error {
+	if err := writeSection(w, carHeader(roots)); err != nil {
+		return xerrors.Errorf("failed to write header: %w", err)
+	}
+
+	seen := cid.NewSet()
+	queue := append([]cid.Cid{}, roots...)
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		if c.Prefix().MhType == mh.IDENTITY || !seen.Visit(c) {
+			continue
+		}
+
+		blk := rawBlock{}
+		if err := store.Get(ctx, c, &blk); err != nil {
+			return xerrors.Errorf("failed to load block %s: %w", c, err)
+		}
+		if err := writeSection(w, append(c.Bytes(), blk.data...)); err != nil {
+			return xerrors.Errorf("failed to write block %s: %w", c, err)
+		}
+
+		if c.Prefix().Codec != cid.DagCBOR {
+			continue
+		}
+		if err := cbg.ScanForLinks(bytes.NewReader(blk.data), func(link cid.Cid) {
+			queue = append(queue, link)
+		}); err != nil {
+			return xerrors.Errorf("failed to scan block %s for links: %w", c, err)
+		}
+	}
+	return nil
+}
+
+// Reads a CARv1 from r into a blockstore, returning the roots named in its header.
+// Each block's data is checked against its CID.
+func ImportCAR(bs Blockstore, r io.Reader) ([]cid.Cid, error) {
+	br := bufio.NewReader(r)
+	header, err := readSection(br)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read header: %w", err)
+	}
+	roots, err := parseCARHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		section, err := readSection(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("failed to read block: %w", err)
+		}
+		n, c, err := cid.CidFromBytes(section)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to read block cid: %w", err)
+		}
+		data := section[n:]
+		if sum, err := c.Prefix().Sum(data); err != nil {
+			return nil, err
+		} else if !sum.Equals(c) {
+			return nil, xerrors.Errorf("block data for %s hashes to %s", c, sum)
+		}
+		blk, err := block.NewBlockWithCid(data, c)
+		if err != nil {
+			return nil, err
+		}
+		if err := bs.Put(blk); err != nil {
+			return nil, err
+		}
+	}
+	return roots, nil
+}
+
+// A block's raw data, which is loaded from and stored to an adt.Store without interpretation.
+type rawBlock struct {
+	cid  cid.Cid // Determines the CID prefix when stored.
+	data []byte
+}
+
+// Implements the optional interface with which cbor.IpldStore determines the prefix of a stored block's CID.
+func (b *rawBlock) Cid() cid.Cid {
+	return b.cid
+}
+
+func (b *rawBlock) MarshalCBOR(w io.Writer) error {
+	_, err := w.Write(b.data)
+	return err
+}
+
+func (b *rawBlock) UnmarshalCBOR(r io.Reader) error {
+	var err error
+	b.data, err = ioutil.ReadAll(r)
+	return err
+}
+
+// Encodes the header {"roots": [...], "version": 1}, with keys in canonical order.
+func carHeader(roots []cid.Cid) []byte {
+	buf := bytes.Buffer{}
+	// Writes to a buffer cannot fail.
+	_ = cbg.WriteMajorTypeHeader(&buf, cbg.MajMap, 2)
+	writeString(&buf, "roots")
+	_ = cbg.WriteMajorTypeHeader(&buf, cbg.MajArray, uint64(len(roots)))
+	for _, root := range roots {
+		_ = cbg.WriteCid(&buf, root)
+	}
+	writeString(&buf, "version")
+	_ = cbg.WriteMajorTypeHeader(&buf, cbg.MajUnsignedInt, 1)
+	return buf.Bytes()
+}
+
+func parseCARHeader(header []byte) ([]cid.Cid, error) {
+	r := bytes.NewReader(header)
+	maj, n, err := cbg.CborReadHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if maj != cbg.MajMap {
+		return nil, xerrors.Errorf("header is not a map")
+	}
+
+	var roots []cid.Cid
+	var version uint64
+	for i := uint64(0); i < n; i++ {
+		key, err := cbg.ReadString(r)
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "roots":
+			maj, count, err := cbg.CborReadHeader(r)
+			if err != nil {
+				return nil, err
+			}
+			if maj != cbg.MajArray {
+				return nil, xerrors.Errorf("header roots is not an array")
+			}
+			for j := uint64(0); j < count; j++ {
+				c, err := cbg.ReadCid(r)
+				if err != nil {
+					return nil, err
+				}
+				roots = append(roots, c)
+			}
+		case "version":
+			maj, version, err = cbg.CborReadHeader(r)
+			if err != nil {
+				return nil, err
+			}
+			if maj != cbg.MajUnsignedInt {
+				return nil, xerrors.Errorf("header version is not an integer")
+			}
+		default:
+			return nil, xerrors.Errorf("unexpected header field %s", key)
+		}
+	}
+
+	if version != 1 {
+		return nil, xerrors.Errorf("unsupported car version %d", version)
+	}
+	return roots, nil
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	_ = cbg.WriteMajorTypeHeader(buf, cbg.MajTextString, uint64(len(s)))
+	buf.WriteString(s)
+}
+
+// The maximum length of a section (a CID and its block), far larger than any block written by the actors.
+// A larger length is taken to be corrupt, rather than trusted with an allocation of that size.
+const maxSectionSize = 32 << 20
+
+// Writes a section prefixed by its length as a varint.
+func writeSection(w io.Writer, data []byte) error {
+	if len(data) > maxSectionSize {
+		return xerrors.Errorf("section length %d exceeds maximum %d", len(data), maxSectionSize)
+	}
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, uint64(len(data)))
+	if _, err := w.Write(buf[:n]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// Reads a length-prefixed section, returning io.EOF if there are no more sections.
+func readSection(r *bufio.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if length > maxSectionSize {
+		return nil, xerrors.Errorf("section length %d exceeds maximum %d", length, maxSectionSize)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,143 @@
+package ipld_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	big "github.com/filecoin-project/specs-actors/actors/abi/big"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/support/ipld"
+	vm "github.com/filecoin-project/specs-actors/support/vm"
+)
+
+func TestCAR(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("round trip follows nested links", func(t *testing.T) {
+		store := ipld.NewADTStore(ctx)
+
+		// A map whose values are the roots of arrays.
+		m := adt.MakeEmptyMap(store)
+		for i := int64(0); i < 20; i++ {
+			arr := adt.MakeEmptyArray(store)
+			for j := int64(0); j < 20; j++ {
+				v := cbg.CborInt(i*100 + j)
+				require.NoError(t, arr.Set(uint64(j), &v))
+			}
+			arrRoot, err := arr.Root()
+			require.NoError(t, err)
+			c := cbg.CborCid(arrRoot)
+			require.NoError(t, m.Put(adt.IntKey(i), &c))
+		}
+		root, err := m.Root()
+		require.NoError(t, err)
+
+		buf := bytes.Buffer{}
+		require.NoError(t, ipld.ExportCAR(ctx, store, &buf, root))
+
+		bs := ipld.NewBlockStoreInMemory()
+		roots, err := ipld.ImportCAR(bs, &buf)
+		require.NoError(t, err)
+		require.Equal(t, []cid.Cid{root}, roots)
+
+		imported := adt.WrapStore(ctx, cbor.NewCborStore(bs))
+		m2, err := adt.AsMap(imported, root)
+		require.NoError(t, err)
+		var c cbg.CborCid
+		found, err := m2.Get(adt.IntKey(7), &c)
+		require.NoError(t, err)
+		require.True(t, found)
+		arr, err := adt.AsArray(imported, cid.Cid(c))
+		require.NoError(t, err)
+		var v cbg.CborInt
+		found, err = arr.Get(13, &v)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, cbg.CborInt(713), v)
+	})
+
+	t.Run("round trip of VM state", func(t *testing.T) {
+		v := vm.NewVMWithSingletons(ctx, t)
+		vm.CreateAccounts(ctx, t, v, 3, big.NewInt(1e18), 93837778)
+		root := v.StateRoot()
+
+		buf := bytes.Buffer{}
+		require.NoError(t, ipld.ExportCAR(ctx, v.Store(), &buf, root))
+
+		bs := ipld.NewBlockStoreInMemory()
+		roots, err := ipld.ImportCAR(bs, &buf)
+		require.NoError(t, err)
+		require.Equal(t, []cid.Cid{root}, roots)
+
+		imported, err := vm.NewVMAtEpoch(ctx, vm.BuiltinActorImpls(), adt.WrapStore(ctx, cbor.NewCborStore(bs)), root, v.GetEpoch())
+		require.NoError(t, err)
+		expected, err := v.GetTotalActorBalance()
+		require.NoError(t, err)
+		actual, err := imported.GetTotalActorBalance()
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("unreachable blocks are not exported", func(t *testing.T) {
+		store := ipld.NewADTStore(ctx)
+		one, two := cbg.CborInt(1), cbg.CborInt(2)
+		reachable, err := store.Put(ctx, &one)
+		require.NoError(t, err)
+		unreachable, err := store.Put(ctx, &two)
+		require.NoError(t, err)
+
+		buf := bytes.Buffer{}
+		require.NoError(t, ipld.ExportCAR(ctx, store, &buf, reachable))
+
+		bs := ipld.NewBlockStoreInMemory()
+		_, err = ipld.ImportCAR(bs, &buf)
+		require.NoError(t, err)
+		_, err = bs.Get(reachable)
+		assert.NoError(t, err)
+		_, err = bs.Get(unreachable)
+		assert.Error(t, err)
+	})
+
+	t.Run("corrupt block is rejected", func(t *testing.T) {
+		store := ipld.NewADTStore(ctx)
+		value := cbg.CborInt(1)
+		root, err := store.Put(ctx, &value)
+		require.NoError(t, err)
+
+		buf := bytes.Buffer{}
+		require.NoError(t, ipld.ExportCAR(ctx, store, &buf, root))
+		data := buf.Bytes()
+		data[len(data)-1]++
+
+		_, err = ipld.ImportCAR(ipld.NewBlockStoreInMemory(), bytes.NewReader(data))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "hashes to")
+	})
+
+	t.Run("oversized section is rejected", func(t *testing.T) {
+		data := make([]byte, binary.MaxVarintLen64)
+		data = data[:binary.PutUvarint(data, 1<<40)]
+
+		_, err := ipld.ImportCAR(ipld.NewBlockStoreInMemory(), bytes.NewReader(data))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "exceeds maximum")
+	})
+
+	t.Run("missing block fails export", func(t *testing.T) {
+		store := ipld.NewADTStore(ctx)
+		value := cbg.CborInt(1)
+		root, err := ipld.NewADTStore(ctx).Put(ctx, &value)
+		require.NoError(t, err)
+
+		err = ipld.ExportCAR(ctx, store, &bytes.Buffer{}, root)
+		require.Error(t, err)
+	})
+}
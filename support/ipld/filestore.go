@@ -0,0 +1,246 @@
+package ipld
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	block "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"golang.org/x/xerrors"
+
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+)
+
+const (
+	fileStoreLog  = "blocks.log"
+	fileStoreRoot = "checkpoint"
+)
+
+// FileBlockstore is a blockstore persisted to a directory, so that it may exceed available memory and survive
+// process restarts.
+// Blocks are appended to a log, in the same section format as a CAR file body, and never removed. The index from
+// CID to log offset is held in memory and rebuilt by scanning the log when the store is opened.
+// A partially-written block at the end of the log, as left by a crash, is discarded when the store is opened.
+type FileBlockstore struct {
+	mu      sync.Mutex
+	dir     string
+	file    *os.File
+	writer  *bufio.Writer
+	size    int64 // Length of the log, including buffered writes.
+	flushed int64 // Length of the log written through to the file.
+	index   map[cid.Cid]logEntry
+	scratch []byte
+}
+
+// The location of a block's data in the log.
+type logEntry struct {
+	offset int64
+	length int
+}
+
+var _ Blockstore = (*FileBlockstore)(nil)
+
+// Opens the blockstore in a directory, creating the directory if necessary.
+func OpenFileBlockstore(dir string) (*FileBlockstore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, fileStoreLog), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	bs := &FileBlockstore{
+		dir:     dir,
+		file:    file,
+		index:   map[cid.Cid]logEntry{},
+		scratch: make([]byte, binary.MaxVarintLen64),
+	}
+	if err := bs.loadIndex(); err != nil {
+		_ = file.Close()
+		return nil, xerrors.Errorf("failed to index %s: %w", file.Name(), err)
+	}
+	bs.writer = bufio.NewWriter(file)
+	return bs, nil
+}
+
+func (bs *FileBlockstore) Get(c cid.Cid) (block.Block, error) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	entry, ok := bs.index[c]
+	if !ok {
+		return nil, xerrors.Errorf("block %s not found", c)
+	}
+	if entry.offset+int64(entry.length) > bs.flushed {
+		if err := bs.flush(); err != nil {
+			return nil, err
+		}
+	}
+	data := make([]byte, entry.length)
+	if _, err := bs.file.ReadAt(data, entry.offset); err != nil {
+		return nil, xerrors.Errorf("failed to read block %s: %w", c, err)
+	}
+	return block.NewBlockWithCid(data, c)
+}
+
+// Appends a block to the log, unless the store already holds it.
+func (bs *FileBlockstore) Put(b block.Block) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	c := b.Cid()
+	if _, ok := bs.index[c]; ok {
+		return nil
+	}
+
+	key, data := c.Bytes(), b.RawData()
+	if len(key)+len(data) > maxSectionSize {
+		return xerrors.Errorf("block %s of %d bytes exceeds maximum section length %d", c, len(data), maxSectionSize)
+	}
+	n := binary.PutUvarint(bs.scratch, uint64(len(key)+len(data)))
+	for _, part := range [][]byte{bs.scratch[:n], key, data} {
+		if _, err := bs.writer.Write(part); err != nil {
+			return err
+		}
+	}
+	bs.index[c] = logEntry{offset: bs.size + int64(n+len(key)), length: len(data)}
+	bs.size += int64(n + len(key) + len(data))
+	return nil
+}
+
+// Whether the store holds a block.
+func (bs *FileBlockstore) Has(c cid.Cid) bool {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	_, ok := bs.index[c]
+	return ok
+}
+
+// The number of blocks held.
+func (bs *FileBlockstore) Len() int {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	return len(bs.index)
+}
+
+// Writes all blocks to stable storage.
+func (bs *FileBlockstore) Sync() error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	return bs.sync()
+}
+
+// Writes all blocks to stable storage, then records a state root from which to resume after a restart.
+func (bs *FileBlockstore) Checkpoint(root cid.Cid) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if _, ok := bs.index[root]; !ok {
+		return xerrors.Errorf("checkpoint root %s not found", root)
+	}
+	if err := bs.sync(); err != nil {
+		return err
+	}
+	// Write and rename, so that a crash leaves either the old or new checkpoint.
+	path := filepath.Join(bs.dir, fileStoreRoot)
+	if err := ioutil.WriteFile(path+".tmp", root.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	// Sync the directory, so that the rename itself survives a crash.
+	d, err := os.Open(bs.dir)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		_ = d.Close()
+		return err
+	}
+	return d.Close()
+}
+
+// Returns the root recorded by the most recent checkpoint, or cid.Undef if there is none.
+func (bs *FileBlockstore) LastCheckpoint() (cid.Cid, error) {
+	data, err := ioutil.ReadFile(filepath.Join(bs.dir, fileStoreRoot))
+	if os.IsNotExist(err) {
+		return cid.Undef, nil
+	} else if err != nil {
+		return cid.Undef, err
+	}
+	return cid.Cast(data)
+}
+
+// Writes all blocks to stable storage and closes the log.
+func (bs *FileBlockstore) Close() error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if err := bs.sync(); err != nil {
+		_ = bs.file.Close()
+		return err
+	}
+	return bs.file.Close()
+}
+
+func (bs *FileBlockstore) sync() error {
+	if err := bs.flush(); err != nil {
+		return err
+	}
+	return bs.file.Sync()
+}
+
+// Writes buffered blocks through to the file, without syncing it.
+func (bs *FileBlockstore) flush() error {
+	if err := bs.writer.Flush(); err != nil {
+		return err
+	}
+	bs.flushed = bs.size
+	return nil
+}
+
+// Scans the log to rebuild the index, truncating any incomplete block at its end.
+func (bs *FileBlockstore) loadIndex() error {
+	r := bufio.NewReader(bs.file)
+	var offset int64
+	for {
+		section, err := readSection(r)
+		if err == io.EOF {
+			break
+		} else if err == io.ErrUnexpectedEOF {
+			// The log ends part way through a block.
+			if err := bs.file.Truncate(offset); err != nil {
+				return err
+			}
+			break
+		} else if err != nil {
+			return err
+		}
+
+		n, c, err := cid.CidFromBytes(section)
+		if err != nil {
+			return xerrors.Errorf("invalid cid at offset %d: %w", offset, err)
+		}
+		header := binary.PutUvarint(bs.scratch, uint64(len(section)))
+		bs.index[c] = logEntry{offset: offset + int64(header+n), length: len(section) - n}
+		offset += int64(header + len(section))
+	}
+	bs.size = offset
+	bs.flushed = offset
+	_, err := bs.file.Seek(offset, io.SeekStart)
+	return err
+}
+
+// Opens an IPLD store backed by a FileBlockstore in a directory.
+// The blockstore is returned too, so that it may be checkpointed and closed.
+func NewADTFileStore(ctx context.Context, dir string) (adt.Store, *FileBlockstore, error) {
+	bs, err := OpenFileBlockstore(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return adt.WrapStore(ctx, cbor.NewCborStore(bs)), bs, nil
+}
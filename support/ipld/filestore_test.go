@@ -0,0 +1,174 @@
+package ipld_test
+
+import (
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/support/ipld"
+)
+
+func TestFileBlockstore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("state survives reopening", func(t *testing.T) {
+		dir := tempDir(t)
+		defer func() { _ = os.RemoveAll(dir) }()
+		store, bs, err := ipld.NewADTFileStore(ctx, dir)
+		require.NoError(t, err)
+
+		m := adt.MakeEmptyMap(store)
+		for i := int64(0); i < 100; i++ {
+			v := cbg.CborInt(i * i)
+			require.NoError(t, m.Put(adt.IntKey(i), &v))
+		}
+		root, err := m.Root()
+		require.NoError(t, err)
+
+		// reads are served before the log is synced
+		var v cbg.CborInt
+		found, err := m.Get(adt.IntKey(9), &v)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, cbg.CborInt(81), v)
+
+		require.NoError(t, bs.Checkpoint(root))
+		blocks := bs.Len()
+		require.NoError(t, bs.Close())
+
+		store, bs, err = ipld.NewADTFileStore(ctx, dir)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, bs.Close()) }()
+		assert.Equal(t, blocks, bs.Len())
+
+		checkpoint, err := bs.LastCheckpoint()
+		require.NoError(t, err)
+		assert.Equal(t, root, checkpoint)
+
+		m, err = adt.AsMap(store, checkpoint)
+		require.NoError(t, err)
+		found, err = m.Get(adt.IntKey(42), &v)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, cbg.CborInt(42*42), v)
+	})
+
+	t.Run("duplicate puts are not appended", func(t *testing.T) {
+		dir := tempDir(t)
+		defer func() { _ = os.RemoveAll(dir) }()
+		store, bs, err := ipld.NewADTFileStore(ctx, dir)
+		require.NoError(t, err)
+		v := cbg.CborInt(1)
+		_, err = store.Put(ctx, &v)
+		require.NoError(t, err)
+		require.NoError(t, bs.Sync())
+		size := logSize(t, dir)
+
+		_, err = store.Put(ctx, &v)
+		require.NoError(t, err)
+		require.NoError(t, bs.Close())
+		assert.Equal(t, size, logSize(t, dir))
+	})
+
+	t.Run("reads flush the log only for unflushed blocks", func(t *testing.T) {
+		dir := tempDir(t)
+		defer func() { _ = os.RemoveAll(dir) }()
+		store, bs, err := ipld.NewADTFileStore(ctx, dir)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, bs.Close()) }()
+		v1, v2 := cbg.CborInt(1), cbg.CborInt(2)
+		c1, err := store.Put(ctx, &v1)
+		require.NoError(t, err)
+		require.NoError(t, bs.Sync())
+		size := logSize(t, dir)
+
+		c2, err := store.Put(ctx, &v2)
+		require.NoError(t, err)
+		var out cbg.CborInt
+		require.NoError(t, store.Get(ctx, c1, &out))
+		assert.Equal(t, v1, out)
+		assert.Equal(t, size, logSize(t, dir))
+
+		require.NoError(t, store.Get(ctx, c2, &out))
+		assert.Equal(t, v2, out)
+		assert.Greater(t, logSize(t, dir), size)
+	})
+
+	t.Run("incomplete block at end of log is discarded", func(t *testing.T) {
+		dir := tempDir(t)
+		defer func() { _ = os.RemoveAll(dir) }()
+		store, bs, err := ipld.NewADTFileStore(ctx, dir)
+		require.NoError(t, err)
+		one, two := cbg.CborInt(1), cbg.CborInt(2)
+		first, err := store.Put(ctx, &one)
+		require.NoError(t, err)
+		second, err := store.Put(ctx, &two)
+		require.NoError(t, err)
+		require.NoError(t, bs.Close())
+
+		// simulate a crash part way through writing the second block
+		path := filepath.Join(dir, "blocks.log")
+		require.NoError(t, os.Truncate(path, logSize(t, dir)-1))
+
+		store, bs, err = ipld.NewADTFileStore(ctx, dir)
+		require.NoError(t, err)
+		assert.True(t, bs.Has(first))
+		assert.False(t, bs.Has(second))
+
+		// the store remains writable after recovery
+		second, err = store.Put(ctx, &two)
+		require.NoError(t, err)
+		require.NoError(t, bs.Close())
+
+		bs, err = ipld.OpenFileBlockstore(dir)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, bs.Close()) }()
+		assert.Equal(t, 2, bs.Len())
+		blk, err := bs.Get(second)
+		require.NoError(t, err)
+		assert.Equal(t, second, blk.Cid())
+	})
+
+	t.Run("oversized section length fails to open", func(t *testing.T) {
+		dir := tempDir(t)
+		defer func() { _ = os.RemoveAll(dir) }()
+		length := make([]byte, binary.MaxVarintLen64)
+		length = length[:binary.PutUvarint(length, 1<<40)]
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "blocks.log"), length, 0644))
+
+		_, err := ipld.OpenFileBlockstore(dir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "exceeds maximum")
+	})
+
+	t.Run("no checkpoint", func(t *testing.T) {
+		dir := tempDir(t)
+		defer func() { _ = os.RemoveAll(dir) }()
+		bs, err := ipld.OpenFileBlockstore(dir)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, bs.Close()) }()
+		checkpoint, err := bs.LastCheckpoint()
+		require.NoError(t, err)
+		assert.False(t, checkpoint.Defined())
+	})
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "filestore")
+	require.NoError(t, err)
+	return dir
+}
+
+func logSize(t *testing.T, dir string) int64 {
+	info, err := os.Stat(filepath.Join(dir, "blocks.log"))
+	require.NoError(t, err)
+	return info.Size()
+}
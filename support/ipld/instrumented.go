@@ -0,0 +1,200 @@
+package ipld
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	cid "github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+)
+
+// Counts of the block accesses made through an InstrumentedStore.
+type IOStats struct {
+	Gets         int64
+	Puts         int64
+	BytesRead    int64
+	BytesWritten int64
+	// The number of distinct blocks read or written, if tracked (see TrackDistinctCIDs).
+	DistinctCIDs int64
+}
+
+// The accesses attributed to an actor method.
+// Accesses made outside any method, such as when flushing the state tree, have an undefined code.
+type MethodStats struct {
+	Code   cid.Cid
+	Method abi.MethodNum
+	IOStats
+}
+
+// The name of the actor and method number, e.g. "fil/1/storageminer.5".
+func (s *MethodStats) Name() string {
+	if !s.Code.Defined() {
+		return "(outside methods)"
+	}
+	return fmt.Sprintf("%s.%d", builtin.ActorNameByCode(s.Code), s.Method)
+}
+
+// InstrumentedStore is an adt.Store that counts the block reads and writes made through it.
+// Accesses are attributed to the actor method most recently entered with EnterMethod and not yet exited, so a
+// method's statistics exclude those of the methods it sends to. The VM enters each method it invokes.
+type InstrumentedStore struct {
+	base      adt.Store
+	stack     []methodKey
+	totals    map[methodKey]*methodCounts
+	trackCIDs bool
+}
+
+type methodKey struct {
+	code   cid.Cid
+	method abi.MethodNum
+}
+
+type methodCounts struct {
+	IOStats
+	touched map[cid.Cid]struct{}
+}
+
+var _ adt.Store = (*InstrumentedStore)(nil)
+
+func NewInstrumentedStore(base adt.Store) *InstrumentedStore {
+	return &InstrumentedStore{
+		base:   base,
+		totals: map[methodKey]*methodCounts{},
+	}
+}
+
+func (s *InstrumentedStore) Context() context.Context {
+	return s.base.Context()
+}
+
+func (s *InstrumentedStore) Get(ctx context.Context, c cid.Cid, out interface{}) error {
+	blk := rawBlock{}
+	if err := s.base.Get(ctx, c, &blk); err != nil {
+		return err
+	}
+	counts := s.current()
+	counts.Gets++
+	counts.BytesRead += int64(len(blk.data))
+	if s.trackCIDs {
+		counts.touch(c)
+	}
+	return decodeBlock(blk.data, out)
+}
+
+func (s *InstrumentedStore) Put(ctx context.Context, v interface{}) (cid.Cid, error) {
+	c, data, err := encodeBlock(v)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if stored, err := s.base.Put(ctx, &rawBlock{cid: c, data: data}); err != nil {
+		return cid.Undef, err
+	} else if !stored.Equals(c) {
+		return cid.Undef, xerrors.Errorf("block %s stored as %s", c, stored)
+	}
+	counts := s.current()
+	counts.Puts++
+	counts.BytesWritten += int64(len(data))
+	if s.trackCIDs {
+		counts.touch(c)
+	}
+	return c, nil
+}
+
+// Enables or disables counting of the distinct blocks accessed by each method, which is disabled by default.
+// Tracking holds every CID accessed in memory until Reset, so is unsuitable for very large workloads.
+func (s *InstrumentedStore) TrackDistinctCIDs(enabled bool) {
+	s.trackCIDs = enabled
+}
+
+// Attributes subsequent accesses to a method of the actor with the given code, until the returned function is
+// called. Calls may be nested, as when one actor sends to another.
+func (s *InstrumentedStore) EnterMethod(code cid.Cid, method abi.MethodNum) (exit func()) {
+	s.stack = append(s.stack, methodKey{code, method})
+	depth := len(s.stack)
+	return func() {
+		s.stack = s.stack[:depth-1]
+	}
+}
+
+// The accesses attributed to each method, ordered by actor name and method number.
+func (s *InstrumentedStore) Stats() []MethodStats {
+	var stats []MethodStats
+	for key, counts := range s.totals { //nolint:nomaprange
+		stats = append(stats, MethodStats{Code: key.code, Method: key.method, IOStats: counts.IOStats})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		ni, nj := builtin.ActorNameByCode(stats[i].Code), builtin.ActorNameByCode(stats[j].Code)
+		if ni != nj {
+			return ni < nj
+		}
+		if !stats[i].Code.Equals(stats[j].Code) {
+			return stats[i].Code.KeyString() < stats[j].Code.KeyString()
+		}
+		return stats[i].Method < stats[j].Method
+	})
+	return stats
+}
+
+// The sum of the accesses attributed to all methods.
+// Distinct CIDs are counted per method, so a block touched by several methods is counted by each.
+func (s *InstrumentedStore) Total() IOStats {
+	total := IOStats{}
+	for _, counts := range s.totals { //nolint:nomaprange
+		total.Gets += counts.Gets
+		total.Puts += counts.Puts
+		total.BytesRead += counts.BytesRead
+		total.BytesWritten += counts.BytesWritten
+		total.DistinctCIDs += counts.DistinctCIDs
+	}
+	return total
+}
+
+// Discards all counts.
+func (s *InstrumentedStore) Reset() {
+	s.totals = map[methodKey]*methodCounts{}
+}
+
+// Writes a table of the accesses attributed to each method.
+func (s *InstrumentedStore) WriteReport(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	if _, err := fmt.Fprintln(tw, "method\tgets\tputs\tbytes read\tbytes written\tdistinct cids\t"); err != nil {
+		return err
+	}
+	for _, st := range s.Stats() {
+		if _, err := fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t\n", st.Name(), st.Gets, st.Puts,
+			st.BytesRead, st.BytesWritten, st.DistinctCIDs); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+func (s *InstrumentedStore) current() *methodCounts {
+	key := methodKey{}
+	if len(s.stack) > 0 {
+		key = s.stack[len(s.stack)-1]
+	}
+	counts, ok := s.totals[key]
+	if !ok {
+		counts = &methodCounts{}
+		s.totals[key] = counts
+	}
+	return counts
+}
+
+func (c *methodCounts) touch(k cid.Cid) {
+	if c.touched == nil {
+		c.touched = map[cid.Cid]struct{}{}
+	}
+	if _, ok := c.touched[k]; !ok {
+		c.touched[k] = struct{}{}
+		c.DistinctCIDs++
+	}
+}
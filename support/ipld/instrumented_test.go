@@ -0,0 +1,97 @@
+package ipld_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	big "github.com/filecoin-project/specs-actors/actors/abi/big"
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	power "github.com/filecoin-project/specs-actors/actors/builtin/power"
+	"github.com/filecoin-project/specs-actors/support/ipld"
+	vm "github.com/filecoin-project/specs-actors/support/vm"
+)
+
+func TestInstrumentedStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("accesses attributed to innermost method", func(t *testing.T) {
+		store := ipld.NewInstrumentedStore(ipld.NewADTStore(ctx))
+		store.TrackDistinctCIDs(true)
+		one, two := cbg.CborInt(1), cbg.CborInt(2)
+
+		exitOuter := store.EnterMethod(builtin.StoragePowerActorCodeID, 2)
+		c1, err := store.Put(ctx, &one)
+		require.NoError(t, err)
+
+		exitInner := store.EnterMethod(builtin.InitActorCodeID, 2)
+		_, err = store.Put(ctx, &two)
+		require.NoError(t, err)
+		var v cbg.CborInt
+		require.NoError(t, store.Get(ctx, c1, &v))
+		require.NoError(t, store.Get(ctx, c1, &v))
+		assert.Equal(t, one, v)
+		exitInner()
+
+		require.NoError(t, store.Get(ctx, c1, &v))
+		exitOuter()
+
+		stats := store.Stats()
+		require.Len(t, stats, 2)
+		assert.Equal(t, "fil/1/init.2", stats[0].Name())
+		assert.Equal(t, ipld.IOStats{Gets: 2, Puts: 1, BytesRead: 2, BytesWritten: 1, DistinctCIDs: 2}, stats[0].IOStats)
+		assert.Equal(t, "fil/1/storagepower.2", stats[1].Name())
+		assert.Equal(t, ipld.IOStats{Gets: 1, Puts: 1, BytesRead: 1, BytesWritten: 1, DistinctCIDs: 1}, stats[1].IOStats)
+		assert.Equal(t, ipld.IOStats{Gets: 3, Puts: 2, BytesRead: 3, BytesWritten: 2, DistinctCIDs: 3}, store.Total())
+
+		store.Reset()
+		assert.Empty(t, store.Stats())
+	})
+
+	t.Run("distinct CIDs not tracked by default", func(t *testing.T) {
+		store := ipld.NewInstrumentedStore(ipld.NewADTStore(ctx))
+		one := cbg.CborInt(1)
+		c, err := store.Put(ctx, &one)
+		require.NoError(t, err)
+		var v cbg.CborInt
+		require.NoError(t, store.Get(ctx, c, &v))
+		assert.Equal(t, ipld.IOStats{Gets: 1, Puts: 1, BytesRead: 1, BytesWritten: 1}, store.Total())
+	})
+
+	t.Run("VM attributes accesses to invoked methods", func(t *testing.T) {
+		v := vm.NewVMWithSingletons(ctx, t)
+		addrs := vm.CreateAccounts(ctx, t, v, 1, big.Mul(big.NewInt(10_000), vm.FIL), 93837778)
+
+		store := ipld.NewInstrumentedStore(v.Store())
+		iv, err := vm.NewVMAtEpoch(ctx, vm.BuiltinActorImpls(), store, v.StateRoot(), v.GetEpoch())
+		require.NoError(t, err)
+
+		params := power.CreateMinerParams{
+			Owner:         addrs[0],
+			Worker:        addrs[0],
+			SealProofType: abi.RegisteredSealProof_StackedDrg32GiBV1,
+			Peer:          abi.PeerID("not really a peer id"),
+		}
+		vm.ApplyOk(t, iv, addrs[0], builtin.StoragePowerActorAddr, big.Zero(), builtin.MethodsPower.CreateMiner, &params)
+		iv.StateRoot()
+
+		byName := map[string]ipld.IOStats{}
+		for _, st := range store.Stats() {
+			byName[st.Name()] = st.IOStats
+		}
+		for _, name := range []string{"(outside methods)", "fil/1/storagepower.2", "fil/1/init.2", "fil/1/storageminer.1"} {
+			assert.Contains(t, byName, name)
+			assert.True(t, byName[name].Gets+byName[name].Puts > 0, name)
+		}
+		assert.True(t, byName["fil/1/storageminer.1"].Puts > 0)
+
+		buf := bytes.Buffer{}
+		require.NoError(t, store.WriteReport(&buf))
+		assert.Contains(t, buf.String(), "fil/1/storageminer.1")
+	})
+}
@@ -3,6 +3,7 @@ package ipld
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	block "github.com/ipfs/go-block-format"
 	"github.com/ipfs/go-cid"
@@ -11,15 +12,19 @@ import (
 	"github.com/filecoin-project/specs-actors/actors/util/adt"
 )
 
+// A blockstore held in memory, safe for concurrent use.
 type BlockStoreInMemory struct {
+	mu   sync.RWMutex
 	data map[cid.Cid]block.Block
 }
 
 func NewBlockStoreInMemory() *BlockStoreInMemory {
-	return &BlockStoreInMemory{make(map[cid.Cid]block.Block)}
+	return &BlockStoreInMemory{data: make(map[cid.Cid]block.Block)}
 }
 
 func (mb *BlockStoreInMemory) Get(c cid.Cid) (block.Block, error) {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
 	d, ok := mb.data[c]
 	if ok {
 		return d, nil
@@ -28,6 +33,8 @@ func (mb *BlockStoreInMemory) Get(c cid.Cid) (block.Block, error) {
 }
 
 func (mb *BlockStoreInMemory) Put(b block.Block) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
 	mb.data[b.Cid()] = b
 	return nil
 }
@@ -160,9 +160,6 @@ type expectVerifyConsensusFault struct {
 
 var _ runtime.Runtime = &Runtime{}
 var _ runtime.StateHandle = &Runtime{}
-var typeOfRuntimeInterface = reflect.TypeOf((*runtime.Runtime)(nil)).Elem()
-var typeOfCborUnmarshaler = reflect.TypeOf((*runtime.CBORUnmarshaler)(nil)).Elem()
-var typeOfCborMarshaler = reflect.TypeOf((*runtime.CBORMarshaler)(nil)).Elem()
 
 ///// Implementation of the runtime API /////
 
@@ -1032,14 +1029,8 @@ func (rt *Runtime) Call(method interface{}, params interface{}) interface{} {
 
 func (rt *Runtime) verifyExportedMethodType(meth reflect.Value) {
 	rt.t.Helper()
-	t := meth.Type()
-	rt.require(t.Kind() == reflect.Func, "%v is not a function", meth)
-	rt.require(t.NumIn() == 2, "exported method %v must have two parameters, got %v", meth, t.NumIn())
-	rt.require(t.In(0) == typeOfRuntimeInterface, "exported method first parameter must be runtime, got %v", t.In(0))
-	rt.require(t.In(1).Kind() == reflect.Ptr, "exported method second parameter must be pointer to params, got %v", t.In(1))
-	rt.require(t.In(1).Implements(typeOfCborUnmarshaler), "exported method second parameter must be CBOR-unmarshalable params, got %v", t.In(1))
-	rt.require(t.NumOut() == 1, "exported method must return a single value")
-	rt.require(t.Out(0).Implements(typeOfCborMarshaler), "exported method must return CBOR-marshalable value")
+	err := runtime.VerifyMethodType(meth.Type())
+	rt.require(err == nil, "%v", err)
 }
 
 func (rt *Runtime) requireInCall() {
@@ -0,0 +1,331 @@
+package statediff
+
+import (
+	"bytes"
+	"math/big"
+	"math/bits"
+	"sort"
+
+	amt "github.com/filecoin-project/go-amt-ipld/v2"
+	cid "github.com/ipfs/go-cid"
+	hamt "github.com/ipfs/go-hamt-ipld"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+)
+
+// Calls back with each index at which two arrays (AMTs) hold different values, in increasing index order.
+// A nil value indicates absence. An undefined root is treated as an empty array.
+// Subtrees with the same CID in both arrays are not loaded.
+func DiffArrays(store adt.Store, before, after cid.Cid, cb func(i uint64, before, after *cbg.Deferred) error) error {
+	if before.Equals(after) {
+		return nil
+	}
+	a, err := loadAMT(store, before)
+	if err != nil {
+		return err
+	}
+	b, err := loadAMT(store, after)
+	if err != nil {
+		return err
+	}
+	d := arrayDiffer{store: store, cb: cb}
+	return d.diff(&a.Node, int(a.Height), &b.Node, int(b.Height), 0)
+}
+
+// Calls back with each key for which two maps (HAMTs) hold different values, in an order determined by the key
+// hashes. A nil value indicates absence. An undefined root is treated as an empty map.
+// Subtrees with the same CID in both maps are not loaded.
+func DiffMaps(store adt.Store, before, after cid.Cid, cb func(key string, before, after *cbg.Deferred) error) error {
+	if before.Equals(after) {
+		return nil
+	}
+	a, err := loadHAMT(store, before)
+	if err != nil {
+		return err
+	}
+	b, err := loadHAMT(store, after)
+	if err != nil {
+		return err
+	}
+	d := mapDiffer{store: store, cb: cb}
+	return d.diff(a, b)
+}
+
+//
+// Arrays
+//
+
+const amtWidth = 8
+
+type arrayDiffer struct {
+	store adt.Store
+	cb    func(i uint64, before, after *cbg.Deferred) error
+}
+
+// Diffs two AMT nodes covering indices from offset. Either node may be nil.
+func (d *arrayDiffer) diff(a *amt.Node, aHeight int, b *amt.Node, bHeight int, offset uint64) error {
+	// The indices of the shorter tree all fall under the first child of each higher level of the taller tree.
+	if aHeight > bHeight {
+		first, err := d.loadChild(amtLink(a, 0))
+		if err != nil {
+			return err
+		}
+		if err := d.diff(first, aHeight-1, b, bHeight, offset); err != nil {
+			return err
+		}
+		for i := 1; i < amtWidth; i++ {
+			if err := d.diffChildren(amtLink(a, i), cid.Undef, aHeight, offset+uint64(i)*amtNodesForHeight(aHeight)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if bHeight > aHeight {
+		first, err := d.loadChild(amtLink(b, 0))
+		if err != nil {
+			return err
+		}
+		if err := d.diff(a, aHeight, first, bHeight-1, offset); err != nil {
+			return err
+		}
+		for i := 1; i < amtWidth; i++ {
+			if err := d.diffChildren(cid.Undef, amtLink(b, i), bHeight, offset+uint64(i)*amtNodesForHeight(bHeight)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	height := aHeight
+	for i := 0; i < amtWidth; i++ {
+		if height == 0 {
+			va, vb := amtValue(a, i), amtValue(b, i)
+			if !deferredEqual(va, vb) {
+				if err := d.cb(offset+uint64(i), va, vb); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := d.diffChildren(amtLink(a, i), amtLink(b, i), height, offset+uint64(i)*amtNodesForHeight(height)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Diffs the children linked from two nodes at a height.
+func (d *arrayDiffer) diffChildren(la, lb cid.Cid, parentHeight int, offset uint64) error {
+	if la.Equals(lb) {
+		return nil
+	}
+	a, err := d.loadChild(la)
+	if err != nil {
+		return err
+	}
+	b, err := d.loadChild(lb)
+	if err != nil {
+		return err
+	}
+	return d.diff(a, parentHeight-1, b, parentHeight-1, offset)
+}
+
+func (d *arrayDiffer) loadChild(c cid.Cid) (*amt.Node, error) {
+	if !c.Defined() {
+		return nil, nil
+	}
+	var n amt.Node
+	if err := d.store.Get(d.store.Context(), c, &n); err != nil {
+		return nil, xerrors.Errorf("failed to load array node %s: %w", c, err)
+	}
+	return &n, nil
+}
+
+func loadAMT(store adt.Store, c cid.Cid) (*amt.Root, error) {
+	if !c.Defined() {
+		return &amt.Root{}, nil
+	}
+	var r amt.Root
+	if err := store.Get(store.Context(), c, &r); err != nil {
+		return nil, xerrors.Errorf("failed to load array root %s: %w", c, err)
+	}
+	return &r, nil
+}
+
+// The position in a node's links or values of the entry at a slot, if the slot is occupied.
+func amtPosition(n *amt.Node, slot int) (int, bool) {
+	if n == nil || n.Bmap[0]&(1<<uint(slot)) == 0 {
+		return 0, false
+	}
+	return bits.OnesCount8(n.Bmap[0] & (1<<uint(slot) - 1)), true
+}
+
+func amtLink(n *amt.Node, slot int) cid.Cid {
+	if pos, ok := amtPosition(n, slot); ok && pos < len(n.Links) {
+		return n.Links[pos]
+	}
+	return cid.Undef
+}
+
+func amtValue(n *amt.Node, slot int) *cbg.Deferred {
+	if pos, ok := amtPosition(n, slot); ok && pos < len(n.Values) {
+		return n.Values[pos]
+	}
+	return nil
+}
+
+// The number of indices covered by each child of a node at a height.
+func amtNodesForHeight(height int) uint64 {
+	return 1 << uint(3*height)
+}
+
+//
+// Maps
+//
+
+type mapDiffer struct {
+	store adt.Store
+	cb    func(key string, before, after *cbg.Deferred) error
+}
+
+// Diffs two HAMT nodes at the same position in their trees. Either node may be nil.
+func (d *mapDiffer) diff(a, b *hamt.Node) error {
+	width := hamtBitLen(a)
+	if bw := hamtBitLen(b); bw > width {
+		width = bw
+	}
+	for slot := 0; slot < width; slot++ {
+		pa, pb := hamtPointer(a, slot), hamtPointer(b, slot)
+		if pa == nil && pb == nil {
+			continue
+		}
+		// Shards at the same position hold the same subset of key hashes, so may be compared directly.
+		if pa != nil && pb != nil && pa.Link.Defined() && pb.Link.Defined() {
+			if pa.Link.Equals(pb.Link) {
+				continue
+			}
+			ca, err := d.loadNode(pa.Link)
+			if err != nil {
+				return err
+			}
+			cb, err := d.loadNode(pb.Link)
+			if err != nil {
+				return err
+			}
+			if err := d.diff(ca, cb); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := d.diffEntries(pa, pb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Diffs all the entries under two pointers, at least one of which is a bucket of entries rather than a shard.
+func (d *mapDiffer) diffEntries(pa, pb *hamt.Pointer) error {
+	ea, err := d.entries(pa)
+	if err != nil {
+		return err
+	}
+	eb, err := d.entries(pb)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(ea)+len(eb))
+	for k := range ea { //nolint:nomaprange
+		keys = append(keys, k)
+	}
+	for k := range eb { //nolint:nomaprange
+		if _, ok := ea[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		va, vb := ea[k], eb[k]
+		if !deferredEqual(va, vb) {
+			if err := d.cb(k, va, vb); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Collects all the entries under a pointer.
+func (d *mapDiffer) entries(p *hamt.Pointer) (map[string]*cbg.Deferred, error) {
+	out := map[string]*cbg.Deferred{}
+	var collect func(p *hamt.Pointer) error
+	collect = func(p *hamt.Pointer) error {
+		if p == nil {
+			return nil
+		}
+		for _, kv := range p.KVs {
+			out[string(kv.Key)] = kv.Value
+		}
+		if !p.Link.Defined() {
+			return nil
+		}
+		n, err := d.loadNode(p.Link)
+		if err != nil {
+			return err
+		}
+		for _, child := range n.Pointers {
+			if err := collect(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return out, collect(p)
+}
+
+func (d *mapDiffer) loadNode(c cid.Cid) (*hamt.Node, error) {
+	return loadHAMT(d.store, c)
+}
+
+func loadHAMT(store adt.Store, c cid.Cid) (*hamt.Node, error) {
+	if !c.Defined() {
+		return nil, nil
+	}
+	var n hamt.Node
+	if err := store.Get(store.Context(), c, &n); err != nil {
+		return nil, xerrors.Errorf("failed to load map node %s: %w", c, err)
+	}
+	return &n, nil
+}
+
+func hamtBitLen(n *hamt.Node) int {
+	if n == nil || n.Bitfield == nil {
+		return 0
+	}
+	return n.Bitfield.BitLen()
+}
+
+// The pointer at a slot of a node, or nil if the slot is empty.
+func hamtPointer(n *hamt.Node, slot int) *hamt.Pointer {
+	if n == nil || n.Bitfield == nil || n.Bitfield.Bit(slot) == 0 {
+		return nil
+	}
+	mask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(slot)), big.NewInt(1))
+	pos := 0
+	for _, w := range mask.And(mask, n.Bitfield).Bits() {
+		pos += bits.OnesCount(uint(w))
+	}
+	if pos >= len(n.Pointers) {
+		return nil
+	}
+	return n.Pointers[pos]
+}
+
+func deferredEqual(a, b *cbg.Deferred) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return bytes.Equal(a.Raw, b.Raw)
+}
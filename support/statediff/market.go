@@ -0,0 +1,120 @@
+package statediff
+
+import (
+	addr "github.com/filecoin-project/go-address"
+	cid "github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	big "github.com/filecoin-project/specs-actors/actors/abi/big"
+	market "github.com/filecoin-project/specs-actors/actors/builtin/market"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+)
+
+// The changes between two market states.
+type MarketDiff struct {
+	Totals []FieldDelta  `json:"totals"`
+	Fields []FieldChange `json:"fields"`
+
+	DealsPublished []abi.DealID `json:"dealsPublished"`
+	DealsRemoved   []abi.DealID `json:"dealsRemoved"`
+	DealsActivated []abi.DealID `json:"dealsActivated"` // Deals whose state was created by sector activation.
+	DealsSlashed   []abi.DealID `json:"dealsSlashed"`
+
+	Escrow []BalanceChange `json:"escrow"`
+	Locked []BalanceChange `json:"locked"`
+}
+
+// A change to an address's entry in a balance table. A missing entry is reported as zero.
+type BalanceChange struct {
+	Address addr.Address `json:"address"`
+	Before  big.Int      `json:"before"`
+	After   big.Int      `json:"after"`
+	Delta   big.Int      `json:"delta"`
+}
+
+// Computes the changes between two market states.
+func DiffMarket(store adt.Store, before, after cid.Cid) (*MarketDiff, error) {
+	var a, b market.State
+	if err := store.Get(store.Context(), before, &a); err != nil {
+		return nil, xerrors.Errorf("failed to load market state %s: %w", before, err)
+	}
+	if err := store.Get(store.Context(), after, &b); err != nil {
+		return nil, xerrors.Errorf("failed to load market state %s: %w", after, err)
+	}
+
+	diff := &MarketDiff{}
+	diff.Totals = appendFieldDelta(diff.Totals, "TotalClientLockedCollateral", a.TotalClientLockedCollateral, b.TotalClientLockedCollateral)
+	diff.Totals = appendFieldDelta(diff.Totals, "TotalProviderLockedCollateral", a.TotalProviderLockedCollateral, b.TotalProviderLockedCollateral)
+	diff.Totals = appendFieldDelta(diff.Totals, "TotalClientStorageFee", a.TotalClientStorageFee, b.TotalClientStorageFee)
+	diff.Fields = appendFieldChange(diff.Fields, "NextID", int64(a.NextID), int64(b.NextID))
+	diff.Fields = appendFieldChange(diff.Fields, "LastCron", int64(a.LastCron), int64(b.LastCron))
+
+	if err := DiffArrays(store, a.Proposals, b.Proposals, func(i uint64, before, after *cbg.Deferred) error {
+		if before == nil {
+			diff.DealsPublished = append(diff.DealsPublished, abi.DealID(i))
+		} else if after == nil {
+			diff.DealsRemoved = append(diff.DealsRemoved, abi.DealID(i))
+		}
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("failed to diff deal proposals: %w", err)
+	}
+
+	if err := DiffArrays(store, a.States, b.States, func(i uint64, before, after *cbg.Deferred) error {
+		if after == nil {
+			return nil
+		}
+		var sb market.DealState
+		if err := decode(after, &sb); err != nil {
+			return err
+		}
+		sa := market.DealState{SectorStartEpoch: -1, LastUpdatedEpoch: -1, SlashEpoch: -1}
+		if before == nil {
+			diff.DealsActivated = append(diff.DealsActivated, abi.DealID(i))
+		} else if err := decode(before, &sa); err != nil {
+			return err
+		}
+		if sa.SlashEpoch == -1 && sb.SlashEpoch != -1 {
+			diff.DealsSlashed = append(diff.DealsSlashed, abi.DealID(i))
+		}
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("failed to diff deal states: %w", err)
+	}
+
+	var err error
+	if diff.Escrow, err = diffBalanceTables(store, a.EscrowTable, b.EscrowTable); err != nil {
+		return nil, xerrors.Errorf("failed to diff escrow table: %w", err)
+	}
+	if diff.Locked, err = diffBalanceTables(store, a.LockedTable, b.LockedTable); err != nil {
+		return nil, xerrors.Errorf("failed to diff locked table: %w", err)
+	}
+	return diff, nil
+}
+
+func diffBalanceTables(store adt.Store, before, after cid.Cid) ([]BalanceChange, error) {
+	var changes []BalanceChange
+	err := DiffMaps(store, before, after, func(key string, before, after *cbg.Deferred) error {
+		a, err := addr.NewFromBytes([]byte(key))
+		if err != nil {
+			return err
+		}
+		change := BalanceChange{Address: a, Before: big.Zero(), After: big.Zero()}
+		if before != nil {
+			if err := decode(before, &change.Before); err != nil {
+				return err
+			}
+		}
+		if after != nil {
+			if err := decode(after, &change.After); err != nil {
+				return err
+			}
+		}
+		change.Delta = big.Sub(change.After, change.Before)
+		changes = append(changes, change)
+		return nil
+	})
+	return changes, err
+}
@@ -0,0 +1,223 @@
+package statediff
+
+import (
+	"bytes"
+
+	"github.com/filecoin-project/go-bitfield"
+	cid "github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	miner "github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+)
+
+// The changes between two miner states.
+type MinerDiff struct {
+	InfoChanged bool          `json:"infoChanged"`
+	Balances    []FieldDelta  `json:"balances"`
+	Fields      []FieldChange `json:"fields"`
+
+	SectorsAdded    bitfield.BitField  `json:"sectorsAdded"`
+	SectorsRemoved  bitfield.BitField  `json:"sectorsRemoved"`
+	SectorsExtended []SectorExpiration `json:"sectorsExtended"` // Sectors whose expiration changed.
+	SectorsModified bitfield.BitField  `json:"sectorsModified"` // Sectors changed other than by expiration.
+
+	PreCommitsAdded   bitfield.BitField `json:"preCommitsAdded"`
+	PreCommitsRemoved bitfield.BitField `json:"preCommitsRemoved"`
+
+	Partitions []PartitionDiff `json:"partitions"`
+}
+
+type SectorExpiration struct {
+	Sector abi.SectorNumber `json:"sector"`
+	From   abi.ChainEpoch   `json:"from"`
+	To     abi.ChainEpoch   `json:"to"`
+}
+
+// The changes to a partition. Partitions that were added or removed are compared with an empty partition.
+type PartitionDiff struct {
+	Deadline  uint64 `json:"deadline"`
+	Partition uint64 `json:"partition"`
+
+	SectorsAdded    bitfield.BitField `json:"sectorsAdded"`
+	SectorsRemoved  bitfield.BitField `json:"sectorsRemoved"`
+	NewFaults       bitfield.BitField `json:"newFaults"`
+	FaultsRemoved   bitfield.BitField `json:"faultsRemoved"` // Recovered or terminated.
+	NewRecoveries   bitfield.BitField `json:"newRecoveries"` // Declared recovering.
+	NewTerminations bitfield.BitField `json:"newTerminations"`
+
+	LivePowerDelta   miner.PowerPair `json:"livePowerDelta"`
+	FaultyPowerDelta miner.PowerPair `json:"faultyPowerDelta"`
+}
+
+// Computes the changes between two miner states.
+func DiffMiner(store adt.Store, before, after cid.Cid) (*MinerDiff, error) {
+	var a, b miner.State
+	if err := store.Get(store.Context(), before, &a); err != nil {
+		return nil, xerrors.Errorf("failed to load miner state %s: %w", before, err)
+	}
+	if err := store.Get(store.Context(), after, &b); err != nil {
+		return nil, xerrors.Errorf("failed to load miner state %s: %w", after, err)
+	}
+
+	diff := &MinerDiff{InfoChanged: !a.Info.Equals(b.Info)}
+	diff.Balances = appendFieldDelta(diff.Balances, "PreCommitDeposits", a.PreCommitDeposits, b.PreCommitDeposits)
+	diff.Balances = appendFieldDelta(diff.Balances, "LockedFunds", a.LockedFunds, b.LockedFunds)
+	diff.Balances = appendFieldDelta(diff.Balances, "InitialPledgeRequirement", a.InitialPledgeRequirement, b.InitialPledgeRequirement)
+	diff.Fields = appendFieldChange(diff.Fields, "ProvingPeriodStart", int64(a.ProvingPeriodStart), int64(b.ProvingPeriodStart))
+	diff.Fields = appendFieldChange(diff.Fields, "CurrentDeadline", int64(a.CurrentDeadline), int64(b.CurrentDeadline))
+
+	var added, removed, modified []uint64
+	if err := DiffArrays(store, a.Sectors, b.Sectors, func(i uint64, before, after *cbg.Deferred) error {
+		switch {
+		case before == nil:
+			added = append(added, i)
+		case after == nil:
+			removed = append(removed, i)
+		default:
+			var sa, sb miner.SectorOnChainInfo
+			if err := decode(before, &sa); err != nil {
+				return err
+			}
+			if err := decode(after, &sb); err != nil {
+				return err
+			}
+			if sa.Expiration != sb.Expiration {
+				diff.SectorsExtended = append(diff.SectorsExtended, SectorExpiration{sa.SectorNumber, sa.Expiration, sb.Expiration})
+				sa.Expiration = sb.Expiration
+			}
+			if !cborEqual(&sa, &sb) {
+				modified = append(modified, i)
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("failed to diff sectors: %w", err)
+	}
+	diff.SectorsAdded = bitfield.NewFromSet(added)
+	diff.SectorsRemoved = bitfield.NewFromSet(removed)
+	diff.SectorsModified = bitfield.NewFromSet(modified)
+
+	var pcAdded, pcRemoved []uint64
+	if err := DiffMaps(store, a.PreCommittedSectors, b.PreCommittedSectors, func(key string, before, after *cbg.Deferred) error {
+		sectorNo, err := adt.ParseUIntKey(key)
+		if err != nil {
+			return err
+		}
+		if before == nil {
+			pcAdded = append(pcAdded, sectorNo)
+		} else if after == nil {
+			pcRemoved = append(pcRemoved, sectorNo)
+		}
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("failed to diff precommitted sectors: %w", err)
+	}
+	diff.PreCommitsAdded = bitfield.NewFromSet(pcAdded)
+	diff.PreCommitsRemoved = bitfield.NewFromSet(pcRemoved)
+
+	var err error
+	if diff.Partitions, err = diffDeadlines(store, a.Deadlines, b.Deadlines); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+func diffDeadlines(store adt.Store, before, after cid.Cid) ([]PartitionDiff, error) {
+	if before.Equals(after) {
+		return nil, nil
+	}
+	var da, db miner.Deadlines
+	if err := store.Get(store.Context(), before, &da); err != nil {
+		return nil, xerrors.Errorf("failed to load deadlines: %w", err)
+	}
+	if err := store.Get(store.Context(), after, &db); err != nil {
+		return nil, xerrors.Errorf("failed to load deadlines: %w", err)
+	}
+
+	var diffs []PartitionDiff
+	for dlIdx := range da.Due {
+		if da.Due[dlIdx].Equals(db.Due[dlIdx]) {
+			continue
+		}
+		var dla, dlb miner.Deadline
+		if err := store.Get(store.Context(), da.Due[dlIdx], &dla); err != nil {
+			return nil, xerrors.Errorf("failed to load deadline %d: %w", dlIdx, err)
+		}
+		if err := store.Get(store.Context(), db.Due[dlIdx], &dlb); err != nil {
+			return nil, xerrors.Errorf("failed to load deadline %d: %w", dlIdx, err)
+		}
+
+		if err := DiffArrays(store, dla.Partitions, dlb.Partitions, func(partIdx uint64, before, after *cbg.Deferred) error {
+			pa, pb := emptyPartition(), emptyPartition()
+			if before != nil {
+				if err := decode(before, pa); err != nil {
+					return err
+				}
+			}
+			if after != nil {
+				if err := decode(after, pb); err != nil {
+					return err
+				}
+			}
+			pd, err := diffPartition(pa, pb)
+			if err != nil {
+				return xerrors.Errorf("failed to diff partition %d of deadline %d: %w", partIdx, dlIdx, err)
+			}
+			pd.Deadline, pd.Partition = uint64(dlIdx), partIdx
+			diffs = append(diffs, *pd)
+			return nil
+		}); err != nil {
+			return nil, xerrors.Errorf("failed to diff partitions of deadline %d: %w", dlIdx, err)
+		}
+	}
+	return diffs, nil
+}
+
+func diffPartition(a, b *miner.Partition) (*PartitionDiff, error) {
+	var d PartitionDiff
+	var err error
+	for _, set := range []struct {
+		out           *bitfield.BitField
+		after, before bitfield.BitField
+	}{
+		{&d.SectorsAdded, b.Sectors, a.Sectors},
+		{&d.SectorsRemoved, a.Sectors, b.Sectors},
+		{&d.NewFaults, b.Faults, a.Faults},
+		{&d.FaultsRemoved, a.Faults, b.Faults},
+		{&d.NewRecoveries, b.Recoveries, a.Recoveries},
+		{&d.NewTerminations, b.Terminated, a.Terminated},
+	} {
+		if *set.out, err = bitfield.SubtractBitField(set.after, set.before); err != nil {
+			return nil, err
+		}
+	}
+	d.LivePowerDelta = b.LivePower.Sub(a.LivePower)
+	d.FaultyPowerDelta = b.FaultyPower.Sub(a.FaultyPower)
+	return &d, nil
+}
+
+func emptyPartition() *miner.Partition {
+	return &miner.Partition{
+		Sectors:         bitfield.New(),
+		Faults:          bitfield.New(),
+		Recoveries:      bitfield.New(),
+		Terminated:      bitfield.New(),
+		LivePower:       miner.NewPowerPairZero(),
+		FaultyPower:     miner.NewPowerPairZero(),
+		RecoveringPower: miner.NewPowerPairZero(),
+	}
+}
+
+func cborEqual(a, b cbg.CBORMarshaler) bool {
+	ba, bb := bytes.Buffer{}, bytes.Buffer{}
+	if err := a.MarshalCBOR(&ba); err != nil {
+		return false
+	}
+	if err := b.MarshalCBOR(&bb); err != nil {
+		return false
+	}
+	return bytes.Equal(ba.Bytes(), bb.Bytes())
+}
@@ -0,0 +1,71 @@
+package statediff
+
+import (
+	addr "github.com/filecoin-project/go-address"
+	cid "github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+
+	power "github.com/filecoin-project/specs-actors/actors/builtin/power"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+)
+
+// The changes between two power states.
+type PowerDiff struct {
+	Totals []FieldDelta  `json:"totals"`
+	Fields []FieldChange `json:"fields"`
+	Claims []ClaimChange `json:"claims"`
+}
+
+// A change to a miner's claim. A nil claim indicates absence.
+type ClaimChange struct {
+	Miner  addr.Address `json:"miner"`
+	Before *power.Claim `json:"before"`
+	After  *power.Claim `json:"after"`
+}
+
+// Computes the changes between two power states.
+func DiffPower(store adt.Store, before, after cid.Cid) (*PowerDiff, error) {
+	var a, b power.State
+	if err := store.Get(store.Context(), before, &a); err != nil {
+		return nil, xerrors.Errorf("failed to load power state %s: %w", before, err)
+	}
+	if err := store.Get(store.Context(), after, &b); err != nil {
+		return nil, xerrors.Errorf("failed to load power state %s: %w", after, err)
+	}
+
+	diff := &PowerDiff{}
+	diff.Totals = appendFieldDelta(diff.Totals, "TotalRawBytePower", a.TotalRawBytePower, b.TotalRawBytePower)
+	diff.Totals = appendFieldDelta(diff.Totals, "TotalBytesCommitted", a.TotalBytesCommitted, b.TotalBytesCommitted)
+	diff.Totals = appendFieldDelta(diff.Totals, "TotalQualityAdjPower", a.TotalQualityAdjPower, b.TotalQualityAdjPower)
+	diff.Totals = appendFieldDelta(diff.Totals, "TotalQABytesCommitted", a.TotalQABytesCommitted, b.TotalQABytesCommitted)
+	diff.Totals = appendFieldDelta(diff.Totals, "TotalPledgeCollateral", a.TotalPledgeCollateral, b.TotalPledgeCollateral)
+	diff.Fields = appendFieldChange(diff.Fields, "MinerCount", a.MinerCount, b.MinerCount)
+	diff.Fields = appendFieldChange(diff.Fields, "MinerAboveMinPowerCount", a.MinerAboveMinPowerCount, b.MinerAboveMinPowerCount)
+	diff.Fields = appendFieldChange(diff.Fields, "FirstCronEpoch", int64(a.FirstCronEpoch), int64(b.FirstCronEpoch))
+
+	if err := DiffMaps(store, a.Claims, b.Claims, func(key string, before, after *cbg.Deferred) error {
+		miner, err := addr.NewFromBytes([]byte(key))
+		if err != nil {
+			return err
+		}
+		change := ClaimChange{Miner: miner}
+		if before != nil {
+			change.Before = &power.Claim{}
+			if err := decode(before, change.Before); err != nil {
+				return err
+			}
+		}
+		if after != nil {
+			change.After = &power.Claim{}
+			if err := decode(after, change.After); err != nil {
+				return err
+			}
+		}
+		diff.Claims = append(diff.Claims, change)
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("failed to diff claims: %w", err)
+	}
+	return diff, nil
+}
@@ -0,0 +1,66 @@
+// Package statediff computes the differences between two versions of actor state, reported in terms of the actor's
+// domain (sectors, partitions, deals, balances, claims) rather than blocks.
+// The HAMTs and AMTs holding the state are compared structurally, so the cost of a diff is proportional to the
+// size of the change rather than the size of the state.
+package statediff
+
+import (
+	"bytes"
+
+	cid "github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+
+	big "github.com/filecoin-project/specs-actors/actors/abi/big"
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+)
+
+// A change to a token amount or quantity of power.
+type FieldDelta struct {
+	Field  string  `json:"field"`
+	Before big.Int `json:"before"`
+	After  big.Int `json:"after"`
+	Delta  big.Int `json:"delta"`
+}
+
+// A change to a scalar field such as an epoch or count.
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before int64  `json:"before"`
+	After  int64  `json:"after"`
+}
+
+// Appends a delta if the values differ.
+func appendFieldDelta(deltas []FieldDelta, field string, before, after big.Int) []FieldDelta {
+	if before.Equals(after) {
+		return deltas
+	}
+	return append(deltas, FieldDelta{Field: field, Before: before, After: after, Delta: big.Sub(after, before)})
+}
+
+// Appends a change if the values differ.
+func appendFieldChange(changes []FieldChange, field string, before, after int64) []FieldChange {
+	if before == after {
+		return changes
+	}
+	return append(changes, FieldChange{Field: field, Before: before, After: after})
+}
+
+func decode(d *cbg.Deferred, out cbg.CBORUnmarshaler) error {
+	return out.UnmarshalCBOR(bytes.NewReader(d.Raw))
+}
+
+// Computes the changes between two states of a builtin actor, selecting the kind of diff by the actor's code CID.
+func DiffActorState(store adt.Store, code cid.Cid, before, after cid.Cid) (interface{}, error) {
+	switch {
+	case code.Equals(builtin.StorageMinerActorCodeID):
+		return DiffMiner(store, before, after)
+	case code.Equals(builtin.StorageMarketActorCodeID):
+		return DiffMarket(store, before, after)
+	case code.Equals(builtin.StoragePowerActorCodeID):
+		return DiffPower(store, before, after)
+	default:
+		return nil, xerrors.Errorf("no state diff for actor code %s", code)
+	}
+}
@@ -0,0 +1,340 @@
+package statediff_test
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"testing"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-bitfield"
+	cid "github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	big "github.com/filecoin-project/specs-actors/actors/abi/big"
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	miner "github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	power "github.com/filecoin-project/specs-actors/actors/builtin/power"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+	ipld "github.com/filecoin-project/specs-actors/support/ipld"
+	"github.com/filecoin-project/specs-actors/support/statediff"
+	tutil "github.com/filecoin-project/specs-actors/support/testing"
+	vm "github.com/filecoin-project/specs-actors/support/vm"
+)
+
+type stringKey string
+
+func (k stringKey) Key() string {
+	return string(k)
+}
+
+type change struct {
+	before, after *int64
+}
+
+func TestDiffArrays(t *testing.T) {
+	store := ipld.NewADTStore(context.Background())
+	rnd := rand.New(rand.NewSource(42))
+
+	for _, tc := range []struct {
+		name                  string
+		beforeRange, afterMax uint64
+	}{
+		{"same height", 500, 500},
+		{"growing height", 20, 100_000},
+		{"shrinking height", 100_000, 20},
+		{"empty before", 0, 1000},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			before := map[uint64]int64{}
+			for i := 0; i < int(tc.beforeRange)/4; i++ {
+				before[uint64(rnd.Int63n(int64(tc.beforeRange)))] = rnd.Int63n(10)
+			}
+			after := map[uint64]int64{}
+			for k, v := range before { //nolint:nomaprange
+				if k < tc.afterMax && rnd.Intn(4) != 0 {
+					if rnd.Intn(4) == 0 {
+						v++
+					}
+					after[k] = v
+				}
+			}
+			for i := 0; i < 50; i++ {
+				after[uint64(rnd.Int63n(int64(tc.afterMax)))] = rnd.Int63n(10)
+			}
+
+			expected := map[uint64]change{}
+			for k, v := range before { //nolint:nomaprange
+				v := v
+				expected[k] = change{before: &v}
+			}
+			for k, v := range after { //nolint:nomaprange
+				v := v
+				c := expected[k]
+				if c.before != nil && *c.before == v {
+					delete(expected, k)
+					continue
+				}
+				c.after = &v
+				expected[k] = c
+			}
+
+			actual := map[uint64]change{}
+			last := int64(-1)
+			require.NoError(t, statediff.DiffArrays(store, makeArray(t, store, before), makeArray(t, store, after),
+				func(i uint64, before, after *cbg.Deferred) error {
+					assert.Greater(t, int64(i), last, "indices out of order")
+					last = int64(i)
+					actual[i] = change{decodeInt(t, before), decodeInt(t, after)}
+					return nil
+				}))
+			assert.Equal(t, expected, actual)
+		})
+	}
+
+	t.Run("identical roots are not loaded", func(t *testing.T) {
+		missing := tutil.MakeCID("missing", nil)
+		require.NoError(t, statediff.DiffArrays(store, missing, missing, func(uint64, *cbg.Deferred, *cbg.Deferred) error {
+			t.Fatal("unexpected change")
+			return nil
+		}))
+	})
+}
+
+func TestDiffMaps(t *testing.T) {
+	store := ipld.NewADTStore(context.Background())
+	rnd := rand.New(rand.NewSource(7))
+
+	before := map[string]int64{}
+	for i := 0; i < 2000; i++ {
+		before[adt.IntKey(rnd.Int63n(5000)).Key()] = rnd.Int63n(10)
+	}
+	after := map[string]int64{}
+	for k, v := range before { //nolint:nomaprange
+		switch rnd.Intn(10) {
+		case 0: // deleted
+		case 1:
+			after[k] = v + 1
+		default:
+			after[k] = v
+		}
+	}
+	for i := 0; i < 200; i++ {
+		after[adt.IntKey(5000+rnd.Int63n(5000)).Key()] = rnd.Int63n(10)
+	}
+
+	expected := map[string]change{}
+	for k, v := range before { //nolint:nomaprange
+		v := v
+		if av, ok := after[k]; !ok {
+			expected[k] = change{before: &v}
+		} else if av != v {
+			expected[k] = change{&v, &av}
+		}
+	}
+	for k, v := range after { //nolint:nomaprange
+		v := v
+		if _, ok := before[k]; !ok {
+			expected[k] = change{after: &v}
+		}
+	}
+
+	actual := map[string]change{}
+	require.NoError(t, statediff.DiffMaps(store, makeMap(t, store, before), makeMap(t, store, after),
+		func(key string, before, after *cbg.Deferred) error {
+			_, seen := actual[key]
+			assert.False(t, seen, "key reported twice")
+			actual[key] = change{decodeInt(t, before), decodeInt(t, after)}
+			return nil
+		}))
+	assert.Equal(t, expected, actual)
+
+	t.Run("from and to empty", func(t *testing.T) {
+		count := 0
+		require.NoError(t, statediff.DiffMaps(store, makeMap(t, store, nil), makeMap(t, store, before),
+			func(key string, before, after *cbg.Deferred) error {
+				assert.Nil(t, before)
+				count++
+				return nil
+			}))
+		assert.Equal(t, len(before), count)
+	})
+}
+
+func TestDiffMiner(t *testing.T) {
+	ctx := context.Background()
+	store := ipld.NewADTStore(ctx)
+	sealProof := abi.RegisteredSealProof_StackedDrg32GiBV1
+	sectorSize, err := sealProof.SectorSize()
+	require.NoError(t, err)
+	partitionSize, err := sealProof.WindowPoStPartitionSectors()
+	require.NoError(t, err)
+
+	emptyMap, err := adt.MakeEmptyMap(store).Root()
+	require.NoError(t, err)
+	emptyArray, err := adt.MakeEmptyArray(store).Root()
+	require.NoError(t, err)
+	emptyBitfield, err := store.Put(ctx, bitfield.New())
+	require.NoError(t, err)
+	emptyDeadline, err := store.Put(ctx, miner.ConstructDeadline(emptyArray))
+	require.NoError(t, err)
+	emptyDeadlines, err := store.Put(ctx, miner.ConstructDeadlines(emptyDeadline))
+	require.NoError(t, err)
+	info, err := miner.ConstructMinerInfo(tutil.NewIDAddr(t, 100), tutil.NewIDAddr(t, 101), abi.PeerID("peer"), nil, sealProof)
+	require.NoError(t, err)
+	infoCid, err := store.Put(ctx, info)
+	require.NoError(t, err)
+	st, err := miner.ConstructState(infoCid, 0, emptyBitfield, emptyArray, emptyMap, emptyDeadlines)
+	require.NoError(t, err)
+
+	sectors := []*miner.SectorOnChainInfo{makeSector(1, 1000), makeSector(2, 1000), makeSector(3, 2000)}
+	require.NoError(t, st.PutSectors(store, sectors...))
+	_, err = st.AssignSectorsToDeadlines(store, 0, sectors, partitionSize, sectorSize)
+	require.NoError(t, err)
+	before, err := store.Put(ctx, st)
+	require.NoError(t, err)
+
+	// Add two sectors, extend one and remove another, and lock some funds.
+	added := []*miner.SectorOnChainInfo{makeSector(4, 1000), makeSector(5, 3000)}
+	require.NoError(t, st.PutSectors(store, added...))
+	livePower, err := st.AssignSectorsToDeadlines(store, 0, added, partitionSize, sectorSize)
+	require.NoError(t, err)
+	require.NoError(t, st.PutSectors(store, makeSector(1, 5000)))
+	require.NoError(t, st.DeleteSectors(store, bitfield.NewFromSet([]uint64{3})))
+	st.LockedFunds = abi.NewTokenAmount(1000)
+	after, err := store.Put(ctx, st)
+	require.NoError(t, err)
+
+	diff, err := statediff.DiffMiner(store, before, after)
+	require.NoError(t, err)
+	assertBits(t, []uint64{4, 5}, diff.SectorsAdded)
+	assertBits(t, []uint64{3}, diff.SectorsRemoved)
+	assertBits(t, nil, diff.SectorsModified)
+	assert.Equal(t, []statediff.SectorExpiration{{Sector: 1, From: 1000, To: 5000}}, diff.SectorsExtended)
+	assert.False(t, diff.InfoChanged)
+	assert.Equal(t, []statediff.FieldDelta{{
+		Field:  "LockedFunds",
+		Before: big.Zero(),
+		After:  abi.NewTokenAmount(1000),
+		Delta:  abi.NewTokenAmount(1000),
+	}}, diff.Balances)
+
+	require.Len(t, diff.Partitions, 1)
+	part := diff.Partitions[0]
+	assertBits(t, []uint64{4, 5}, part.SectorsAdded)
+	assertBits(t, nil, part.NewFaults)
+	assert.Equal(t, livePower, part.LivePowerDelta)
+	assert.True(t, part.FaultyPowerDelta.IsZero())
+
+	unchanged, err := statediff.DiffMiner(store, after, after)
+	require.NoError(t, err)
+	assert.Empty(t, unchanged.Partitions)
+	assert.Empty(t, unchanged.Balances)
+}
+
+func TestDiffMarketAndPower(t *testing.T) {
+	ctx := context.Background()
+	v := vm.NewVMWithSingletons(ctx, t)
+	addrs := vm.CreateAccounts(ctx, t, v, 1, big.Mul(big.NewInt(10_000), vm.FIL), 93837778)
+	owner := addrs[0]
+
+	marketBefore, powerBefore := actorHead(t, v, builtin.StorageMarketActorAddr), actorHead(t, v, builtin.StoragePowerActorAddr)
+
+	vm.ApplyOk(t, v, owner, builtin.StorageMarketActorAddr, vm.FIL, builtin.MethodsMarket.AddBalance, &owner)
+	ret := vm.ApplyOk(t, v, owner, builtin.StoragePowerActorAddr, big.Zero(), builtin.MethodsPower.CreateMiner, &power.CreateMinerParams{
+		Owner:         owner,
+		Worker:        owner,
+		SealProofType: abi.RegisteredSealProof_StackedDrg32GiBV1,
+		Peer:          abi.PeerID("peer"),
+	})
+	var minerAddrs power.CreateMinerReturn
+	require.NoError(t, ret.Into(&minerAddrs))
+
+	marketAfter, powerAfter := actorHead(t, v, builtin.StorageMarketActorAddr), actorHead(t, v, builtin.StoragePowerActorAddr)
+
+	marketDiff, err := statediff.DiffActorState(v.Store(), builtin.StorageMarketActorCodeID, marketBefore, marketAfter)
+	require.NoError(t, err)
+	md := marketDiff.(*statediff.MarketDiff)
+	require.Len(t, md.Escrow, 1)
+	assert.Equal(t, vm.FIL, md.Escrow[0].Delta)
+	assert.Empty(t, md.Locked)
+	assert.Empty(t, md.DealsPublished)
+
+	powerDiff, err := statediff.DiffActorState(v.Store(), builtin.StoragePowerActorCodeID, powerBefore, powerAfter)
+	require.NoError(t, err)
+	pd := powerDiff.(*statediff.PowerDiff)
+	require.Len(t, pd.Claims, 1)
+	assert.Equal(t, minerAddrs.IDAddress, pd.Claims[0].Miner)
+	assert.Nil(t, pd.Claims[0].Before)
+	require.NotNil(t, pd.Claims[0].After)
+	assert.Equal(t, []statediff.FieldChange{{Field: "MinerCount", Before: 0, After: 1}}, pd.Fields)
+
+	_, err = statediff.DiffActorState(v.Store(), builtin.AccountActorCodeID, marketBefore, marketAfter)
+	assert.Error(t, err)
+}
+
+func makeArray(t *testing.T, store adt.Store, values map[uint64]int64) cid.Cid {
+	arr := adt.MakeEmptyArray(store)
+	for k, v := range values { //nolint:nomaprange
+		v := cbg.CborInt(v)
+		require.NoError(t, arr.Set(k, &v))
+	}
+	root, err := arr.Root()
+	require.NoError(t, err)
+	return root
+}
+
+func makeMap(t *testing.T, store adt.Store, values map[string]int64) cid.Cid {
+	m := adt.MakeEmptyMap(store)
+	for k, v := range values { //nolint:nomaprange
+		v := cbg.CborInt(v)
+		require.NoError(t, m.Put(stringKey(k), &v))
+	}
+	root, err := m.Root()
+	require.NoError(t, err)
+	return root
+}
+
+func decodeInt(t *testing.T, d *cbg.Deferred) *int64 {
+	if d == nil {
+		return nil
+	}
+	var v cbg.CborInt
+	require.NoError(t, v.UnmarshalCBOR(bytes.NewReader(d.Raw)))
+	i := int64(v)
+	return &i
+}
+
+func makeSector(number abi.SectorNumber, expiration abi.ChainEpoch) *miner.SectorOnChainInfo {
+	return &miner.SectorOnChainInfo{
+		SectorNumber:       number,
+		SealProof:          abi.RegisteredSealProof_StackedDrg32GiBV1,
+		SealedCID:          tutil.MakeCID("commR", &miner.SealedCIDPrefix),
+		Expiration:         expiration,
+		DealWeight:         big.Zero(),
+		VerifiedDealWeight: big.Zero(),
+		InitialPledge:      abi.NewTokenAmount(1 << 20),
+	}
+}
+
+func actorHead(t *testing.T, v *vm.VM, a addr.Address) cid.Cid {
+	act, found, err := v.GetActor(a)
+	require.NoError(t, err)
+	require.True(t, found)
+	return act.Head
+}
+
+func assertBits(t *testing.T, expected []uint64, bf bitfield.BitField) {
+	actual, err := bf.All(1 << 20)
+	require.NoError(t, err)
+	if expected == nil {
+		expected = []uint64{}
+	}
+	if actual == nil {
+		actual = []uint64{}
+	}
+	assert.Equal(t, expected, actual)
+}
@@ -0,0 +1,27 @@
+package testing
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+)
+
+// Asserts that an accumulator holds no messages, such as when a state violates no invariants.
+func AssertNoMsgs(t testing.TB, acc *builtin.MessageAccumulator) {
+	t.Helper()
+	assert.True(t, acc.IsEmpty(), "unexpected messages %v", acc.Messages())
+}
+
+// Asserts that some message held by an accumulator contains a substring, such as the expected invariant violation.
+func AssertMsgContains(t testing.TB, acc *builtin.MessageAccumulator, substr string) {
+	t.Helper()
+	for _, msg := range acc.Messages() {
+		if strings.Contains(msg, substr) {
+			return
+		}
+	}
+	assert.Fail(t, "expected message not found", "expected %q in %v", substr, acc.Messages())
+}
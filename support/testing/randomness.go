@@ -0,0 +1,50 @@
+package testing
+
+import (
+	"encoding/binary"
+
+	"github.com/minio/blake2b-simd"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	crypto "github.com/filecoin-project/specs-actors/actors/crypto"
+)
+
+// FakeRandomness is a deterministic source of chain randomness.
+// The beacon and ticket values for each epoch are derived from a seed, and randomness is drawn from them with
+// crypto.DrawRandomness, so values are reproducible but distinct for each tag, epoch and entropy.
+type FakeRandomness struct {
+	seed []byte
+}
+
+func NewFakeRandomness(seed []byte) *FakeRandomness {
+	return &FakeRandomness{seed: seed}
+}
+
+// The fake beacon value for an epoch.
+func (r *FakeRandomness) BeaconValue(epoch abi.ChainEpoch) []byte {
+	return r.baseValue("beacon", epoch)
+}
+
+// The fake ticket value for an epoch.
+func (r *FakeRandomness) TicketValue(epoch abi.ChainEpoch) []byte {
+	return r.baseValue("ticket", epoch)
+}
+
+func (r *FakeRandomness) GetRandomnessFromBeacon(tag crypto.DomainSeparationTag, epoch abi.ChainEpoch, entropy []byte) abi.Randomness {
+	return crypto.DrawRandomness(r.BeaconValue(epoch), tag, epoch, entropy)
+}
+
+func (r *FakeRandomness) GetRandomnessFromTickets(tag crypto.DomainSeparationTag, epoch abi.ChainEpoch, entropy []byte) abi.Randomness {
+	return crypto.DrawRandomness(r.TicketValue(epoch), tag, epoch, entropy)
+}
+
+func (r *FakeRandomness) baseValue(kind string, epoch abi.ChainEpoch) []byte {
+	var epochBuf [8]byte
+	binary.BigEndian.PutUint64(epochBuf[:], uint64(epoch))
+
+	h := blake2b.New256()
+	_, _ = h.Write(r.seed)
+	_, _ = h.Write([]byte(kind))
+	_, _ = h.Write(epochBuf[:])
+	return h.Sum(nil)
+}
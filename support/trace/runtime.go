@@ -0,0 +1,97 @@
+package trace
+
+import (
+	"fmt"
+	"time"
+
+	addr "github.com/filecoin-project/go-address"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	runtime "github.com/filecoin-project/specs-actors/actors/runtime"
+	exitcode "github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+)
+
+// Wraps a runtime so that sends, aborts, logs, spans and gas charges are recorded in the recorder's current
+// invocation.
+// When combined with gas metering, the metering wrapper should wrap this one, so that a send which runs out of
+// gas is not recorded as a subcall.
+func NewTracedRuntime(rt runtime.Runtime, recorder *Recorder) runtime.Runtime {
+	return &tracedRuntime{
+		Runtime:  rt,
+		recorder: recorder,
+	}
+}
+
+type tracedRuntime struct {
+	runtime.Runtime
+	recorder *Recorder
+}
+
+var _ runtime.Runtime = (*tracedRuntime)(nil)
+
+func (rt *tracedRuntime) Send(toAddr addr.Address, methodNum abi.MethodNum, params runtime.CBORMarshaler, value abi.TokenAmount) (runtime.SendReturn, exitcode.ExitCode) {
+	rt.recorder.enter(rt.Message().Receiver(), toAddr, methodNum, value, params)
+
+	// Keep the recorder's stack consistent if the send panics, as when the caller is forbidden from sending,
+	// recording the code and message of an abort.
+	completed := false
+	defer func() {
+		if completed {
+			return
+		}
+		r := recover()
+		code := exitcode.SysErrorIllegalActor
+		if a, ok := r.(runtime.Abort); ok {
+			code = a.Code
+			rt.recorder.Abort(a.Msg)
+		}
+		rt.recorder.exit(nil, code)
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	ret, code := rt.Runtime.Send(toAddr, methodNum, params, value)
+	completed = true
+	rt.recorder.exit(ret, code)
+	return ret, code
+}
+
+func (rt *tracedRuntime) Abortf(errExitCode exitcode.ExitCode, msg string, args ...interface{}) {
+	if inv := rt.recorder.current(); inv != nil {
+		inv.Abort = fmt.Sprintf(msg, args...)
+	}
+	rt.Runtime.Abortf(errExitCode, msg, args...)
+}
+
+func (rt *tracedRuntime) Log(level runtime.LogLevel, msg string, args ...interface{}) {
+	if inv := rt.recorder.current(); inv != nil {
+		inv.Logs = append(inv.Logs, Log{Level: level, Message: fmt.Sprintf(msg, args...)})
+	}
+	rt.Runtime.Log(level, msg, args...)
+}
+
+func (rt *tracedRuntime) StartSpan(name string) runtime.TraceSpan {
+	span := &Span{Name: name, start: time.Now()}
+	if inv := rt.recorder.current(); inv != nil {
+		inv.Spans = append(inv.Spans, span)
+	}
+	return &tracedSpan{inner: rt.Runtime.StartSpan(name), span: span}
+}
+
+func (rt *tracedRuntime) ChargeGas(name string, gas int64, virtual int64) {
+	if inv := rt.recorder.current(); inv != nil {
+		inv.GasCharges = append(inv.GasCharges, GasCharge{Name: name, Gas: gas, Virtual: virtual})
+	}
+	rt.Runtime.ChargeGas(name, gas, virtual)
+}
+
+type tracedSpan struct {
+	inner runtime.TraceSpan
+	span  *Span
+}
+
+func (s *tracedSpan) End() {
+	s.span.Duration = time.Since(s.span.start)
+	s.inner.End()
+}
@@ -0,0 +1,175 @@
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	addr "github.com/filecoin-project/go-address"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	runtime "github.com/filecoin-project/specs-actors/actors/runtime"
+	exitcode "github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	gas "github.com/filecoin-project/specs-actors/actors/runtime/gas"
+)
+
+// An Invocation records a single method invocation and, recursively, the invocations it made.
+type Invocation struct {
+	Caller   addr.Address      `json:"caller"`
+	Receiver addr.Address      `json:"receiver"`
+	Method   abi.MethodNum     `json:"method"`
+	Value    abi.TokenAmount   `json:"value"`
+	Params   interface{}       `json:"params"`
+	Return   interface{}       `json:"return"`
+	ExitCode exitcode.ExitCode `json:"exitCode"`
+	// The message passed to Abortf, if the invocation aborted.
+	Abort string `json:"abort,omitempty"`
+
+	// Gas consumed by the invocation, including its subcalls, if gas is metered.
+	GasUsed int64 `json:"gasUsed"`
+	// Explicit charges made by the actor via ChargeGas.
+	GasCharges []GasCharge `json:"gasCharges,omitempty"`
+
+	Logs     []Log         `json:"logs,omitempty"`
+	Spans    []*Span       `json:"spans,omitempty"`
+	Subcalls []*Invocation `json:"subcalls,omitempty"`
+
+	gasAtStart int64
+}
+
+type GasCharge struct {
+	Name    string `json:"name"`
+	Gas     int64  `json:"gas"`
+	Virtual int64  `json:"virtual,omitempty"`
+}
+
+type Log struct {
+	Level   runtime.LogLevel `json:"level"`
+	Message string           `json:"message"`
+}
+
+// A Span records a span started by an actor. The duration is zero if the span was never ended.
+type Span struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+
+	start time.Time
+}
+
+// A SendReturn implementing ReturnValuer exposes the callee's return value so it can be recorded.
+// The return value of any other SendReturn is not recorded.
+type ReturnValuer interface {
+	ReturnValue() interface{}
+}
+
+// A Recorder accumulates a trace for each top-level message applied.
+// The runtime implementation brackets each top-level message with Begin and End, wraps the runtime
+// provided to every actor invocation with NewTracedRuntime, and reports each abort it recovers with Abort.
+// A Recorder is not safe for concurrent use.
+type Recorder struct {
+	traces  []*Invocation
+	stack   []*Invocation
+	tracker *gas.Tracker
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Begins recording a top-level message.
+// The tracker, which may be nil, is the one charged for gas by the message's invocations.
+func (r *Recorder) Begin(caller, receiver addr.Address, method abi.MethodNum, value abi.TokenAmount, params interface{}, tracker *gas.Tracker) {
+	if len(r.stack) != 0 {
+		panic(fmt.Sprintf("top-level message begun while %d invocations are incomplete", len(r.stack)))
+	}
+	r.tracker = tracker
+	r.traces = append(r.traces, r.enter(caller, receiver, method, value, params))
+}
+
+// Completes recording of a top-level message.
+func (r *Recorder) End(ret runtime.SendReturn, code exitcode.ExitCode) {
+	if len(r.stack) != 1 {
+		panic(fmt.Sprintf("top-level message ended while %d invocations are incomplete", len(r.stack)-1))
+	}
+	r.exit(ret, code)
+	r.tracker = nil
+}
+
+// Records the message of an abort by the invocation currently executing.
+// The runtime implementation calls this for every abort it recovers, since aborts raised by the runtime itself (such
+// as a failed caller validation) do not pass through the traced runtime's Abortf.
+func (r *Recorder) Abort(msg string) {
+	if inv := r.current(); inv != nil {
+		inv.Abort = msg
+	}
+}
+
+// The traces of all top-level messages begun, in order.
+func (r *Recorder) Traces() []*Invocation {
+	return r.traces
+}
+
+// The most recent trace, or nil if no message has been recorded.
+func (r *Recorder) Last() *Invocation {
+	if len(r.traces) == 0 {
+		return nil
+	}
+	return r.traces[len(r.traces)-1]
+}
+
+// Discards all traces.
+func (r *Recorder) Reset() {
+	r.traces = nil
+	r.stack = nil
+	r.tracker = nil
+}
+
+// Writes the traces as an indented JSON array.
+func (r *Recorder) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r.traces)
+}
+
+func (r *Recorder) enter(caller, receiver addr.Address, method abi.MethodNum, value abi.TokenAmount, params interface{}) *Invocation {
+	inv := &Invocation{
+		Caller:     caller,
+		Receiver:   receiver,
+		Method:     method,
+		Value:      value,
+		Params:     params,
+		gasAtStart: r.gasUsed(),
+	}
+	if parent := r.current(); parent != nil {
+		parent.Subcalls = append(parent.Subcalls, inv)
+	}
+	r.stack = append(r.stack, inv)
+	return inv
+}
+
+func (r *Recorder) exit(ret runtime.SendReturn, code exitcode.ExitCode) {
+	inv := r.current()
+	r.stack = r.stack[:len(r.stack)-1]
+
+	if valuer, ok := ret.(ReturnValuer); ok {
+		inv.Return = valuer.ReturnValue()
+	}
+	inv.ExitCode = code
+	inv.GasUsed = r.gasUsed() - inv.gasAtStart
+}
+
+// The invocation currently executing, or nil if there is none.
+func (r *Recorder) current() *Invocation {
+	if len(r.stack) == 0 {
+		return nil
+	}
+	return r.stack[len(r.stack)-1]
+}
+
+func (r *Recorder) gasUsed() int64 {
+	if r.tracker == nil {
+		return 0
+	}
+	return r.tracker.Used()
+}
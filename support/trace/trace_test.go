@@ -0,0 +1,163 @@
+package trace_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	big "github.com/filecoin-project/specs-actors/actors/abi/big"
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	power "github.com/filecoin-project/specs-actors/actors/builtin/power"
+	reward "github.com/filecoin-project/specs-actors/actors/builtin/reward"
+	runtime "github.com/filecoin-project/specs-actors/actors/runtime"
+	exitcode "github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	gas "github.com/filecoin-project/specs-actors/actors/runtime/gas"
+	tutil "github.com/filecoin-project/specs-actors/support/testing"
+	trace "github.com/filecoin-project/specs-actors/support/trace"
+	vm "github.com/filecoin-project/specs-actors/support/vm"
+)
+
+func TestRecordCreateMiner(t *testing.T) {
+	ctx := context.Background()
+	v := vm.NewVMWithSingletons(ctx, t)
+	addrs := vm.CreateAccounts(ctx, t, v, 1, big.Mul(big.NewInt(10_000), vm.FIL), 93837778)
+	owner := addrs[0]
+
+	recorder := trace.NewRecorder()
+	v.SetTracer(recorder)
+
+	params := power.CreateMinerParams{
+		Owner:         owner,
+		Worker:        owner,
+		SealProofType: abi.RegisteredSealProof_StackedDrg32GiBV1,
+		Peer:          abi.PeerID("not really a peer id"),
+	}
+
+	t.Run("nested invocations", func(t *testing.T) {
+		vm.ApplyOk(t, v, owner, builtin.StoragePowerActorAddr, vm.FIL, builtin.MethodsPower.CreateMiner, &params)
+		require.Len(t, recorder.Traces(), 1)
+
+		ownerID, found := v.NormalizeAddress(owner)
+		require.True(t, found)
+
+		root := recorder.Last()
+		assert.Equal(t, ownerID, root.Caller)
+		assert.Equal(t, builtin.StoragePowerActorAddr, root.Receiver)
+		assert.Equal(t, builtin.MethodsPower.CreateMiner, root.Method)
+		assert.Equal(t, vm.FIL, root.Value)
+		assert.Equal(t, exitcode.Ok, root.ExitCode)
+		assert.Equal(t, &params, root.Params)
+
+		ret, ok := root.Return.(*power.CreateMinerReturn)
+		require.True(t, ok)
+
+		// power -> init.Exec -> miner.Constructor
+		require.Len(t, root.Subcalls, 1)
+		exec := root.Subcalls[0]
+		assert.Equal(t, builtin.StoragePowerActorAddr, exec.Caller)
+		assert.Equal(t, builtin.InitActorAddr, exec.Receiver)
+		assert.Equal(t, builtin.MethodsInit.Exec, exec.Method)
+		assert.Equal(t, exitcode.Ok, exec.ExitCode)
+
+		require.NotEmpty(t, exec.Subcalls)
+		ctor := exec.Subcalls[0]
+		assert.Equal(t, ret.IDAddress, ctor.Receiver)
+		assert.Equal(t, builtin.MethodConstructor, ctor.Method)
+		assert.Equal(t, exitcode.Ok, ctor.ExitCode)
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		badParams := params
+		badParams.SealProofType = abi.RegisteredSealProof(-1)
+		vm.ApplyCode(t, v, owner, builtin.StoragePowerActorAddr, vm.FIL, builtin.MethodsPower.CreateMiner, &badParams, exitcode.ErrIllegalArgument)
+		require.Len(t, recorder.Traces(), 2)
+
+		root := recorder.Last()
+		assert.Equal(t, exitcode.ErrIllegalArgument, root.ExitCode)
+		assert.Contains(t, root.Abort, "failed to init new actor")
+
+		ctor := root.Subcalls[0].Subcalls[0]
+		assert.Equal(t, exitcode.ErrIllegalArgument, ctor.ExitCode)
+		assert.NotEmpty(t, ctor.Abort)
+	})
+
+	t.Run("abort by caller validation", func(t *testing.T) {
+		rewardParams := reward.AwardBlockRewardParams{Miner: owner, Penalty: big.Zero(), GasReward: big.Zero(), WinCount: 1}
+		vm.ApplyCode(t, v, owner, builtin.RewardActorAddr, big.Zero(), builtin.MethodsReward.AwardBlockReward, &rewardParams, exitcode.SysErrForbidden)
+
+		root := recorder.Last()
+		assert.Equal(t, exitcode.SysErrForbidden, root.ExitCode)
+		assert.Contains(t, root.Abort, "forbidden")
+	})
+
+	t.Run("gas", func(t *testing.T) {
+		recorder.Reset()
+		used, code := v.EstimateGas(owner, builtin.StoragePowerActorAddr, vm.FIL, builtin.MethodsPower.CreateMiner, &params, gas.BlockGasLimit)
+		require.Equal(t, exitcode.Ok, code)
+
+		root := recorder.Last()
+		assert.Equal(t, used, root.GasUsed)
+		exec := root.Subcalls[0]
+		assert.Greater(t, exec.GasUsed, int64(0))
+		assert.Less(t, exec.GasUsed, root.GasUsed)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		buf := bytes.Buffer{}
+		require.NoError(t, recorder.WriteJSON(&buf))
+
+		var decoded []map[string]interface{}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+		require.Len(t, decoded, 1)
+		assert.Equal(t, builtin.StoragePowerActorAddr.String(), decoded[0]["receiver"])
+		assert.NotEmpty(t, decoded[0]["subcalls"])
+	})
+}
+
+func TestSendAbort(t *testing.T) {
+	caller, receiver := tutil.NewIDAddr(t, 100), tutil.NewIDAddr(t, 101)
+	recorder := trace.NewRecorder()
+	recorder.Begin(caller, caller, builtin.MethodSend, big.Zero(), nil, nil)
+
+	rt := trace.NewTracedRuntime(&abortingRuntime{receiver: caller}, recorder)
+	assert.PanicsWithValue(t, runtime.Abort{Code: exitcode.ErrInsufficientFunds, Msg: "no funds"}, func() {
+		rt.Send(receiver, builtin.MethodSend, nil, big.Zero())
+	})
+	recorder.End(nil, exitcode.ErrInsufficientFunds)
+
+	root := recorder.Last()
+	require.Len(t, root.Subcalls, 1)
+	send := root.Subcalls[0]
+	assert.Equal(t, receiver, send.Receiver)
+	assert.Equal(t, exitcode.ErrInsufficientFunds, send.ExitCode)
+	assert.Equal(t, "no funds", send.Abort)
+}
+
+// A runtime whose sends abort before reaching the receiver.
+type abortingRuntime struct {
+	runtime.Runtime
+	receiver addr.Address
+}
+
+func (rt *abortingRuntime) Message() runtime.Message {
+	return &message{receiver: rt.receiver}
+}
+
+func (rt *abortingRuntime) Send(addr.Address, abi.MethodNum, runtime.CBORMarshaler, abi.TokenAmount) (runtime.SendReturn, exitcode.ExitCode) {
+	panic(runtime.Abort{Code: exitcode.ErrInsufficientFunds, Msg: "no funds"})
+}
+
+type message struct {
+	runtime.Message
+	receiver addr.Address
+}
+
+func (m *message) Receiver() addr.Address {
+	return m.receiver
+}
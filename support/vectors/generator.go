@@ -0,0 +1,148 @@
+package vectors
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+
+	addr "github.com/filecoin-project/go-address"
+	"golang.org/x/xerrors"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	crypto "github.com/filecoin-project/specs-actors/actors/crypto"
+	runtime "github.com/filecoin-project/specs-actors/actors/runtime"
+	exitcode "github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	gas "github.com/filecoin-project/specs-actors/actors/runtime/gas"
+	ipld "github.com/filecoin-project/specs-actors/support/ipld"
+	tutil "github.com/filecoin-project/specs-actors/support/testing"
+	vm "github.com/filecoin-project/specs-actors/support/vm"
+)
+
+// A Generator records a test vector from messages applied to a VM.
+// The VM's state when the generator is created becomes the vector's pre-state. Messages applied through the
+// generator are applied to the VM with gas metered up to the block gas limit, and the results recorded as the
+// expected receipts.
+type Generator struct {
+	vm         *vm.VM
+	vector     TestVector
+	randomness *recordingRandomness
+	original   vm.RandomnessSource // The VM's randomness source before recording, restored by Finish.
+}
+
+// Creates a generator recording from the VM's current state, epoch and circulating supply.
+// Until Finish is called, the VM's randomness is drawn through the generator from the VM's source or, if it has
+// none, a source seeded by the vector name.
+func NewGenerator(v *vm.VM, name string) (*Generator, error) {
+	root := v.StateRoot()
+	car := bytes.Buffer{}
+	if err := ipld.ExportCAR(v.Store().Context(), v.Store(), &car, root); err != nil {
+		return nil, xerrors.Errorf("failed to export pre-state: %w", err)
+	}
+
+	original := v.GetRandomness()
+	source := original
+	if source == nil {
+		source = tutil.NewFakeRandomness([]byte(name))
+	}
+	randomness := &recordingRandomness{source: source}
+	v.SetRandomness(randomness)
+
+	return &Generator{
+		vm: v,
+		vector: TestVector{
+			SchemaVersion:     SchemaVersion,
+			Name:              name,
+			PreState:          State{Root: root, CAR: car.Bytes()},
+			Epoch:             v.GetEpoch(),
+			CirculatingSupply: v.GetCirculatingSupply(),
+		},
+		randomness: randomness,
+		original:   original,
+	}, nil
+}
+
+// Applies a message to the VM, recording it and its receipt.
+func (g *Generator) ApplyMessage(from, to addr.Address, value abi.TokenAmount, method abi.MethodNum, params runtime.CBORMarshaler) (runtime.SendReturn, exitcode.ExitCode, error) {
+	msg := Message{From: from, To: to, Value: value, Method: method}
+	if params != nil {
+		buf := bytes.Buffer{}
+		if err := params.MarshalCBOR(&buf); err != nil {
+			return nil, 0, xerrors.Errorf("failed to serialize params: %w", err)
+		}
+		msg.Params = buf.Bytes()
+	}
+
+	ret, code, gasUsed := g.vm.ApplyMeteredMessage(from, to, value, method, params, gas.BlockGasLimit)
+	receipt := Receipt{ExitCode: code, ExecutionGasUsed: gasUsed}
+	if ret != nil {
+		raw := RawBytes{}
+		if err := ret.Into(&raw); err != nil {
+			return nil, 0, xerrors.Errorf("failed to serialize return value: %w", err)
+		}
+		receipt.Return = raw
+	}
+
+	g.vector.Messages = append(g.vector.Messages, msg)
+	g.vector.Receipts = append(g.vector.Receipts, receipt)
+	return ret, code, nil
+}
+
+// Completes the vector with the randomness drawn and the VM's current state root, and restores the VM's
+// original randomness source.
+func (g *Generator) Finish() *TestVector {
+	g.vm.SetRandomness(g.original)
+	vec := g.vector
+	vec.Randomness = append([]RandomnessRecord{}, g.randomness.records...)
+	vec.PostStateRoot = g.vm.StateRoot()
+	return &vec
+}
+
+// RawBytes holds a serialized CBOR value, which it marshals and unmarshals verbatim.
+type RawBytes []byte
+
+func (b RawBytes) MarshalCBOR(w io.Writer) error {
+	_, err := w.Write(b)
+	return err
+}
+
+func (b *RawBytes) UnmarshalCBOR(r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	*b = data
+	return nil
+}
+
+// Records the randomness drawn from another source.
+type recordingRandomness struct {
+	source  vm.RandomnessSource
+	records []RandomnessRecord
+}
+
+func (r *recordingRandomness) GetRandomnessFromBeacon(tag crypto.DomainSeparationTag, epoch abi.ChainEpoch, entropy []byte) abi.Randomness {
+	value := r.source.GetRandomnessFromBeacon(tag, epoch, entropy)
+	r.record(RandomnessBeacon, tag, epoch, entropy, value)
+	return value
+}
+
+func (r *recordingRandomness) GetRandomnessFromTickets(tag crypto.DomainSeparationTag, epoch abi.ChainEpoch, entropy []byte) abi.Randomness {
+	value := r.source.GetRandomnessFromTickets(tag, epoch, entropy)
+	r.record(RandomnessTickets, tag, epoch, entropy, value)
+	return value
+}
+
+func (r *recordingRandomness) record(kind string, tag crypto.DomainSeparationTag, epoch abi.ChainEpoch, entropy []byte, value abi.Randomness) {
+	for _, rec := range r.records {
+		if rec.Kind == kind && rec.Tag == tag && rec.Epoch == epoch && bytes.Equal(rec.Entropy, entropy) {
+			return
+		}
+	}
+	r.records = append(r.records, RandomnessRecord{
+		Kind:    kind,
+		Tag:     tag,
+		Epoch:   epoch,
+		Entropy: append([]byte{}, entropy...),
+		Value:   value,
+	})
+}
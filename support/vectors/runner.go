@@ -0,0 +1,161 @@
+package vectors
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	cid "github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"golang.org/x/xerrors"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	crypto "github.com/filecoin-project/specs-actors/actors/crypto"
+	gas "github.com/filecoin-project/specs-actors/actors/runtime/gas"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+	ipld "github.com/filecoin-project/specs-actors/support/ipld"
+	vm "github.com/filecoin-project/specs-actors/support/vm"
+)
+
+// A Machine applies messages to a state tree. Implementations adapt a VM, backed by some implementation of the
+// actor runtime, to be checked against test vectors.
+type Machine interface {
+	// Applies a message with gas limited to the block gas limit, returning its receipt, which reports only the gas
+	// charged for execution (see Receipt).
+	// An error indicates a failure of the machine itself, rather than of the message.
+	ApplyMessage(msg *Message) (Receipt, error)
+	// Commits all state changes and returns the root of the state tree.
+	StateRoot() (cid.Cid, error)
+}
+
+// Creates a machine operating on the state tree with the given root, at an epoch, providing a circulating supply
+// and randomness from a source to actors.
+type MachineFactory func(ctx context.Context, store adt.Store, root cid.Cid, epoch abi.ChainEpoch, circSupply abi.TokenAmount,
+	randomness vm.RandomnessSource) (Machine, error)
+
+// A MachineFactory for the VM in this repository.
+func NewVMMachine(ctx context.Context, store adt.Store, root cid.Cid, epoch abi.ChainEpoch, circSupply abi.TokenAmount,
+	randomness vm.RandomnessSource) (Machine, error) {
+	v, err := vm.NewVMAtEpoch(ctx, vm.BuiltinActorImpls(), store, root, epoch)
+	if err != nil {
+		return nil, err
+	}
+	v.SetCirculatingSupply(circSupply)
+	v.SetRandomness(randomness)
+	return &vmMachine{vm: v}, nil
+}
+
+type vmMachine struct {
+	vm *vm.VM
+}
+
+func (m *vmMachine) ApplyMessage(msg *Message) (Receipt, error) {
+	var params interface{}
+	if len(msg.Params) > 0 {
+		params = RawBytes(msg.Params)
+	}
+	ret, code, gasUsed := m.vm.ApplyMeteredMessage(msg.From, msg.To, msg.Value, msg.Method, params, gas.BlockGasLimit)
+	receipt := Receipt{ExitCode: code, ExecutionGasUsed: gasUsed}
+	if ret != nil {
+		raw := RawBytes{}
+		if err := ret.Into(&raw); err != nil {
+			return Receipt{}, err
+		}
+		receipt.Return = raw
+	}
+	return receipt, nil
+}
+
+func (m *vmMachine) StateRoot() (cid.Cid, error) {
+	return m.vm.StateRoot(), nil
+}
+
+// Runs a test vector against a machine, returning an error describing the first divergence from the expected
+// results.
+func Run(ctx context.Context, vec *TestVector, factory MachineFactory) (err error) {
+	bs := ipld.NewBlockStoreInMemory()
+	roots, err := ipld.ImportCAR(bs, bytes.NewReader(vec.PreState.CAR))
+	if err != nil {
+		return xerrors.Errorf("failed to import pre-state: %w", err)
+	}
+	if len(roots) != 1 || !roots[0].Equals(vec.PreState.Root) {
+		return xerrors.Errorf("pre-state CAR roots %v do not match %s", roots, vec.PreState.Root)
+	}
+	root := roots[0]
+	store := adt.WrapStore(ctx, cbor.NewCborStore(bs))
+
+	// A request for randomness that was not recorded panics, since the runtime interface has no means to fail.
+	defer func() {
+		if r := recover(); r != nil {
+			missing, ok := r.(missingRandomness)
+			if !ok {
+				panic(r)
+			}
+			err = missing
+		}
+	}()
+
+	m, err := factory(ctx, store, root, vec.Epoch, vec.CirculatingSupply, &replayRandomness{records: vec.Randomness})
+	if err != nil {
+		return xerrors.Errorf("failed to create machine: %w", err)
+	}
+
+	for i := range vec.Messages {
+		receipt, err := m.ApplyMessage(&vec.Messages[i])
+		if err != nil {
+			return xerrors.Errorf("failed to apply message %d: %w", i, err)
+		}
+		expected := vec.Receipts[i]
+		if receipt.ExitCode != expected.ExitCode {
+			return xerrors.Errorf("message %d exit code %s, expected %s", i, receipt.ExitCode, expected.ExitCode)
+		}
+		if !bytes.Equal(receipt.Return, expected.Return) {
+			return xerrors.Errorf("message %d return %x, expected %x", i, receipt.Return, expected.Return)
+		}
+		if receipt.ExecutionGasUsed != expected.ExecutionGasUsed {
+			return xerrors.Errorf("message %d used execution gas %d, expected %d", i, receipt.ExecutionGasUsed, expected.ExecutionGasUsed)
+		}
+	}
+
+	postRoot, err := m.StateRoot()
+	if err != nil {
+		return xerrors.Errorf("failed to flush state: %w", err)
+	}
+	if !postRoot.Equals(vec.PostStateRoot) {
+		return xerrors.Errorf("post-state root %s, expected %s", postRoot, vec.PostStateRoot)
+	}
+	return nil
+}
+
+// Provides the randomness recorded in a vector.
+type replayRandomness struct {
+	records []RandomnessRecord
+}
+
+type missingRandomness struct {
+	kind    string
+	tag     crypto.DomainSeparationTag
+	epoch   abi.ChainEpoch
+	entropy []byte
+}
+
+func (m missingRandomness) Error() string {
+	return fmt.Sprintf("no %s randomness recorded for tag %d, epoch %d, entropy %x", m.kind, m.tag, m.epoch, m.entropy)
+}
+
+func (r *replayRandomness) GetRandomnessFromBeacon(tag crypto.DomainSeparationTag, epoch abi.ChainEpoch, entropy []byte) abi.Randomness {
+	return r.lookup(RandomnessBeacon, tag, epoch, entropy)
+}
+
+func (r *replayRandomness) GetRandomnessFromTickets(tag crypto.DomainSeparationTag, epoch abi.ChainEpoch, entropy []byte) abi.Randomness {
+	return r.lookup(RandomnessTickets, tag, epoch, entropy)
+}
+
+func (r *replayRandomness) lookup(kind string, tag crypto.DomainSeparationTag, epoch abi.ChainEpoch, entropy []byte) abi.Randomness {
+	for _, rec := range r.records {
+		if rec.Kind == kind && rec.Tag == tag && rec.Epoch == epoch && bytes.Equal(rec.Entropy, entropy) {
+			return rec.Value
+		}
+	}
+	panic(missingRandomness{kind: kind, tag: tag, epoch: epoch, entropy: entropy})
+}
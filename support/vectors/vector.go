@@ -0,0 +1,98 @@
+package vectors
+
+import (
+	"encoding/json"
+	"io"
+
+	addr "github.com/filecoin-project/go-address"
+	cid "github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	crypto "github.com/filecoin-project/specs-actors/actors/crypto"
+	exitcode "github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+)
+
+// The version of the test vector schema. Readers reject vectors with a different version.
+const SchemaVersion = 1
+
+// A TestVector records the application of a sequence of messages to a state tree, and the results expected of
+// any conforming implementation.
+type TestVector struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Name          string `json:"name"`
+
+	// The state tree to which messages are applied.
+	PreState State `json:"preState"`
+	// The epoch at which messages are applied.
+	Epoch abi.ChainEpoch `json:"epoch"`
+	// The circulating supply provided to actors while applying the messages.
+	CirculatingSupply abi.TokenAmount `json:"circulatingSupply"`
+	// The randomness provided to actors while applying the messages.
+	Randomness []RandomnessRecord `json:"randomness"`
+
+	Messages []Message `json:"messages"`
+	// The expected receipt for each message.
+	Receipts []Receipt `json:"receipts"`
+	// The expected root of the state tree after all messages have been applied.
+	PostStateRoot cid.Cid `json:"postStateRoot"`
+}
+
+// A state tree, as a CAR file containing every block reachable from the root.
+type State struct {
+	Root cid.Cid `json:"root"`
+	CAR  []byte  `json:"car"`
+}
+
+type Message struct {
+	From   addr.Address    `json:"from"`
+	To     addr.Address    `json:"to"`
+	Value  abi.TokenAmount `json:"value"`
+	Method abi.MethodNum   `json:"method"`
+	Params []byte          `json:"params"` // CBOR-encoded, empty for no params.
+}
+
+type Receipt struct {
+	ExitCode exitcode.ExitCode `json:"exitCode"`
+	Return   []byte            `json:"return"` // CBOR-encoded, empty for no return value.
+	// The gas charged for executing the message, which is less than the gas used of a node's receipt.
+	// It excludes the gas.Pricelist OnChainMessage charge, which depends on the signed serialization of the
+	// message, and the OnChainReturnValue charge, which depends on the length of the return value.
+	ExecutionGasUsed int64 `json:"executionGasUsed"`
+}
+
+const (
+	RandomnessBeacon  = "beacon"
+	RandomnessTickets = "tickets"
+)
+
+// A request for randomness, and the value provided.
+type RandomnessRecord struct {
+	Kind    string                     `json:"kind"` // RandomnessBeacon or RandomnessTickets.
+	Tag     crypto.DomainSeparationTag `json:"tag"`
+	Epoch   abi.ChainEpoch             `json:"epoch"`
+	Entropy []byte                     `json:"entropy"`
+	Value   abi.Randomness             `json:"value"`
+}
+
+// Writes a vector as JSON.
+func Write(w io.Writer, v *TestVector) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// Reads a vector written by Write.
+func Read(r io.Reader) (*TestVector, error) {
+	var v TestVector
+	if err := json.NewDecoder(r).Decode(&v); err != nil {
+		return nil, err
+	}
+	if v.SchemaVersion != SchemaVersion {
+		return nil, xerrors.Errorf("unsupported schema version %d, expected %d", v.SchemaVersion, SchemaVersion)
+	}
+	if len(v.Receipts) != len(v.Messages) {
+		return nil, xerrors.Errorf("vector has %d messages but %d receipts", len(v.Messages), len(v.Receipts))
+	}
+	return &v, nil
+}
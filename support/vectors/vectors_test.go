@@ -0,0 +1,122 @@
+package vectors_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	big "github.com/filecoin-project/specs-actors/actors/abi/big"
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	power "github.com/filecoin-project/specs-actors/actors/builtin/power"
+	exitcode "github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+	tutil "github.com/filecoin-project/specs-actors/support/testing"
+	"github.com/filecoin-project/specs-actors/support/vectors"
+	vm "github.com/filecoin-project/specs-actors/support/vm"
+)
+
+func TestGenerateAndRun(t *testing.T) {
+	ctx := context.Background()
+	v := vm.NewVMWithSingletons(ctx, t)
+	addrs := vm.CreateAccounts(ctx, t, v, 2, big.Mul(big.NewInt(10_000), vm.FIL), 93837778)
+	owner, other := addrs[0], addrs[1]
+	circSupply := big.Mul(big.NewInt(1e9), vm.FIL)
+	v.SetCirculatingSupply(circSupply)
+	require.Nil(t, v.GetRandomness())
+
+	gen, err := vectors.NewGenerator(v, "transfer and create miner")
+	require.NoError(t, err)
+
+	_, code, err := gen.ApplyMessage(owner, other, vm.FIL, builtin.MethodSend, nil)
+	require.NoError(t, err)
+	assert.Equal(t, exitcode.Ok, code)
+
+	_, code, err = gen.ApplyMessage(owner, other, big.Mul(big.NewInt(1e6), vm.FIL), builtin.MethodSend, nil)
+	require.NoError(t, err)
+	assert.Equal(t, exitcode.SysErrInsufficientFunds, code)
+
+	params := power.CreateMinerParams{
+		Owner:         owner,
+		Worker:        owner,
+		SealProofType: abi.RegisteredSealProof_StackedDrg32GiBV1,
+		Peer:          abi.PeerID("not really a peer id"),
+	}
+	ret, code, err := gen.ApplyMessage(owner, builtin.StoragePowerActorAddr, big.Zero(), builtin.MethodsPower.CreateMiner, &params)
+	require.NoError(t, err)
+	require.Equal(t, exitcode.Ok, code)
+	var minerAddrs power.CreateMinerReturn
+	require.NoError(t, ret.Into(&minerAddrs))
+
+	vec := gen.Finish()
+	assert.Nil(t, v.GetRandomness(), "original randomness source restored")
+	assert.Equal(t, circSupply, vec.CirculatingSupply)
+	require.Len(t, vec.Receipts, 3)
+	assert.NotEmpty(t, vec.Receipts[2].Return)
+	for _, receipt := range vec.Receipts {
+		assert.True(t, receipt.ExecutionGasUsed > 0)
+	}
+
+	// round trip through JSON
+	buf := bytes.Buffer{}
+	require.NoError(t, vectors.Write(&buf, vec))
+	vec, err = vectors.Read(&buf)
+	require.NoError(t, err)
+
+	t.Run("vector passes against the VM", func(t *testing.T) {
+		require.NoError(t, vectors.Run(ctx, vec, vectors.NewVMMachine))
+	})
+
+	t.Run("machine is provided the circulating supply", func(t *testing.T) {
+		var provided abi.TokenAmount
+		factory := func(ctx context.Context, store adt.Store, root cid.Cid, epoch abi.ChainEpoch, supply abi.TokenAmount,
+			randomness vm.RandomnessSource) (vectors.Machine, error) {
+			provided = supply
+			return vectors.NewVMMachine(ctx, store, root, epoch, supply, randomness)
+		}
+		require.NoError(t, vectors.Run(ctx, vec, factory))
+		assert.Equal(t, circSupply, provided)
+	})
+
+	t.Run("divergent exit code fails", func(t *testing.T) {
+		tampered := *vec
+		tampered.Receipts = append([]vectors.Receipt{}, vec.Receipts...)
+		tampered.Receipts[1].ExitCode = exitcode.Ok
+		err := vectors.Run(ctx, &tampered, vectors.NewVMMachine)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "message 1 exit code")
+	})
+
+	t.Run("divergent gas fails", func(t *testing.T) {
+		tampered := *vec
+		tampered.Receipts = append([]vectors.Receipt{}, vec.Receipts...)
+		tampered.Receipts[0].ExecutionGasUsed++
+		err := vectors.Run(ctx, &tampered, vectors.NewVMMachine)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "message 0 used execution gas")
+	})
+
+	t.Run("divergent post-state fails", func(t *testing.T) {
+		tampered := *vec
+		tampered.PostStateRoot = vec.PreState.Root
+		err := vectors.Run(ctx, &tampered, vectors.NewVMMachine)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "post-state root")
+	})
+}
+
+func TestGeneratorRestoresRandomness(t *testing.T) {
+	v := vm.NewVMWithSingletons(context.Background(), t)
+	source := tutil.NewFakeRandomness([]byte("seed"))
+	v.SetRandomness(source)
+
+	gen, err := vectors.NewGenerator(v, "empty")
+	require.NoError(t, err)
+	assert.NotEqual(t, source, v.GetRandomness())
+	gen.Finish()
+	assert.Equal(t, source, v.GetRandomness())
+}
@@ -0,0 +1,763 @@
+package vm
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	addr "github.com/filecoin-project/go-address"
+	cid "github.com/ipfs/go-cid"
+	"github.com/minio/blake2b-simd"
+	"github.com/pkg/errors"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	big "github.com/filecoin-project/specs-actors/actors/abi/big"
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	init_ "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	"github.com/filecoin-project/specs-actors/actors/crypto"
+	runtime "github.com/filecoin-project/specs-actors/actors/runtime"
+	checked "github.com/filecoin-project/specs-actors/actors/runtime/checked"
+	exitcode "github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	gas "github.com/filecoin-project/specs-actors/actors/runtime/gas"
+	states "github.com/filecoin-project/specs-actors/actors/states"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+	commp "github.com/filecoin-project/specs-actors/support/commp"
+	trace "github.com/filecoin-project/specs-actors/support/trace"
+)
+
+// Context for an individual message invocation, including inter-actor sends.
+type invocationContext struct {
+	rt               *VM
+	topLevel         *topLevelContext
+	msg              InternalMessage // The message being processed
+	fromActor        *states.Actor   // The immediate calling actor
+	toActor          *states.Actor   // The actor to which message is addressed
+	emptyObject      cid.Cid
+	allowSideEffects bool
+}
+
+// Context for a top-level invocation sequence
+type topLevelContext struct {
+	originatorStableAddress addr.Address  // Stable (public key) address of the top-level message sender.
+	originatorCallSeq       uint64        // Call sequence number of the top-level message.
+	newActorAddressCount    uint64        // Count of calls to NewActorAddress (mutable).
+	gasTracker              *gas.Tracker  // Gas charged by the invocation sequence, or nil if gas is not metered.
+	pricelist               gas.Pricelist // Prices of operations, if gas is metered.
+}
+
+// An internal message is a message between actors, or the top-level message sent to the VM.
+type InternalMessage struct {
+	from   addr.Address
+	to     addr.Address
+	value  abi.TokenAmount
+	method abi.MethodNum
+	params interface{}
+}
+
+var _ runtime.Message = (*InternalMessage)(nil)
+
+func (msg InternalMessage) Caller() addr.Address {
+	return msg.from
+}
+
+func (msg InternalMessage) Receiver() addr.Address {
+	return msg.to
+}
+
+func (msg InternalMessage) ValueReceived() abi.TokenAmount {
+	return msg.value
+}
+
+func newInvocationContext(rt *VM, topLevel *topLevelContext, msg InternalMessage, fromActor *states.Actor, emptyObject cid.Cid) invocationContext {
+	// Note: the toActor and stateHandle are loaded during the `invoke()`
+	return invocationContext{
+		rt:               rt,
+		topLevel:         topLevel,
+		msg:              msg,
+		fromActor:        fromActor,
+		toActor:          nil,
+		emptyObject:      emptyObject,
+		allowSideEffects: true,
+	}
+}
+
+var _ runtime.StateHandle = &invocationContext{}
+
+func (ic *invocationContext) loadState(obj runtime.CBORUnmarshaler) cid.Cid {
+	// The actor must be loaded from store every time since the state may have changed via a different state handle
+	// (e.g. in a recursive call).
+	actr := ic.loadActor()
+	c := actr.Head
+	if !c.Defined() {
+		ic.Abortf(exitcode.SysErrorIllegalActor, "failed to load undefined state, must construct first")
+	}
+	err := ic.rt.store.Get(ic.rt.ctx, c, obj)
+	if err != nil {
+		panic(errors.Wrapf(err, "failed to load state for actor %s, CID %s", ic.msg.to, c))
+	}
+	return c
+}
+
+func (ic *invocationContext) loadActor() *states.Actor {
+	actr, found, err := ic.rt.GetActor(ic.msg.to)
+	if err != nil {
+		panic(err)
+	}
+	if !found {
+		panic(fmt.Errorf("failed to find actor %s for state", ic.msg.to))
+	}
+	return actr
+}
+
+func (ic *invocationContext) storeActor(actr *states.Actor) {
+	err := ic.rt.SetActor(ic.rt.ctx, ic.msg.to, actr)
+	if err != nil {
+		panic(err)
+	}
+}
+
+/////////////////////////////////////////////
+//          Runtime methods
+/////////////////////////////////////////////
+
+var _ runtime.Runtime = (*invocationContext)(nil)
+
+// Store implements runtime.Runtime.
+func (ic *invocationContext) Store() runtime.Store {
+	return ic
+}
+
+func (ic *invocationContext) Message() runtime.Message {
+	return ic.msg
+}
+
+func (ic *invocationContext) CurrEpoch() abi.ChainEpoch {
+	return ic.rt.currentEpoch
+}
+
+func (ic *invocationContext) ValidateImmediateCallerAcceptAny() {
+	// any caller is acceptable
+}
+
+func (ic *invocationContext) ValidateImmediateCallerIs(addrs ...addr.Address) {
+	for _, a := range addrs {
+		if a == ic.msg.from {
+			return
+		}
+	}
+	ic.Abortf(exitcode.SysErrForbidden, "caller address %v forbidden, allowed: %v", ic.msg.from, addrs)
+}
+
+func (ic *invocationContext) ValidateImmediateCallerType(types ...cid.Cid) {
+	for _, t := range types {
+		if t.Equals(ic.fromActor.Code) {
+			return
+		}
+	}
+	ic.Abortf(exitcode.SysErrForbidden, "caller type %v forbidden, allowed: %v", ic.fromActor.Code, types)
+}
+
+func (ic *invocationContext) CurrentBalance() abi.TokenAmount {
+	// load balance
+	act, found, err := ic.rt.GetActor(ic.msg.to)
+	if err != nil {
+		panic(err)
+	}
+	if !found {
+		panic(fmt.Errorf("failed to find actor %s for balance", ic.msg.to))
+	}
+	return act.Balance
+}
+
+func (ic *invocationContext) ResolveAddress(address addr.Address) (addr.Address, bool) {
+	return ic.rt.NormalizeAddress(address)
+}
+
+func (ic *invocationContext) GetActorCodeCID(a addr.Address) (ret cid.Cid, ok bool) {
+	entry, found, err := ic.rt.GetActor(a)
+	if err != nil {
+		panic(err)
+	}
+	if !found {
+		return cid.Undef, false
+	}
+	return entry.Code, true
+}
+
+func (ic *invocationContext) GetRandomnessFromBeacon(tag crypto.DomainSeparationTag, epoch abi.ChainEpoch, entropy []byte) abi.Randomness {
+	if ic.rt.randomness == nil {
+		return []byte("not really random")
+	}
+	return ic.rt.randomness.GetRandomnessFromBeacon(tag, epoch, entropy)
+}
+
+func (ic *invocationContext) GetRandomnessFromTickets(tag crypto.DomainSeparationTag, epoch abi.ChainEpoch, entropy []byte) abi.Randomness {
+	if ic.rt.randomness == nil {
+		return []byte("not really random")
+	}
+	return ic.rt.randomness.GetRandomnessFromTickets(tag, epoch, entropy)
+}
+
+func (ic *invocationContext) State() runtime.StateHandle {
+	return ic
+}
+
+func (ic *invocationContext) Send(toAddr addr.Address, methodNum abi.MethodNum, params runtime.CBORMarshaler, value abi.TokenAmount) (runtime.SendReturn, exitcode.ExitCode) {
+	// check if side-effects are allowed
+	if !ic.allowSideEffects {
+		ic.Abortf(exitcode.SysErrorIllegalActor, "Calling Send() is not allowed during side-effect lock")
+	}
+	from := ic.msg.to
+	fromActor := ic.loadActor()
+
+	newMsg := InternalMessage{
+		from:   from,
+		to:     toAddr,
+		value:  value,
+		method: methodNum,
+		params: params,
+	}
+
+	newCtx := newInvocationContext(ic.rt, ic.topLevel, newMsg, fromActor, ic.emptyObject)
+	return newCtx.invokeWithRollback()
+}
+
+func (ic *invocationContext) Abortf(errExitCode exitcode.ExitCode, msg string, args ...interface{}) {
+	runtime.Abortf(errExitCode, msg, args...)
+}
+
+func (ic *invocationContext) NewActorAddress() addr.Address {
+	var buf bytes.Buffer
+
+	b1, err := ic.topLevel.originatorStableAddress.Marshal()
+	if err != nil {
+		panic(err)
+	}
+	_, err = buf.Write(b1)
+	if err != nil {
+		panic(err)
+	}
+
+	err = binary.Write(&buf, binary.BigEndian, ic.topLevel.originatorCallSeq)
+	if err != nil {
+		panic(err)
+	}
+
+	err = binary.Write(&buf, binary.BigEndian, ic.topLevel.newActorAddressCount)
+	if err != nil {
+		panic(err)
+	}
+
+	actorAddress, err := addr.NewActorAddress(buf.Bytes())
+	if err != nil {
+		panic(err)
+	}
+	ic.topLevel.newActorAddressCount++
+	return actorAddress
+}
+
+func (ic *invocationContext) CreateActor(codeID cid.Cid, addr addr.Address) {
+	if !builtin.IsBuiltinActor(codeID) {
+		ic.Abortf(exitcode.SysErrorIllegalArgument, "Can only create built-in actors.")
+	}
+
+	if builtin.IsSingletonActor(codeID) {
+		ic.Abortf(exitcode.SysErrorIllegalArgument, "Can only have one instance of singleton actors.")
+	}
+
+	ic.rt.Log(runtime.WARN, "creating actor, friendly-name: %s, code: %s, addr: %s\n", builtin.ActorNameByCode(codeID), codeID, addr)
+
+	// Check existing address. If nothing there, create empty actor.
+	//
+	// Note: we are storing the actors by ActorID *address*
+	_, found, err := ic.rt.GetActor(addr)
+	if err != nil {
+		panic(err)
+	}
+	if found {
+		ic.Abortf(exitcode.SysErrorIllegalArgument, "Actor address already exists")
+	}
+
+	newActor := &states.Actor{
+		Head:    ic.emptyObject,
+		Code:    codeID,
+		Balance: abi.NewTokenAmount(0),
+	}
+	if err := ic.rt.SetActor(ic.rt.ctx, addr, newActor); err != nil {
+		panic(err)
+	}
+}
+
+// deleteActor deletes the executing actor from the state tree, transferring any balance to beneficiary.
+// Aborts if the beneficiary does not exist.
+// May only be called by the actor itself.
+func (ic *invocationContext) DeleteActor(beneficiary addr.Address) {
+	receiver := ic.msg.to
+	receiverActor, found, err := ic.rt.GetActor(receiver)
+	if err != nil {
+		panic(err)
+	}
+	if !found {
+		ic.Abortf(exitcode.SysErrorIllegalActor, "delete non-existent actor %v", receiver)
+	}
+
+	beneficiaryID, found := ic.rt.NormalizeAddress(beneficiary)
+	if found {
+		_, found, err = ic.rt.GetActor(beneficiaryID)
+		if err != nil {
+			panic(err)
+		}
+	}
+	if !found {
+		ic.Abortf(exitcode.SysErrorIllegalActor, "beneficiary %v of deleted actor %v does not exist", beneficiary, receiver)
+	}
+
+	// Transfer any remaining balance to the beneficiary.
+	// This looks like it could cause a problem with gas refund going to a non-existent actor, but the gas payer
+	// is always an account actor, which cannot be the receiver of this message.
+	if receiverActor.Balance.GreaterThan(big.Zero()) {
+		ic.rt.transfer(receiver, beneficiaryID, receiverActor.Balance)
+	}
+
+	if err := ic.rt.deleteActor(ic.rt.ctx, receiver); err != nil {
+		panic(err)
+	}
+}
+
+func (ic *invocationContext) TotalFilCircSupply() abi.TokenAmount {
+	return ic.rt.circulatingSupply
+}
+
+func (ic *invocationContext) Context() context.Context {
+	return ic.rt.ctx
+}
+
+func (ic *invocationContext) StartSpan(_ string) runtime.TraceSpan {
+	return &fakeTraceSpan{}
+}
+
+func (ic *invocationContext) ChargeGas(_ string, _ int64, _ int64) {
+	// no-op
+}
+
+func (ic *invocationContext) Log(level runtime.LogLevel, msg string, args ...interface{}) {
+	ic.rt.Log(level, msg, args...)
+}
+
+type returnWrapper struct {
+	inner runtime.CBORMarshaler
+}
+
+// Implements trace.ReturnValuer.
+func (r returnWrapper) ReturnValue() interface{} {
+	return r.inner
+}
+
+func (r returnWrapper) Into(o runtime.CBORUnmarshaler) error {
+	if r.inner == nil {
+		return fmt.Errorf("failed to unmarshal nil return (did you mean adt.Empty?)")
+	}
+	b := bytes.Buffer{}
+	if err := r.inner.MarshalCBOR(&b); err != nil {
+		return err
+	}
+	return o.UnmarshalCBOR(&b)
+}
+
+/////////////////////////////////////////////
+//          Store methods
+/////////////////////////////////////////////
+
+func (ic *invocationContext) Get(c cid.Cid, o runtime.CBORUnmarshaler) bool {
+	// A real VM would map the CID to a block, then check existence of the block.
+	// This is done via a direct Get, which will fail if the block is not found.
+	err := ic.rt.store.Get(ic.rt.ctx, c, o)
+	// assume all errors are not found errors (bad assumption, but ok for testing)
+	return err == nil
+}
+
+func (ic *invocationContext) Put(x runtime.CBORMarshaler) cid.Cid {
+	c, err := ic.rt.store.Put(ic.rt.ctx, x)
+	if err != nil {
+		ic.Abortf(exitcode.SysErrSerialization, "could not put object in store: %s", err)
+	}
+	return c
+}
+
+/////////////////////////////////////////////
+//          State handle methods
+/////////////////////////////////////////////
+
+func (ic *invocationContext) Create(obj runtime.CBORMarshaler) {
+	actr := ic.loadActor()
+	if !actr.Head.Equals(ic.emptyObject) {
+		ic.Abortf(exitcode.SysErrorIllegalActor, "failed to construct actor state: already initialized")
+	}
+	c, err := ic.rt.store.Put(ic.rt.ctx, obj)
+	if err != nil {
+		ic.Abortf(exitcode.ErrIllegalState, "failed to create actor state")
+	}
+	actr.Head = c
+	ic.storeActor(actr)
+}
+
+// Readonly is the implementation of the ActorStateHandle interface.
+func (ic *invocationContext) Readonly(obj runtime.CBORUnmarshaler) {
+	// Load state to obj.
+	ic.loadState(obj)
+}
+
+// Transaction is the implementation of the ActorStateHandle interface.
+func (ic *invocationContext) Transaction(obj runtime.CBORer, f func()) {
+	if obj == nil {
+		ic.Abortf(exitcode.SysErrorIllegalActor, "Must not pass nil to Transaction()")
+	}
+
+	// Load state to obj.
+	ic.loadState(obj)
+
+	// Call user code allowing mutation but not side-effects
+	ic.allowSideEffects = false
+	f()
+	ic.allowSideEffects = true
+
+	ic.replace(obj)
+}
+
+func (ic *invocationContext) replace(obj runtime.CBORMarshaler) cid.Cid {
+	actr, found, err := ic.rt.GetActor(ic.msg.to)
+	if err != nil {
+		panic(err)
+	}
+	if !found {
+		ic.Abortf(exitcode.ErrIllegalState, "failed to find actor %s for state", ic.msg.to)
+	}
+	c, err := ic.rt.store.Put(ic.rt.ctx, obj)
+	if err != nil {
+		ic.Abortf(exitcode.ErrIllegalState, "could not save new state")
+	}
+	actr.Head = c
+	err = ic.rt.SetActor(ic.rt.ctx, ic.msg.to, actr)
+	if err != nil {
+		ic.Abortf(exitcode.ErrIllegalState, "could not save actor %s", ic.msg.to)
+	}
+	return c
+}
+
+/////////////////////////////////////////////
+//          Syscalls
+/////////////////////////////////////////////
+
+var _ runtime.Syscalls = (*invocationContext)(nil)
+
+func (ic *invocationContext) Syscalls() runtime.Syscalls {
+	return ic
+}
+
+func (ic *invocationContext) VerifySignature(_ crypto.Signature, _ addr.Address, _ []byte) error {
+	return nil
+}
+
+func (ic *invocationContext) HashBlake2b(data []byte) [32]byte {
+	return blake2b.Sum256(data)
+}
+
+func (ic *invocationContext) ComputeUnsealedSectorCID(reg abi.RegisteredSealProof, pieces []abi.PieceInfo) (cid.Cid, error) {
+	return commp.ComputeUnsealedSectorCID(reg, pieces)
+}
+
+func (ic *invocationContext) VerifySeal(_ abi.SealVerifyInfo) error {
+	return nil
+}
+
+func (ic *invocationContext) BatchVerifySeals(vis map[addr.Address][]abi.SealVerifyInfo) (map[addr.Address][]bool, error) {
+	res := map[addr.Address][]bool{}
+	for addr, infos := range vis { //nolint:nomaprange
+		verified := make([]bool, len(infos))
+		for i := range infos {
+			verified[i] = true
+		}
+		res[addr] = verified
+	}
+	return res, nil
+}
+
+func (ic *invocationContext) VerifyPoSt(_ abi.WindowPoStVerifyInfo) error {
+	return nil
+}
+
+func (ic *invocationContext) VerifyConsensusFault(_, _, _ []byte) (*runtime.ConsensusFault, error) {
+	return nil, fmt.Errorf("consensus faults are not supported by the test VM")
+}
+
+/////////////////////////////////////////////
+//          Invocation
+/////////////////////////////////////////////
+
+// Invokes the message, rolling back the actor state tree to its prior state if the invocation fails.
+func (ic *invocationContext) invokeWithRollback() (runtime.SendReturn, exitcode.ExitCode) {
+	priorRoot, err := ic.rt.checkpoint()
+	if err != nil {
+		panic(err)
+	}
+
+	ret, code := ic.invoke()
+	if code != exitcode.Ok {
+		if err := ic.rt.rollback(priorRoot); err != nil {
+			panic(err)
+		}
+	}
+	return ret, code
+}
+
+// Resolves the receiver, transfers value and dispatches the method, recovering any abort into an exit code.
+func (ic *invocationContext) invoke() (ret runtime.SendReturn, errcode exitcode.ExitCode) {
+	// recover from panics, translating aborts to exit codes
+	defer func() {
+		if r := recover(); r != nil {
+			if a, ok := r.(runtime.Abort); ok {
+				ic.rt.Log(runtime.WARN, "abort: %s", a)
+				if ic.rt.tracer != nil {
+					ic.rt.tracer.Abort(a.Msg)
+				}
+				ret = returnWrapper{adt.Empty}
+				errcode = a.Code
+				return
+			}
+			// refuse to handle a non-abort panic
+			panic(r)
+		}
+	}()
+
+	// pre-dispatch
+	// 1. load and validate receiver
+	// 2. transfer funds carried by the msg
+	// 3. if method number is 0 (send), return early
+
+	// 1. load target actor, creating an account actor for a public key address if necessary
+	var toAddr addr.Address
+	ic.toActor, toAddr = ic.resolveTarget(ic.msg.to)
+
+	// normalize the receiver now that it is known to exist
+	ic.msg.to = toAddr
+
+	// 2. transfer funds carried by the msg
+	if !ic.msg.value.Nil() && !ic.msg.value.IsZero() {
+		if ic.msg.value.LessThan(big.Zero()) {
+			ic.Abortf(exitcode.SysErrForbidden, "attempt to transfer negative value %s from %s to %s",
+				ic.msg.value, ic.msg.from, ic.msg.to)
+		}
+		if ic.fromActor.Balance.LessThan(ic.msg.value) {
+			ic.Abortf(exitcode.SysErrInsufficientFunds, "sender %s insufficient balance %s to transfer %s to %s",
+				ic.msg.from, ic.fromActor.Balance, ic.msg.value, ic.msg.to)
+		}
+		ic.toActor, ic.fromActor = ic.rt.transfer(ic.msg.from, ic.msg.to, ic.msg.value)
+	}
+
+	// 3. if method number is 0 (send), return early
+	if ic.msg.method == builtin.MethodSend {
+		return returnWrapper{adt.Empty}, exitcode.Ok
+	}
+
+	// dispatch
+	out := ic.dispatch(ic.toActor.Code, ic.msg.method, ic.msg.params)
+	return returnWrapper{out}, exitcode.Ok
+}
+
+// Serializes the params and invokes the method of the actor with the given code, returning its result.
+// An abort by the method, or its violation of the rules enforced by checked.Runtime, is raised in this context.
+func (ic *invocationContext) dispatch(code cid.Cid, method abi.MethodNum, params interface{}) runtime.CBORMarshaler {
+	// Round-trip the parameters through their serialized form so that in-process values can't leak between actors.
+	buf := bytes.Buffer{}
+	if params != nil {
+		marshaler, ok := params.(runtime.CBORMarshaler)
+		if !ok {
+			ic.Abortf(exitcode.SysErrInvalidParameters, "params of type %T are not CBOR-marshalable", params)
+		}
+		if err := marshaler.MarshalCBOR(&buf); err != nil {
+			ic.Abortf(exitcode.SysErrInvalidParameters, "failed to marshal params: %s", err)
+		}
+	}
+
+	checkedRt := checked.NewRuntime(ic)
+	var rt runtime.Runtime = checkedRt
+	if ic.rt.tracer != nil {
+		rt = trace.NewTracedRuntime(rt, ic.rt.tracer)
+	}
+	if ic.topLevel.gasTracker != nil {
+		rt = gas.NewMeteredRuntime(rt, ic.topLevel.pricelist, ic.topLevel.gasTracker)
+	}
+
+	if scoped, ok := ic.rt.store.(MethodScopedStore); ok {
+		defer scoped.EnterMethod(code, method)()
+	}
+	result := ic.rt.invoker.Invoke(rt, code, method, buf.Bytes())
+	if result.ExitCode != exitcode.Ok {
+		ic.Abortf(result.ExitCode, "%s", result.Message)
+	}
+	checkedRt.CheckReturn()
+	return result.Value
+}
+
+// Loads the actor at the target address, creating an account actor if the address is an unknown public key address.
+func (ic *invocationContext) resolveTarget(target addr.Address) (*states.Actor, addr.Address) {
+	// ID addresses need no resolution, and may be the target of messages before the init actor is constructed.
+	if target.Protocol() == addr.ID {
+		targetActor, found, err := ic.rt.getActorByID(target)
+		if err != nil {
+			panic(err)
+		}
+		if !found {
+			ic.Abortf(exitcode.SysErrInvalidReceiver, "actor at address %s not found", target)
+		}
+		return targetActor, target
+	}
+
+	// resolve the target address via the InitActor, and attempt to load state.
+	initActorEntry, found, err := ic.rt.GetActor(builtin.InitActorAddr)
+	if err != nil {
+		panic(err)
+	}
+	if !found {
+		ic.Abortf(exitcode.SysErrSenderInvalid, "init actor not found")
+	}
+
+	// get a view into the actor state
+	var state init_.State
+	if err := ic.rt.store.Get(ic.rt.ctx, initActorEntry.Head, &state); err != nil {
+		panic(err)
+	}
+
+	// lookup the ActorID based on the address
+	targetIDAddr, err := state.ResolveAddress(ic.rt.store, target)
+	created := false
+	if err == init_.ErrAddressNotFound {
+		// Dragons: the message may be sent to an address that doesn't exist yet, in which case an account
+		// actor is implicitly created if it is a public key address.
+		if target.Protocol() != addr.SECP256K1 && target.Protocol() != addr.BLS {
+			// Don't implicitly create an account actor for an address without an associated key.
+			ic.Abortf(exitcode.SysErrInvalidReceiver, "cannot send to unknown address %s", target)
+		}
+
+		targetIDAddr, err = state.MapAddressToNewID(ic.rt.store, target)
+		if err != nil {
+			panic(err)
+		}
+		// store new state
+		initHead, err := ic.rt.store.Put(ic.rt.ctx, &state)
+		if err != nil {
+			panic(err)
+		}
+		initActorEntry.Head = initHead
+		if err := ic.rt.SetActor(ic.rt.ctx, builtin.InitActorAddr, initActorEntry); err != nil {
+			panic(err)
+		}
+		created = true
+	} else if err != nil {
+		panic(err)
+	}
+
+	// load actor
+	targetActor, found, err := ic.rt.GetActor(targetIDAddr)
+	if err != nil {
+		panic(err)
+	}
+
+	if !found && created {
+		// create a new account actor
+		targetActor = &states.Actor{
+			Code:    builtin.AccountActorCodeID,
+			Head:    ic.emptyObject,
+			Balance: big.Zero(),
+		}
+		if err := ic.rt.SetActor(ic.rt.ctx, targetIDAddr, targetActor); err != nil {
+			panic(err)
+		}
+
+		// call constructor on account, as the system actor
+		newMsg := InternalMessage{
+			from:   builtin.SystemActorAddr,
+			to:     targetIDAddr,
+			value:  big.Zero(),
+			method: builtin.MethodsAccount.Constructor,
+			params: &target,
+		}
+
+		newCtx := newInvocationContext(ic.rt, ic.topLevel, newMsg, nil, ic.emptyObject)
+		_, code := newCtx.invoke()
+		if code.IsError() {
+			// we failed to construct an account actor..
+			ic.Abortf(code, "failed to construct account actor for %s", target)
+		}
+
+		// load actor again now that it has been constructed
+		targetActor, found, err = ic.rt.GetActor(targetIDAddr)
+		if err != nil {
+			panic(err)
+		}
+	}
+	if !found {
+		ic.Abortf(exitcode.SysErrInvalidReceiver, "actor at address %s registered but not found", targetIDAddr)
+	}
+
+	return targetActor, targetIDAddr
+}
+
+// Transfers value between two actors, returning the updated actor records.
+func (vm *VM) transfer(debitFrom addr.Address, creditTo addr.Address, amount abi.TokenAmount) (*states.Actor, *states.Actor) {
+	// allow only for positive amounts
+	if amount.LessThan(big.Zero()) {
+		panic("unreachable: negative funds transfer not allowed")
+	}
+
+	ctx := context.Background()
+
+	// retrieve debit account
+	fromActor, found, err := vm.GetActor(debitFrom)
+	if err != nil {
+		panic(err)
+	}
+	if !found {
+		panic(fmt.Errorf("unreachable: debit account not found. %s", err))
+	}
+
+	// check that account has enough balance for transfer
+	if fromActor.Balance.LessThan(amount) {
+		panic("unreachable: insufficient balance on debit account")
+	}
+
+	// debit funds
+	fromActor.Balance = big.Sub(fromActor.Balance, amount)
+	if err := vm.SetActor(ctx, debitFrom, fromActor); err != nil {
+		panic(err)
+	}
+
+	// retrieve credit account
+	toActor, found, err := vm.GetActor(creditTo)
+	if err != nil {
+		panic(err)
+	}
+	if !found {
+		panic(fmt.Errorf("unreachable: credit account not found. %s", err))
+	}
+
+	// credit funds
+	toActor.Balance = big.Add(toActor.Balance, amount)
+	if err := vm.SetActor(ctx, creditTo, toActor); err != nil {
+		panic(err)
+	}
+	return toActor, fromActor
+}
+
+// Records a log message emitted during execution.
+func (vm *VM) Log(level runtime.LogLevel, msg string, args ...interface{}) {
+	vm.logs = append(vm.logs, fmt.Sprintf(msg, args...))
+}
+
+type fakeTraceSpan struct {
+}
+
+func (t fakeTraceSpan) End() {
+	// no-op
+}
@@ -0,0 +1,464 @@
+package vm
+
+import (
+	"context"
+
+	addr "github.com/filecoin-project/go-address"
+	cid "github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+	"golang.org/x/xerrors"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	big "github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin/exported"
+	crypto "github.com/filecoin-project/specs-actors/actors/crypto"
+	runtime "github.com/filecoin-project/specs-actors/actors/runtime"
+	exitcode "github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	gas "github.com/filecoin-project/specs-actors/actors/runtime/gas"
+	states "github.com/filecoin-project/specs-actors/actors/states"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+	trace "github.com/filecoin-project/specs-actors/support/trace"
+)
+
+// VM is a simplified message execution framework for the purposes of testing inter-actor communication.
+// The VM maintains actor state and can be used to simulate message validation for a single block or tipset.
+// The VM does not charge gas (other than when estimating it), provide working syscalls, validate message nonces
+// and many other things that a compliant VM needs to do.
+type VM struct {
+	ctx   context.Context
+	store adt.Store
+
+	currentEpoch      abi.ChainEpoch
+	circulatingSupply abi.TokenAmount
+
+	invoker     *runtime.Invoker
+	stateRoot   cid.Cid      // The last committed root.
+	actors      *states.Tree // The current (not necessarily committed) root node.
+	actorsDirty bool
+
+	emptyObject cid.Cid
+
+	logs       []string
+	tracer     *trace.Recorder
+	randomness RandomnessSource
+}
+
+// A source of the chain randomness provided to actors.
+type RandomnessSource interface {
+	GetRandomnessFromBeacon(tag crypto.DomainSeparationTag, epoch abi.ChainEpoch, entropy []byte) abi.Randomness
+	GetRandomnessFromTickets(tag crypto.DomainSeparationTag, epoch abi.ChainEpoch, entropy []byte) abi.Randomness
+}
+
+// A store that attributes block accesses to the actor method being invoked, such as ipld.InstrumentedStore.
+// If the VM's store implements this interface, the VM enters each method it invokes.
+type MethodScopedStore interface {
+	EnterMethod(code cid.Cid, method abi.MethodNum) (exit func())
+}
+
+// Maps actor code CIDs to the implementations invoked for them.
+type ActorImplLookup map[cid.Cid]abi.Invokee
+
+// Returns a lookup of all the actors in this repository, keyed by their code CID.
+func BuiltinActorImpls() ActorImplLookup {
+	impls := ActorImplLookup{}
+	for _, actor := range exported.BuiltinActors() {
+		impls[actor.Code()] = actor
+	}
+	return impls
+}
+
+// Creates an invoker with each of the actor implementations registered.
+func newInvoker(actorImpls ActorImplLookup) (*runtime.Invoker, error) {
+	invoker := runtime.NewInvoker()
+	for code, impl := range actorImpls { //nolint:nomaprange
+		if err := invoker.Register(code, impl); err != nil {
+			return nil, err
+		}
+	}
+	return invoker, nil
+}
+
+// NewVM creates a new runtime for executing messages.
+func NewVM(ctx context.Context, actorImpls ActorImplLookup, store adt.Store) *VM {
+	invoker, err := newInvoker(actorImpls)
+	if err != nil {
+		panic(err)
+	}
+
+	actors := states.NewTree(store)
+	actorRoot, err := actors.Flush()
+	if err != nil {
+		panic(err)
+	}
+
+	emptyObject, err := store.Put(context.TODO(), []struct{}{})
+	if err != nil {
+		panic(err)
+	}
+
+	return &VM{
+		ctx:               ctx,
+		invoker:           invoker,
+		store:             store,
+		actors:            actors,
+		stateRoot:         actorRoot,
+		actorsDirty:       false,
+		emptyObject:       emptyObject,
+		circulatingSupply: big.Zero(),
+	}
+}
+
+// NewVMAtEpoch creates a new runtime for executing messages against an existing state root.
+func NewVMAtEpoch(ctx context.Context, actorImpls ActorImplLookup, store adt.Store, stateRoot cid.Cid, epoch abi.ChainEpoch) (*VM, error) {
+	invoker, err := newInvoker(actorImpls)
+	if err != nil {
+		return nil, err
+	}
+
+	actors, err := states.LoadTree(store, stateRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	emptyObject, err := store.Put(context.TODO(), []struct{}{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &VM{
+		ctx:               ctx,
+		invoker:           invoker,
+		store:             store,
+		actors:            actors,
+		stateRoot:         stateRoot,
+		actorsDirty:       false,
+		emptyObject:       emptyObject,
+		currentEpoch:      epoch,
+		circulatingSupply: big.Zero(),
+	}, nil
+}
+
+// Returns a new VM over the last committed state of this one, at a different epoch.
+func (vm *VM) WithEpoch(epoch abi.ChainEpoch) (*VM, error) {
+	_, err := vm.checkpoint()
+	if err != nil {
+		return nil, err
+	}
+
+	actors, err := states.LoadTree(vm.store, vm.stateRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VM{
+		ctx:               vm.ctx,
+		invoker:           vm.invoker,
+		store:             vm.store,
+		actors:            actors,
+		stateRoot:         vm.stateRoot,
+		actorsDirty:       false,
+		emptyObject:       vm.emptyObject,
+		currentEpoch:      epoch,
+		circulatingSupply: vm.circulatingSupply,
+		tracer:            vm.tracer,
+		randomness:        vm.randomness,
+	}, nil
+}
+
+// Sets a recorder to trace the execution of subsequent messages, or nil to stop tracing.
+func (vm *VM) SetTracer(tracer *trace.Recorder) {
+	vm.tracer = tracer
+}
+
+// Sets the source of randomness provided to actors.
+// Without a source, actors are provided the same constant value for every request.
+func (vm *VM) SetRandomness(randomness RandomnessSource) {
+	vm.randomness = randomness
+}
+
+// Returns the source of randomness provided to actors, or nil if none has been set.
+func (vm *VM) GetRandomness() RandomnessSource {
+	return vm.randomness
+}
+
+// Sets the value returned to actors by TotalFilCircSupply.
+func (vm *VM) SetCirculatingSupply(supply abi.TokenAmount) {
+	vm.circulatingSupply = supply
+}
+
+// Returns the value returned to actors by TotalFilCircSupply.
+func (vm *VM) GetCirculatingSupply() abi.TokenAmount {
+	return vm.circulatingSupply
+}
+
+// Flushes the actors map and returns the resulting root.
+func (vm *VM) checkpoint() (cid.Cid, error) {
+	// commit actor changes
+	if vm.actorsDirty {
+		root, err := vm.actors.Flush()
+		if err != nil {
+			return cid.Undef, err
+		}
+		vm.stateRoot = root
+		vm.actorsDirty = false
+	}
+	return vm.stateRoot, nil
+}
+
+// Discards all changes since the checkpoint with the given root.
+func (vm *VM) rollback(root cid.Cid) error {
+	var err error
+	vm.actors, err = states.LoadTree(vm.store, root)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load node for %s", root)
+	}
+
+	// reset the root node
+	vm.stateRoot = root
+	vm.actorsDirty = false
+	return nil
+}
+
+// Discards all changes to the state since it had the given root, such as one returned by StateRoot.
+func (vm *VM) Rollback(root cid.Cid) error {
+	return vm.rollback(root)
+}
+
+// Looks up an actor by ID address.
+func (vm *VM) GetActor(a addr.Address) (*states.Actor, bool, error) {
+	na, found := vm.NormalizeAddress(a)
+	if !found {
+		return nil, false, nil
+	}
+	return vm.actors.GetActor(na)
+}
+
+// Sets the actor record at an ID address.
+// Authors of tests should use this only to establish preconditions that cannot be reached through messages.
+func (vm *VM) SetActor(_ context.Context, key addr.Address, a *states.Actor) error {
+	if err := vm.actors.SetActor(key, a); err != nil {
+		return err
+	}
+	vm.actorsDirty = true
+	return nil
+}
+
+// Replaces the state of the actor at an ID address.
+// Authors of tests should use this only to establish preconditions that cannot be reached through messages.
+func (vm *VM) SetActorState(ctx context.Context, key addr.Address, state runtime.CBORMarshaler) error {
+	a, found, err := vm.GetActor(key)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return xerrors.Errorf("could not find actor %s to set state", key)
+	}
+	stateCid, err := vm.store.Put(ctx, state)
+	if err != nil {
+		return err
+	}
+	a.Head = stateCid
+	return vm.SetActor(ctx, key, a)
+}
+
+func (vm *VM) deleteActor(_ context.Context, key addr.Address) error {
+	if err := vm.actors.DeleteActor(key); err != nil {
+		return err
+	}
+	vm.actorsDirty = true
+	return nil
+}
+
+// Resolves an address to an ID address via the init actor's address table.
+func (vm *VM) NormalizeAddress(address addr.Address) (addr.Address, bool) {
+	idAddr, found, err := vm.actors.ResolveAddress(address)
+	if err != nil {
+		panic(err)
+	}
+	return idAddr, found
+}
+
+func (vm *VM) getActorByID(idAddr addr.Address) (*states.Actor, bool, error) {
+	return vm.actors.GetActor(idAddr)
+}
+
+// Commits any pending changes and returns the resulting state root.
+func (vm *VM) StateRoot() cid.Cid {
+	root, err := vm.checkpoint()
+	if err != nil {
+		panic(err)
+	}
+	return root
+}
+
+// Loads the current state of an actor into `out`.
+func (vm *VM) GetState(addr addr.Address, out runtime.CBORUnmarshaler) error {
+	act, found, err := vm.GetActor(addr)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return xerrors.Errorf("actor %v not found", addr)
+	}
+	return vm.store.Get(vm.ctx, act.Head, out)
+}
+
+// Returns the store backing the VM's state.
+func (vm *VM) Store() adt.Store {
+	return vm.store
+}
+
+// Returns the CID of the empty object, which is the head of an actor that has been created but not yet constructed.
+func (vm *VM) EmptyObject() cid.Cid {
+	return vm.emptyObject
+}
+
+// Returns the current epoch.
+func (vm *VM) GetEpoch() abi.ChainEpoch {
+	return vm.currentEpoch
+}
+
+// Returns the log messages emitted by actors since the VM was created.
+func (vm *VM) GetLogs() []string {
+	return vm.logs
+}
+
+// Returns the total balance held by all actors in the state tree.
+func (vm *VM) GetTotalActorBalance() (abi.TokenAmount, error) {
+	total := big.Zero()
+	err := vm.actors.ForEach(func(_ addr.Address, act *states.Actor) error {
+		total = big.Add(total, act.Balance)
+		return nil
+	})
+	return total, err
+}
+
+// ApplyMessage applies the message to the current state.
+// The return value is the value returned by the invoked method, which may be passed back through `Into`.
+// All state changes are rolled back if the top-level invocation does not succeed, other than the increment
+// of the sender's CallSeqNum.
+func (vm *VM) ApplyMessage(from, to addr.Address, value abi.TokenAmount, method abi.MethodNum, params interface{}) (runtime.SendReturn, exitcode.ExitCode) {
+	return vm.applyMessage(from, to, value, method, params, true, nil)
+}
+
+// ApplyImplicitMessage applies a message that is not signed by the sender, such as the system actor's
+// per-block reward and cron messages. It behaves as ApplyMessage, except that the sender's CallSeqNum is not
+// incremented, so it must not be used for messages that create actors via the init actor.
+func (vm *VM) ApplyImplicitMessage(from, to addr.Address, value abi.TokenAmount, method abi.MethodNum, params interface{}) (runtime.SendReturn, exitcode.ExitCode) {
+	return vm.applyMessage(from, to, value, method, params, false, nil)
+}
+
+// ApplyMeteredMessage applies a message as ApplyMessage does, with gas metered according to the pricelist for
+// the current epoch. Returns the gas used in addition to the result.
+// If the gas limit is exceeded the exit code is exitcode.SysErrOutOfGas, and state changes are rolled back other
+// than the increment of the sender's CallSeqNum.
+func (vm *VM) ApplyMeteredMessage(from, to addr.Address, value abi.TokenAmount, method abi.MethodNum, params interface{}, gasLimit int64) (runtime.SendReturn, exitcode.ExitCode, int64) {
+	tracker := gas.NewTracker(gasLimit)
+	ret, code := vm.applyMessage(from, to, value, method, params, true, tracker)
+	return ret, code, tracker.Used()
+}
+
+// EstimateGas applies a message with gas metered according to the pricelist for the current epoch, then discards
+// all resulting state changes, including the increment of the sender's CallSeqNum.
+// Returns the gas used and the exit code, which is exitcode.SysErrOutOfGas if the limit is exceeded.
+// The gas used excludes the cost of including the message in a block, which depends on its signed serialization.
+func (vm *VM) EstimateGas(from, to addr.Address, value abi.TokenAmount, method abi.MethodNum, params interface{}, gasLimit int64) (int64, exitcode.ExitCode) {
+	priorRoot, err := vm.checkpoint()
+	if err != nil {
+		panic(err)
+	}
+
+	tracker := gas.NewTracker(gasLimit)
+	_, code := vm.applyMessage(from, to, value, method, params, true, tracker)
+	if err := vm.rollback(priorRoot); err != nil {
+		panic(err)
+	}
+	return tracker.Used(), code
+}
+
+// Applies a message, metering gas if the tracker is non-nil.
+func (vm *VM) applyMessage(from, to addr.Address, value abi.TokenAmount, method abi.MethodNum, params interface{}, incrementCallSeq bool, tracker *gas.Tracker) (runtime.SendReturn, exitcode.ExitCode) {
+	// load actor from global state
+	fromID, ok := vm.NormalizeAddress(from)
+	if !ok {
+		return nil, exitcode.SysErrSenderInvalid
+	}
+
+	fromActor, found, err := vm.getActorByID(fromID)
+	if err != nil {
+		panic(err)
+	}
+	if !found {
+		// Execution error; sender does not exist at time of message execution.
+		return nil, exitcode.SysErrSenderInvalid
+	}
+
+	// increment sender's call sequence number and commit it regardless of the message outcome.
+	callSeq := fromActor.CallSeqNum
+	if incrementCallSeq {
+		fromActor.CallSeqNum++
+		if err := vm.SetActor(vm.ctx, fromID, fromActor); err != nil {
+			panic(err)
+		}
+	}
+	priorRoot, err := vm.checkpoint()
+	if err != nil {
+		panic(err)
+	}
+
+	// send
+	topLevel := topLevelContext{
+		originatorStableAddress: from,
+		originatorCallSeq:       callSeq,
+		newActorAddressCount:    0,
+		gasTracker:              tracker,
+		pricelist:               gas.PricelistByEpoch(vm.currentEpoch),
+	}
+
+	msg := InternalMessage{
+		from:   fromID,
+		to:     to,
+		value:  value,
+		method: method,
+		params: params,
+	}
+
+	if vm.tracer != nil {
+		vm.tracer.Begin(fromID, to, method, value, params, tracker)
+	}
+
+	var ret runtime.SendReturn
+	var exitCode exitcode.ExitCode
+	if tracker != nil && !tracker.TryCharge(topLevel.pricelist.OnMethodInvocation(value, method)) {
+		exitCode = exitcode.SysErrOutOfGas
+	} else {
+		ctx := newInvocationContext(vm, &topLevel, msg, fromActor, vm.emptyObject)
+		ret, exitCode = ctx.invoke()
+	}
+
+	// An actor may continue after a send that ran out of gas, but the message as a whole fails.
+	if tracker != nil && tracker.Exhausted() {
+		exitCode = exitcode.SysErrOutOfGas
+	}
+
+	if vm.tracer != nil {
+		vm.tracer.End(ret, exitCode)
+	}
+
+	// Roll back all state if the receipt's exit code is not ok.
+	// This is required in addition to rollback within the invocation context since top level messages can fail for
+	// more reasons than internal ones. Invocation context still needs its own rollback so actors can recover and
+	// proceed from a nested call failure.
+	if exitCode != exitcode.Ok {
+		if err := vm.rollback(priorRoot); err != nil {
+			panic(err)
+		}
+	} else {
+		// persist changes from final invocation if call is ok
+		if _, err := vm.checkpoint(); err != nil {
+			panic(err)
+		}
+	}
+
+	return ret, exitCode
+}
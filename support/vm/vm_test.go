@@ -0,0 +1,247 @@
+package vm_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	abi "github.com/filecoin-project/specs-actors/actors/abi"
+	big "github.com/filecoin-project/specs-actors/actors/abi/big"
+	builtin "github.com/filecoin-project/specs-actors/actors/builtin"
+	init_ "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	miner "github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	paych "github.com/filecoin-project/specs-actors/actors/builtin/paych"
+	power "github.com/filecoin-project/specs-actors/actors/builtin/power"
+	exitcode "github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	gas "github.com/filecoin-project/specs-actors/actors/runtime/gas"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+	tutil "github.com/filecoin-project/specs-actors/support/testing"
+	vm "github.com/filecoin-project/specs-actors/support/vm"
+)
+
+func TestTransfer(t *testing.T) {
+	ctx := context.Background()
+	v := vm.NewVMWithSingletons(ctx, t)
+	addrs := vm.CreateAccounts(ctx, t, v, 2, big.Mul(big.NewInt(10), vm.FIL), 93837778)
+	from, to := addrs[0], addrs[1]
+
+	t.Run("value moves between balances", func(t *testing.T) {
+		vm.ApplyOk(t, v, from, to, vm.FIL, builtin.MethodSend, nil)
+
+		assert.Equal(t, big.Mul(big.NewInt(9), vm.FIL), actorBalance(t, v, from))
+		assert.Equal(t, big.Mul(big.NewInt(11), vm.FIL), actorBalance(t, v, to))
+	})
+
+	t.Run("insufficient funds", func(t *testing.T) {
+		vm.ApplyCode(t, v, from, to, big.Mul(big.NewInt(100), vm.FIL), builtin.MethodSend, nil, exitcode.SysErrInsufficientFunds)
+
+		assert.Equal(t, big.Mul(big.NewInt(9), vm.FIL), actorBalance(t, v, from))
+		assert.Equal(t, big.Mul(big.NewInt(11), vm.FIL), actorBalance(t, v, to))
+	})
+
+	t.Run("unknown sender", func(t *testing.T) {
+		unknown := tutil.NewBLSAddr(t, 1)
+		vm.ApplyCode(t, v, unknown, to, vm.FIL, builtin.MethodSend, nil, exitcode.SysErrSenderInvalid)
+	})
+
+	t.Run("send to new pubkey address creates an account", func(t *testing.T) {
+		newAddr := tutil.NewSECP256K1Addr(t, "new account")
+		vm.ApplyOk(t, v, from, newAddr, vm.FIL, builtin.MethodSend, nil)
+
+		act, found, err := v.GetActor(newAddr)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, builtin.AccountActorCodeID, act.Code)
+		assert.Equal(t, vm.FIL, act.Balance)
+
+		ret := vm.ApplyOk(t, v, from, newAddr, big.Zero(), builtin.MethodsAccount.PubkeyAddress, nil)
+		var pubkey = tutil.NewIDAddr(t, 0)
+		require.NoError(t, ret.Into(&pubkey))
+		assert.Equal(t, newAddr, pubkey)
+	})
+
+	t.Run("send to unknown actor address fails", func(t *testing.T) {
+		vm.ApplyCode(t, v, from, tutil.NewActorAddr(t, "unknown"), big.Zero(), builtin.MethodSend, nil, exitcode.SysErrInvalidReceiver)
+	})
+
+	t.Run("invalid method", func(t *testing.T) {
+		vm.ApplyCode(t, v, from, builtin.InitActorAddr, big.Zero(), abi.MethodNum(99), nil, exitcode.SysErrInvalidMethod)
+	})
+}
+
+func TestCreateMiner(t *testing.T) {
+	ctx := context.Background()
+	v := vm.NewVMWithSingletons(ctx, t)
+	addrs := vm.CreateAccounts(ctx, t, v, 1, big.Mul(big.NewInt(10_000), vm.FIL), 93837778)
+	owner := addrs[0]
+
+	params := power.CreateMinerParams{
+		Owner:         owner,
+		Worker:        owner,
+		SealProofType: abi.RegisteredSealProof_StackedDrg32GiBV1,
+		Peer:          abi.PeerID("not really a peer id"),
+	}
+	ret := vm.ApplyOk(t, v, owner, builtin.StoragePowerActorAddr, vm.FIL, builtin.MethodsPower.CreateMiner, &params)
+
+	var minerAddrs power.CreateMinerReturn
+	require.NoError(t, ret.Into(&minerAddrs))
+
+	// the miner exists, with the value sent to power
+	act, found, err := v.GetActor(minerAddrs.RobustAddress)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, builtin.StorageMinerActorCodeID, act.Code)
+	assert.Equal(t, vm.FIL, act.Balance)
+
+	ownerID, found := v.NormalizeAddress(owner)
+	require.True(t, found)
+
+	var minerState miner.State
+	require.NoError(t, v.GetState(minerAddrs.IDAddress, &minerState))
+	info, err := minerState.GetInfo(v.Store())
+	require.NoError(t, err)
+	assert.Equal(t, ownerID, info.Owner)
+	assert.Equal(t, ownerID, info.Worker)
+
+	// power has a claim for the miner, and a cron event enrolled by the miner constructor
+	var powerState power.State
+	require.NoError(t, v.GetState(builtin.StoragePowerActorAddr, &powerState))
+	assert.Equal(t, int64(1), powerState.MinerCount)
+
+	cronEvents, err := adt.AsMultimap(v.Store(), powerState.CronEventQueue)
+	require.NoError(t, err)
+	enrolled := 0
+	err = cronEvents.ForAll(func(_ string, arr *adt.Array) error {
+		enrolled += int(arr.Length())
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, enrolled)
+}
+
+func TestAbortRollsBackState(t *testing.T) {
+	ctx := context.Background()
+	v := vm.NewVMWithSingletons(ctx, t)
+	addrs := vm.CreateAccounts(ctx, t, v, 1, big.Mul(big.NewInt(10_000), vm.FIL), 93837778)
+	owner := addrs[0]
+
+	var initBefore init_.State
+	require.NoError(t, v.GetState(builtin.InitActorAddr, &initBefore))
+
+	// The init actor allocates an ID for the new miner before the miner constructor aborts.
+	params := power.CreateMinerParams{
+		Owner:         owner,
+		Worker:        owner,
+		SealProofType: abi.RegisteredSealProof(-1),
+		Peer:          abi.PeerID("not really a peer id"),
+	}
+	vm.ApplyCode(t, v, owner, builtin.StoragePowerActorAddr, vm.FIL, builtin.MethodsPower.CreateMiner, &params, exitcode.ErrIllegalArgument)
+
+	var initAfter init_.State
+	require.NoError(t, v.GetState(builtin.InitActorAddr, &initAfter))
+	assert.Equal(t, initBefore, initAfter)
+
+	var powerState power.State
+	require.NoError(t, v.GetState(builtin.StoragePowerActorAddr, &powerState))
+	assert.Equal(t, int64(0), powerState.MinerCount)
+
+	assert.Equal(t, big.Mul(big.NewInt(10_000), vm.FIL), actorBalance(t, v, owner))
+
+	// the sender's call sequence number is incremented despite the failure
+	act, found, err := v.GetActor(owner)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, uint64(1), act.CallSeqNum)
+}
+
+func TestEstimateGas(t *testing.T) {
+	ctx := context.Background()
+	v := vm.NewVMWithSingletons(ctx, t)
+	addrs := vm.CreateAccounts(ctx, t, v, 2, big.Mul(big.NewInt(10_000), vm.FIL), 93837778)
+	owner, to := addrs[0], addrs[1]
+	pricelist := gas.PricelistByEpoch(v.GetEpoch())
+
+	t.Run("transfer", func(t *testing.T) {
+		used, code := v.EstimateGas(owner, to, vm.FIL, builtin.MethodSend, nil, gas.BlockGasLimit)
+		assert.Equal(t, exitcode.Ok, code)
+		assert.Equal(t, pricelist.OnMethodInvocation(vm.FIL, builtin.MethodSend).Total(), used)
+
+		// nothing changes, including the sender's call sequence number
+		assert.Equal(t, big.Mul(big.NewInt(10_000), vm.FIL), actorBalance(t, v, to))
+		act, found, err := v.GetActor(owner)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, uint64(0), act.CallSeqNum)
+	})
+
+	params := power.CreateMinerParams{
+		Owner:         owner,
+		Worker:        owner,
+		SealProofType: abi.RegisteredSealProof_StackedDrg32GiBV1,
+		Peer:          abi.PeerID("not really a peer id"),
+	}
+
+	t.Run("create miner", func(t *testing.T) {
+		used, code := v.EstimateGas(owner, builtin.StoragePowerActorAddr, vm.FIL, builtin.MethodsPower.CreateMiner, &params, gas.BlockGasLimit)
+		assert.Equal(t, exitcode.Ok, code)
+		assert.Greater(t, used, pricelist.OnCreateActor().Total())
+
+		var powerState power.State
+		require.NoError(t, v.GetState(builtin.StoragePowerActorAddr, &powerState))
+		assert.Equal(t, int64(0), powerState.MinerCount)
+
+		// the estimate is sufficient, and anything less is not
+		_, code = v.EstimateGas(owner, builtin.StoragePowerActorAddr, vm.FIL, builtin.MethodsPower.CreateMiner, &params, used)
+		assert.Equal(t, exitcode.Ok, code)
+		_, code = v.EstimateGas(owner, builtin.StoragePowerActorAddr, vm.FIL, builtin.MethodsPower.CreateMiner, &params, used-1)
+		assert.Equal(t, exitcode.SysErrOutOfGas, code)
+	})
+
+	t.Run("out of gas", func(t *testing.T) {
+		used, code := v.EstimateGas(owner, builtin.StoragePowerActorAddr, vm.FIL, builtin.MethodsPower.CreateMiner, &params, 1000)
+		assert.Equal(t, exitcode.SysErrOutOfGas, code)
+		assert.Equal(t, int64(1000), used)
+	})
+}
+
+func actorBalance(t *testing.T, v *vm.VM, a addr.Address) abi.TokenAmount {
+	act, found, err := v.GetActor(a)
+	require.NoError(t, err)
+	require.True(t, found)
+	return act.Balance
+}
+
+func TestDeleteActorWithMissingBeneficiary(t *testing.T) {
+	ctx := context.Background()
+	v := vm.NewVMWithSingletons(ctx, t)
+	addrs := vm.CreateAccounts(ctx, t, v, 2, big.Mul(big.NewInt(10), vm.FIL), 93837778)
+	from, to := addrs[0], addrs[1]
+
+	ctorParams := bytes.Buffer{}
+	require.NoError(t, (&paych.ConstructorParams{From: from, To: to}).MarshalCBOR(&ctorParams))
+	ret := vm.ApplyOk(t, v, from, builtin.InitActorAddr, vm.FIL, builtin.MethodsInit.Exec, &init_.ExecParams{
+		CodeCID:           builtin.PaymentChannelActorCodeID,
+		ConstructorParams: ctorParams.Bytes(),
+	})
+	var execRet init_.ExecReturn
+	require.NoError(t, ret.Into(&execRet))
+	paychAddr := execRet.IDAddress
+	vm.ApplyOk(t, v, to, paychAddr, big.Zero(), builtin.MethodsPaych.Settle, nil)
+
+	// The channel's payer, to which its balance is returned on collection, no longer exists.
+	var st paych.State
+	require.NoError(t, v.GetState(paychAddr, &st))
+	var err error
+	st.From, err = addr.NewIDAddress(999999)
+	require.NoError(t, err)
+	require.NoError(t, v.SetActorState(ctx, paychAddr, &st))
+
+	v, err = v.WithEpoch(st.SettlingAt)
+	require.NoError(t, err)
+	vm.ApplyCode(t, v, to, paychAddr, big.Zero(), builtin.MethodsPaych.Collect, nil, exitcode.SysErrorIllegalActor)
+	assert.Equal(t, vm.FIL, actorBalance(t, v, paychAddr))
+}